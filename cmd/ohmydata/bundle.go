@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/xuanbo/ohmydata/pkg/srv"
+)
+
+// exportBundle 导出文档到文件或标准输出
+func exportBundle(args []string) error {
+	fs := newFlagSet("export", "")
+	var (
+		output      = fs.String("o", "", "输出文件，默认标准输出")
+		format      = fs.String("format", srv.BundleYAML, "文档格式：yaml、json")
+		secrets     = fs.String("secrets", srv.SecretRedact, "密码导出方式：redact、reference")
+		dataSources = fs.String("datasource", "", "导出的数据源ID，多个以逗号分隔")
+		dataSets    = fs.String("dataset", "", "导出的数据集ID，多个以逗号分隔，引用的数据源一并导出")
+	)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := bootstrap(withCache | withDB); err != nil {
+		return err
+	}
+
+	bundle := srv.NewBundle(srv.NewDataSource(), srv.NewDataSet())
+	doc, err := bundle.Export(context.Background(), &srv.BundleExport{
+		DataSourceIDs: splitList(*dataSources),
+		DataSetIDs:    splitList(*dataSets),
+		Secrets:       *secrets,
+	})
+	if err != nil {
+		return err
+	}
+	b, err := srv.EncodeBundle(doc, *format)
+	if err != nil {
+		return err
+	}
+	if *output == "" {
+		_, err = os.Stdout.Write(b)
+		return err
+	}
+	return ioutil.WriteFile(*output, b, 0644)
+}
+
+// importBundle 导入文件或标准输入的文档
+func importBundle(args []string) error {
+	fs := newFlagSet("import", "<文件，-表示标准输入>")
+	var (
+		dryRun     = fs.Bool("dry-run", false, "试运行，仅输出变更不保存")
+		onConflict = fs.String("on-conflict", srv.ConflictFail, "名称、路径冲突处理方式：fail、skip、overwrite")
+		output     = fs.String("output", outputTable, "输出格式：table、json")
+	)
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		fs.Usage()
+		return errors.New("请指定导入的文件")
+	}
+	b, err := readFile(positional[0])
+	if err != nil {
+		return err
+	}
+	doc, err := srv.DecodeBundle(b)
+	if err != nil {
+		return err
+	}
+	if err := bootstrap(withDrivers | withCache | withDB); err != nil {
+		return err
+	}
+
+	bundle := srv.NewBundle(srv.NewDataSource(), srv.NewDataSet())
+	result, err := bundle.Import(context.Background(), doc, &srv.BundleImport{DryRun: *dryRun, OnConflict: *onConflict})
+	if err != nil {
+		return err
+	}
+	if *output == outputJSON {
+		return printJSON(result)
+	}
+	rows := make([][]string, len(result.Changes))
+	for i, change := range result.Changes {
+		rows[i] = []string{change.Type, change.Name, change.Path, change.Action, change.Reason}
+	}
+	printTable([]string{"TYPE", "NAME", "PATH", "ACTION", "REASON"}, rows)
+	if result.DryRun {
+		fmt.Println("试运行，未保存")
+	}
+	return nil
+}
+
+// readFile 读取文件，-表示标准输入
+func readFile(name string) ([]byte, error) {
+	if name == "-" {
+		return ioutil.ReadAll(os.Stdin)
+	}
+	return ioutil.ReadFile(name)
+}
+
+func splitList(s string) []string {
+	list := make([]string, 0)
+	for _, e := range strings.Split(s, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			list = append(list, e)
+		}
+	}
+	return list
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/xuanbo/ohmydata/pkg/model"
+	"github.com/xuanbo/ohmydata/pkg/srv"
+)
+
+// params 可重复的k=v参数
+type params map[string]interface{}
+
+func (p params) String() string {
+	return fmt.Sprintf("%v", map[string]interface{}(p))
+}
+
+func (p params) Set(s string) error {
+	i := strings.Index(s, "=")
+	if i <= 0 {
+		return fmt.Errorf("参数格式应为k=v: %s", s)
+	}
+	p[s[:i]] = s[i+1:]
+	return nil
+}
+
+// runDataSet 按路径执行已发布的数据集，与调用API一致
+func runDataSet(args []string) error {
+	fs := newFlagSet("dataset run", "<请求路径>")
+	var (
+		method = fs.String("method", http.MethodGet, "请求方法，写操作数据集为POST、PUT、DELETE")
+		output = fs.String("output", outputTable, "输出格式：table、json")
+		values = make(params)
+	)
+	fs.Var(values, "param", "请求参数k=v，可重复指定，分页使用page、size")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		fs.Usage()
+		return errors.New("请指定数据集请求路径")
+	}
+	path := strings.TrimPrefix(strings.TrimPrefix(positional[0], "/"), "api/")
+	if err := bootstrap(withDrivers | withCache | withDB); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	dataSet := srv.NewDataSet()
+	if err := dataSet.LoadRoutes(ctx); err != nil {
+		return err
+	}
+	matched, err := dataSet.Lookup(ctx, strings.ToUpper(*method), path, make(map[string]interface{}))
+	if err != nil {
+		return err
+	}
+	// 仅加载数据集使用的数据源
+	if err := srv.NewDataSource().Load(ctx, matched.SourceID); err != nil {
+		return err
+	}
+	// 命令行调用不校验API授权
+	result, err := dataSet.ServeAPI(srv.WithInternal(ctx), strings.ToUpper(*method), path, values)
+	if err != nil {
+		return err
+	}
+
+	if *output == outputJSON {
+		return printJSON(result)
+	}
+	// 分页结果输出数据以及分页信息
+	if page, ok := result.(*model.Pagination); ok {
+		if err := printRecords(page.Data); err != nil {
+			return err
+		}
+		fmt.Printf("第%d页，每页%d条，共%d条\n", page.Page, page.Size, page.Total)
+		return nil
+	}
+	return printRecords(result)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/xuanbo/ohmydata/pkg/entity"
+	"github.com/xuanbo/ohmydata/pkg/srv"
+)
+
+// testDataSource 测试文档中的数据源，或以参数指定的数据源连接
+func testDataSource(args []string) error {
+	fs := newFlagSet("datasource test", "[文档文件，-表示标准输入]")
+	var (
+		name       = fs.String("name", "", "仅测试文档中指定名称的数据源")
+		sourceType = fs.String("type", "", "数据源类型：mysql、postgres、elastic")
+		url        = fs.String("url", "", "连接地址")
+		username   = fs.String("username", "", "用户名")
+		password   = fs.String("password", "", "密码")
+	)
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+
+	var dataSources []*entity.DataSource
+	switch {
+	case len(positional) == 1:
+		b, err := readFile(positional[0])
+		if err != nil {
+			return err
+		}
+		doc, err := srv.DecodeBundle(b)
+		if err != nil {
+			return err
+		}
+		for _, e := range doc.DataSources {
+			if *name != "" && e.Name != *name {
+				continue
+			}
+			dataSource, err := e.DataSource()
+			if err != nil {
+				return err
+			}
+			dataSources = append(dataSources, dataSource)
+		}
+		if len(dataSources) == 0 {
+			return errors.New("文档中没有需要测试的数据源")
+		}
+	case *sourceType != "":
+		dataSources = append(dataSources, &entity.DataSource{
+			Type:     *sourceType,
+			Name:     *sourceType,
+			URL:      *url,
+			Username: *username,
+			Password: *password,
+		})
+	default:
+		fs.Usage()
+		return errors.New("请指定文档文件或数据源类型、连接地址")
+	}
+	if err := bootstrap(withDrivers); err != nil {
+		return err
+	}
+
+	var failed int
+	dataSource := srv.NewDataSource()
+	rows := make([][]string, len(dataSources))
+	for i, e := range dataSources {
+		status := "OK"
+		if err := dataSource.Test(context.Background(), e); err != nil {
+			status = err.Error()
+			failed++
+		}
+		rows[i] = []string{e.Name, e.Type, status}
+	}
+	printTable([]string{"NAME", "TYPE", "STATUS"}, rows)
+	if failed > 0 {
+		return fmt.Errorf("%d个数据源无法连接", failed)
+	}
+	return nil
+}
@@ -1,6 +1,12 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
 	"github.com/xuanbo/ohmydata/pkg/api"
 	"github.com/xuanbo/ohmydata/pkg/cache"
 	"github.com/xuanbo/ohmydata/pkg/config"
@@ -9,45 +15,188 @@ import (
 	"github.com/xuanbo/ohmydata/pkg/db/mysql"
 	"github.com/xuanbo/ohmydata/pkg/db/postgres"
 	"github.com/xuanbo/ohmydata/pkg/log"
+	"github.com/xuanbo/ohmydata/pkg/tracing"
 
 	"go.uber.org/zap"
 )
 
+// command 子命令
+type command struct {
+	// 命令名，可包含子命令，如datasource test
+	name  string
+	usage string
+	run   func(args []string) error
+}
+
+var commands = []*command{
+	{name: "serve", usage: "启动HTTP服务（默认）", run: serve},
+	{name: "migrate", usage: "同步数据库表结构", run: migrate},
+	{name: "export", usage: "导出数据源、数据集文档", run: exportBundle},
+	{name: "import", usage: "导入数据源、数据集文档", run: importBundle},
+	{name: "datasource test", usage: "测试数据源连接", run: testDataSource},
+	{name: "dataset run", usage: "执行已发布的数据集并输出结果", run: runDataSet},
+	{name: "user create", usage: "新增用户", run: createUser},
+	{name: "user reset-password", usage: "重置用户密码", run: resetPassword},
+}
+
 func main() {
-	// 日志
-	if err := log.Init(); err != nil {
+	cmd, args := lookup(os.Args[1:])
+	if cmd == nil {
+		usage()
+		os.Exit(2)
+	}
+
+	// 日志，除serve外输出到标准错误，不影响命令输出
+	output := "stderr"
+	if cmd.name == "serve" {
+		output = "stdout"
+	}
+	if err := log.Init(output); err != nil {
 		panic(err)
 	}
 	defer log.Flush()
 
+	if err := cmd.run(args); err != nil {
+		if err == flag.ErrHelp {
+			os.Exit(2)
+		}
+		log.Logger().Error("执行命令错误", zap.String("command", cmd.name), zap.Error(err))
+		fmt.Fprintln(os.Stderr, err)
+		log.Flush()
+		os.Exit(1)
+	}
+}
+
+// lookup 匹配子命令，未指定时为serve
+func lookup(args []string) (*command, []string) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return commands[0], args
+	}
+	for _, cmd := range commands {
+		names := strings.Fields(cmd.name)
+		if len(args) < len(names) || strings.Join(args[:len(names)], " ") != cmd.name {
+			continue
+		}
+		return cmd, args[len(names):]
+	}
+	return nil, nil
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "用法: ohmydata <命令> [参数]")
+	fmt.Fprintln(os.Stderr)
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-22s %s\n", cmd.name, cmd.usage)
+	}
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "使用 ohmydata <命令> -h 查看命令参数")
+}
+
+// newFlagSet 子命令参数
+func newFlagSet(name, args string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "用法: ohmydata %s [参数] %s\n", name, args)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseArgs 解析参数，允许位置参数与选项交替出现，如dataset run <path> --param k=v
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+// 初始化步骤
+const (
+	withDrivers = 1 << iota
+	withCache
+	withDB
+	withMigrate
+)
+
+// bootstrap 按需初始化配置、驱动适配层、redis以及数据库，所有子命令共用
+func bootstrap(steps int) error {
 	// 配置
 	if err := config.Init(); err != nil {
-		log.Logger().Panic("初始化配置错误", zap.Error(err))
+		return fmt.Errorf("初始化配置错误: %w", err)
 	}
 
 	// 驱动适配层
-	if err := mysql.Register(); err != nil {
-		log.Logger().Panic("注册mysql驱动错误", zap.Error(err))
-	}
-	if err := postgres.Register(); err != nil {
-		log.Logger().Panic("注册postgres驱动错误", zap.Error(err))
-	}
-	if err := elastic.Register(); err != nil {
-		log.Logger().Panic("注册elastic驱动错误", zap.Error(err))
+	if steps&withDrivers != 0 {
+		if err := mysql.Register(); err != nil {
+			return fmt.Errorf("注册mysql驱动错误: %w", err)
+		}
+		if err := postgres.Register(); err != nil {
+			return fmt.Errorf("注册postgres驱动错误: %w", err)
+		}
+		if err := elastic.Register(); err != nil {
+			return fmt.Errorf("注册elastic驱动错误: %w", err)
+		}
 	}
 
 	// 初始化redis
-	if err := cache.Init(); err != nil {
-		log.Logger().Panic("初始化redis错误", zap.Error(err))
+	if steps&withCache != 0 {
+		if err := cache.Init(); err != nil {
+			return fmt.Errorf("初始化redis错误: %w", err)
+		}
 	}
 
-	// 同步数据库
-	if err := db.Init(); err != nil {
-		log.Logger().Panic("同步数据库表结构错误", zap.Error(err))
+	// 数据库
+	switch {
+	case steps&withMigrate != 0:
+		if err := db.Init(); err != nil {
+			return fmt.Errorf("同步数据库表结构错误: %w", err)
+		}
+	case steps&withDB != 0:
+		if err := db.Connect(); err != nil {
+			return fmt.Errorf("连接数据库错误: %w", err)
+		}
 	}
+	return nil
+}
 
+// serve 启动服务
+func serve(args []string) error {
+	fs := newFlagSet("serve", "")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := bootstrap(withDrivers | withCache | withMigrate); err != nil {
+		return err
+	}
+	// 链路追踪
+	shutdown, err := tracing.Init(context.TODO())
+	if err != nil {
+		return fmt.Errorf("初始化链路追踪错误: %w", err)
+	}
+	defer shutdown(context.TODO())
 	// 启动服务
 	if err := api.ServeHTTP(); err != nil {
-		log.Logger().Panic("启动HTTP服务错误", zap.Error(err))
+		return fmt.Errorf("启动HTTP服务错误: %w", err)
+	}
+	return nil
+}
+
+// migrate 仅同步数据库表结构
+func migrate(args []string) error {
+	fs := newFlagSet("migrate", "")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := bootstrap(withMigrate); err != nil {
+		return err
 	}
+	fmt.Println("数据库表结构同步完成")
+	return nil
 }
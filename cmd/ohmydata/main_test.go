@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLookup(t *testing.T) {
+	cases := []struct {
+		args []string
+		name string
+		rest []string
+	}{
+		{nil, "serve", nil},
+		{[]string{"-h"}, "serve", []string{"-h"}},
+		{[]string{"migrate"}, "migrate", []string{}},
+		{[]string{"datasource", "test", "bundle.yaml"}, "datasource test", []string{"bundle.yaml"}},
+		{[]string{"user", "reset-password", "-username", "admin"}, "user reset-password", []string{"-username", "admin"}},
+		{[]string{"datasource"}, "", nil},
+		{[]string{"unknown"}, "", nil},
+	}
+	for _, c := range cases {
+		cmd, rest := lookup(c.args)
+		if c.name == "" {
+			if cmd != nil {
+				t.Errorf("%v: expected nil, actual: %s", c.args, cmd.name)
+			}
+			continue
+		}
+		if cmd == nil || cmd.name != c.name {
+			t.Errorf("%v: expected %s, actual: %v", c.args, c.name, cmd)
+			continue
+		}
+		if len(rest) != len(c.rest) || (len(rest) > 0 && !reflect.DeepEqual(rest, c.rest)) {
+			t.Errorf("%v: expected args %v, actual: %v", c.args, c.rest, rest)
+		}
+	}
+}
+
+func TestParseArgs(t *testing.T) {
+	fs := newFlagSet("dataset run", "<请求路径>")
+	var (
+		method = fs.String("method", "GET", "")
+		p      = make(params)
+	)
+	fs.Var(p, "param", "")
+	positional, err := parseArgs(fs, []string{"user/1", "--param", "name=test", "-method", "POST", "extra", "--param", "a=b=c"})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if !reflect.DeepEqual(positional, []string{"user/1", "extra"}) {
+		t.Errorf("位置参数错误: %v", positional)
+	}
+	if *method != "POST" {
+		t.Errorf("选项错误: %s", *method)
+	}
+	if p["name"] != "test" || p["a"] != "b=c" {
+		t.Errorf("参数错误: %v", p)
+	}
+
+	fs = newFlagSet("dataset run", "<请求路径>")
+	fs.Var(make(params), "param", "")
+	fs.SetOutput(new(bytes.Buffer))
+	if _, err := parseArgs(fs, []string{"--param", "invalid"}); err == nil {
+		t.Error("参数格式错误时应返回错误")
+	}
+}
+
+func TestPrintRecords(t *testing.T) {
+	var buff bytes.Buffer
+	stdout = &buff
+	defer func() {
+		stdout = os.Stdout
+	}()
+
+	err := printRecords([]map[string]interface{}{
+		{"id": int64(1347469523384537088), "name": "test"},
+		{"id": 2, "tags": []string{"a"}, "extra": nil},
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	lines := strings.Split(strings.TrimSpace(buff.String()), "\n")
+	if len(lines) != 3 {
+		t.Errorf("输出行数错误: %s", buff.String())
+		return
+	}
+	if fields := strings.Fields(lines[0]); !reflect.DeepEqual(fields, []string{"extra", "id", "name", "tags"}) {
+		t.Errorf("表头错误: %v", fields)
+	}
+	// 保留数值精度，缺失字段以及null输出为NULL
+	if fields := strings.Fields(lines[1]); !reflect.DeepEqual(fields, []string{"NULL", "1347469523384537088", "test", "NULL"}) {
+		t.Errorf("第一行错误: %v", fields)
+	}
+	if fields := strings.Fields(lines[2]); !reflect.DeepEqual(fields, []string{"NULL", "2", "NULL", `["a"]`}) {
+		t.Errorf("第二行错误: %v", fields)
+	}
+
+	// 单个对象以及标量
+	buff.Reset()
+	if err := printRecords(map[string]interface{}{"total": 1}); err != nil {
+		t.Error(err)
+		return
+	}
+	if !strings.Contains(buff.String(), "total") {
+		t.Errorf("对象输出错误: %s", buff.String())
+	}
+	buff.Reset()
+	if err := printRecords(3); err != nil {
+		t.Error(err)
+		return
+	}
+	if fields := strings.Fields(buff.String()); !reflect.DeepEqual(fields, []string{"value", "3"}) {
+		t.Errorf("标量输出错误: %v", fields)
+	}
+}
+
+func TestReadPassword(t *testing.T) {
+	defer func() {
+		stdin = os.Stdin
+	}()
+
+	// 显式指定时优先
+	os.Setenv(passwordEnv, "env")
+	defer os.Unsetenv(passwordEnv)
+	if password, err := readPassword("flag"); err != nil || password != "flag" {
+		t.Errorf("expected flag, actual: %s, %v", password, err)
+	}
+	if password, err := readPassword(""); err != nil || password != "env" {
+		t.Errorf("expected env, actual: %s, %v", password, err)
+	}
+
+	os.Unsetenv(passwordEnv)
+	stdin = strings.NewReader("p@ss word\r\nignored\n")
+	if password, err := readPassword(""); err != nil || password != "p@ss word" {
+		t.Errorf("expected stdin, actual: %s, %v", password, err)
+	}
+	stdin = strings.NewReader("")
+	if _, err := readPassword(""); err == nil {
+		t.Error("密码为空时应返回错误")
+	}
+}
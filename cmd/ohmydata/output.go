@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/xuanbo/ohmydata/pkg/strutil"
+)
+
+// 输出格式
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+// stdout 命令输出
+var stdout io.Writer = os.Stdout
+
+func printJSON(v interface{}) error {
+	encoder := json.NewEncoder(stdout)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+func printTable(headers []string, rows [][]string) {
+	w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(headers, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	w.Flush()
+}
+
+// printRecords 以表格输出查询结果：对象数组按行输出，对象输出为一行，字段按名称排序
+func printRecords(v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	// 保留数值精度
+	var data interface{}
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.UseNumber()
+	if err := decoder.Decode(&data); err != nil {
+		return err
+	}
+
+	var records []map[string]interface{}
+	switch data := data.(type) {
+	case []interface{}:
+		for _, e := range data {
+			record, ok := e.(map[string]interface{})
+			if !ok {
+				record = map[string]interface{}{"value": e}
+			}
+			records = append(records, record)
+		}
+	case map[string]interface{}:
+		records = append(records, data)
+	case nil:
+	default:
+		records = append(records, map[string]interface{}{"value": data})
+	}
+
+	keys := make([]string, 0)
+	for _, record := range records {
+		for k := range record {
+			if !strutil.EqualAny(k, keys) {
+				keys = append(keys, k)
+			}
+		}
+	}
+	sort.Strings(keys)
+	rows := make([][]string, len(records))
+	for i, record := range records {
+		row := make([]string, len(keys))
+		for j, k := range keys {
+			row[j] = cell(record[k])
+		}
+		rows[i] = row
+	}
+	printTable(keys, rows)
+	return nil
+}
+
+func cell(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "NULL"
+	case string:
+		return v
+	case map[string]interface{}, []interface{}:
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+	return fmt.Sprintf("%v", v)
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/xuanbo/ohmydata/pkg/entity"
+	"github.com/xuanbo/ohmydata/pkg/srv"
+)
+
+// passwordEnv 密码环境变量
+const passwordEnv = "OHMYDATA_PASSWORD"
+
+// stdin 命令输入
+var stdin io.Reader = os.Stdin
+
+// createUser 新增用户
+func createUser(args []string) error {
+	fs := newFlagSet("user create", "")
+	var (
+		username = fs.String("username", "", "用户名")
+		password = fs.String("password", "", "密码，会出现在进程列表以及shell历史中，默认读取环境变量"+passwordEnv+"或标准输入")
+		name     = fs.String("name", "", "姓名，默认为用户名")
+	)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *username == "" {
+		fs.Usage()
+		return errors.New("请指定用户名")
+	}
+	pwd, err := readPassword(*password)
+	if err != nil {
+		return err
+	}
+	if err := bootstrap(withCache | withDB); err != nil {
+		return err
+	}
+
+	user := &entity.User{Username: *username, Password: pwd, Name: *name}
+	if err := srv.NewUser().Create(context.Background(), user); err != nil {
+		return err
+	}
+	fmt.Printf("用户%s已创建，ID: %s\n", user.Username, user.ID)
+	return nil
+}
+
+// resetPassword 重置用户密码
+func resetPassword(args []string) error {
+	fs := newFlagSet("user reset-password", "")
+	var (
+		username = fs.String("username", "", "用户名")
+		password = fs.String("password", "", "新密码，会出现在进程列表以及shell历史中，默认读取环境变量"+passwordEnv+"或标准输入")
+	)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *username == "" {
+		fs.Usage()
+		return errors.New("请指定用户名")
+	}
+	pwd, err := readPassword(*password)
+	if err != nil {
+		return err
+	}
+	if err := bootstrap(withCache | withDB); err != nil {
+		return err
+	}
+
+	if err := srv.NewUser().ResetPassword(context.Background(), *username, pwd); err != nil {
+		return err
+	}
+	fmt.Printf("用户%s密码已重置\n", *username)
+	return nil
+}
+
+// readPassword 读取密码：显式指定的-password、环境变量OHMYDATA_PASSWORD，否则从标准输入读取一行
+func readPassword(flag string) (string, error) {
+	if flag != "" {
+		return flag, nil
+	}
+	if password := os.Getenv(passwordEnv); password != "" {
+		return password, nil
+	}
+	fmt.Fprint(os.Stderr, "请输入密码: ")
+	line, err := bufio.NewReader(stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		return "", errors.New("密码不能为空")
+	}
+	return password, nil
+}
@@ -0,0 +1,106 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/xuanbo/ohmydata/pkg/api/middleware"
+	"github.com/xuanbo/ohmydata/pkg/api/util"
+	"github.com/xuanbo/ohmydata/pkg/entity"
+	"github.com/xuanbo/ohmydata/pkg/srv"
+
+	"github.com/labstack/echo/v4"
+)
+
+// 应用凭证请求头
+const (
+	headerAppKey    = "X-App-Key"
+	headerAppSecret = "X-App-Secret"
+	// 签名请求
+	headerTimestamp = "X-Timestamp"
+	headerNonce     = "X-Nonce"
+	headerSignature = "X-Signature"
+	// Authorization: App <key>.<secret>
+	authSchemeApp = "App"
+)
+
+// appAuth 应用凭证认证，仅作用于/api/*、/graphql：携带签名或应用凭证时校验并跳过登录token校验，
+// denyAdminToken为true时不再接受管理端登录token
+func appAuth(apps *srv.App, denyAdminToken bool) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			if !isAPIPath(ctx.Request().URL.Path) {
+				return next(ctx)
+			}
+			var (
+				cctx  = ctx.(*middleware.Context)
+				req   = ctx.Request()
+				app   *entity.App
+				err   error
+				key   = req.Header.Get(headerAppKey)
+				token = appToken(req.Header.Get(echo.HeaderAuthorization))
+			)
+			switch {
+			case req.Header.Get(headerSignature) != "":
+				app, err = verifySignature(cctx, apps)
+			case key != "":
+				app, err = apps.Authenticate(cctx.Ctx(), key, req.Header.Get(headerAppSecret))
+			case token != "":
+				app, err = apps.AuthenticateToken(cctx.Ctx(), token)
+			case denyAdminToken:
+				return echo.NewHTTPError(http.StatusUnauthorized, "缺少应用凭证")
+			default:
+				// 管理端登录token
+				return next(ctx)
+			}
+			if err != nil {
+				return err
+			}
+			// 传递到context，跳过登录token校验
+			ctx.Set("appId", app.ID)
+			cctx.SetCtx(context.WithValue(cctx.Ctx(), util.AppID, app.ID))
+			return next(ctx)
+		}
+	}
+}
+
+// verifySignature 校验签名请求，读取请求体后重新设置，不影响后续参数绑定
+func verifySignature(ctx *middleware.Context, apps *srv.App) (*entity.App, error) {
+	req := ctx.Request()
+	var body []byte
+	if req.Body != nil {
+		b, err := util.ReadBody(ctx)
+		if err != nil {
+			return nil, err
+		}
+		body = b
+		req.Body = ioutil.NopCloser(bytes.NewReader(b))
+	}
+	return apps.Verify(ctx.Ctx(), &srv.SignedRequest{
+		Key:       req.Header.Get(headerAppKey),
+		Timestamp: req.Header.Get(headerTimestamp),
+		Nonce:     req.Header.Get(headerNonce),
+		Signature: req.Header.Get(headerSignature),
+		Method:    req.Method,
+		Path:      req.URL.Path,
+		Params:    req.URL.Query(),
+		Body:      body,
+	})
+}
+
+// isAPIPath 已发布数据集的API路径
+func isAPIPath(path string) bool {
+	return strings.HasPrefix(path, "/api/") || path == "/graphql"
+}
+
+// appToken 解析Authorization: App <token>
+func appToken(authorization string) string {
+	if len(authorization) > len(authSchemeApp)+1 && strings.EqualFold(authorization[:len(authSchemeApp)], authSchemeApp) &&
+		authorization[len(authSchemeApp)] == ' ' {
+		return strings.TrimSpace(authorization[len(authSchemeApp)+1:])
+	}
+	return ""
+}
@@ -3,14 +3,19 @@ package api
 import (
 	"context"
 	"fmt"
+	"net"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/xuanbo/ohmydata/pkg/api/middleware"
 	"github.com/xuanbo/ohmydata/pkg/api/util"
 	"github.com/xuanbo/ohmydata/pkg/config"
+	"github.com/xuanbo/ohmydata/pkg/db"
 	"github.com/xuanbo/ohmydata/pkg/log"
+	"github.com/xuanbo/ohmydata/pkg/metrics"
 	"github.com/xuanbo/ohmydata/pkg/model"
+	"github.com/xuanbo/ohmydata/pkg/srv"
 
 	"github.com/dgrijalva/jwt-go"
 	"github.com/labstack/echo/v4"
@@ -32,22 +37,46 @@ func ServeHTTP() error {
 		timeout = 10
 	}
 
+	// 客户端IP，用于限流以及调用记录
+	extractor, err := ipExtractor(config.GetString("http.trustedProxies"))
+	if err != nil {
+		return err
+	}
+	router.IPExtractor = extractor
+
 	// Middleware
 	router.HTTPErrorHandler = customHTTPErrorHandler
+	enableMetrics := config.GetBool("metrics.enabled")
+	metricsAddr := config.GetString("metrics.addr")
+	publicMetrics := enableMetrics && metricsAddr == "" && config.GetBool("metrics.public")
+	if enableMetrics {
+		router.Use(middleware.Metrics())
+	}
+	if config.GetBool("tracing.enabled") {
+		router.Use(middleware.Tracing("ohmydata"))
+	}
 	router.Use(middleware.ZapLogger(log.Logger()))
 	router.Use(middleware.Recover(log.Logger()))
 	router.Use(mw.CORSWithConfig(mw.CORSConfig{
-		AllowOrigins:     []string{"*"},
-		AllowHeaders:     []string{echo.HeaderOrigin, echo.HeaderContentType, echo.HeaderAccept, echo.HeaderAuthorization},
-		AllowMethods:     []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete},
+		AllowOrigins: []string{"*"},
+		AllowHeaders: []string{
+			echo.HeaderOrigin, echo.HeaderContentType, echo.HeaderAccept, echo.HeaderAuthorization, "Idempotency-Key",
+			headerAppKey, headerAppSecret, headerTimestamp, headerNonce, headerSignature,
+		},
+		AllowMethods: []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete},
+		ExposeHeaders: []string{
+			"X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset", "Retry-After",
+		},
 		AllowCredentials: false,
 		MaxAge:           3600,
 	}))
 	router.Use(middleware.NewContext(time.Duration(timeout) * time.Second))
+	// 应用凭证
+	router.Use(appAuth(srv.NewApp(), config.GetBool("app.denyAdminToken")))
 	router.Use(mw.JWTWithConfig(mw.JWTConfig{
-		// 跳过登录
+		// 跳过登录、公开的指标以及已通过应用凭证认证的请求
 		Skipper: func(ctx echo.Context) bool {
-			return ctx.Path() == "/v1/user/login"
+			return ctx.Path() == "/v1/user/login" || (publicMetrics && ctx.Path() == "/metrics") || ctx.Get("appId") != nil
 		},
 		SigningKey:  []byte(secret),
 		ContextKey:  "JWT_TOKEN",
@@ -77,6 +106,18 @@ func ServeHTTP() error {
 		},
 	}))
 
+	// Prometheus指标，配置独立监听地址时不在服务地址上提供
+	if enableMetrics {
+		if err := metrics.RegisterDBStats(db.AllStats); err != nil {
+			return err
+		}
+		if metricsAddr == "" {
+			router.GET("/metrics", echo.WrapHandler(metrics.Handler()))
+		} else {
+			go serveMetrics(metricsAddr)
+		}
+	}
+
 	// 初始化路由
 	if err := InitRoutes(router); err != nil {
 		return err
@@ -93,6 +134,35 @@ func ServeHTTP() error {
 	return router.Start(addr)
 }
 
+// serveMetrics 在独立地址上提供/metrics，无需登录
+func serveMetrics(addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	log.Logger().Info("启动指标服务", zap.String("addr", addr))
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		log.Logger().Error("指标服务异常退出", zap.String("addr", addr), zap.Error(err))
+	}
+}
+
+// ipExtractor 未配置可信代理时使用连接地址，否则仅信任来自可信代理（逗号分隔的CIDR）的X-Forwarded-For
+func ipExtractor(trustedProxies string) (echo.IPExtractor, error) {
+	options := []echo.TrustOption{echo.TrustLoopback(false), echo.TrustLinkLocal(false), echo.TrustPrivateNet(false)}
+	for _, cidr := range strings.Split(trustedProxies, ",") {
+		if cidr = strings.TrimSpace(cidr); cidr == "" {
+			continue
+		}
+		_, ipRange, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("可信代理地址%s错误: %w", cidr, err)
+		}
+		options = append(options, echo.TrustIPRange(ipRange))
+	}
+	if len(options) == 3 {
+		return echo.ExtractIPDirect(), nil
+	}
+	return echo.ExtractIPFromXFFHeader(options...), nil
+}
+
 func customHTTPErrorHandler(err error, ctx echo.Context) {
 	if he, ok := err.(*echo.HTTPError); ok {
 		message := fmt.Sprintf("%s", he.Message)
@@ -4,7 +4,10 @@ import (
 	"context"
 	"time"
 
+	"github.com/xuanbo/ohmydata/pkg/api/util"
+
 	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Context 上下文
@@ -28,6 +31,10 @@ func NewContext(timeout time.Duration) echo.MiddlewareFunc {
 		return func(ctx echo.Context) (err error) {
 			c, cancel := context.WithTimeout(context.Background(), timeout)
 			defer cancel()
+			// 仅传递链路追踪的span，不随请求取消
+			c = trace.ContextWithSpan(c, trace.SpanFromContext(ctx.Request().Context()))
+			c = context.WithValue(c, util.ClientIP, ctx.RealIP())
+			c = context.WithValue(c, util.ResponseHeader, ctx.Response().Header())
 			ctx.Set("CTX", c)
 			return next(&Context{ctx})
 		}
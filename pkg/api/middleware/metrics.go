@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"time"
+
+	"github.com/xuanbo/ohmydata/pkg/metrics"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Metrics 请求指标中间件，需在日志中间件之前注册，以便统计错误处理后的响应状态
+func Metrics() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			start := time.Now()
+			err := next(ctx)
+			route := ctx.Path()
+			if route == "" {
+				route = "unmatched"
+			}
+			metrics.ObserveHTTP(ctx.Request().Method, route, ctx.Response().Status, time.Since(start))
+			return err
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"github.com/xuanbo/ohmydata/pkg/tracing"
+
+	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/semconv"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracing 链路追踪中间件，从请求头中提取W3C trace context，需在日志中间件之前注册，以便记录错误处理后的响应状态
+func Tracing(serverName string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			req := ctx.Request()
+			c := otel.GetTextMapPropagator().Extract(req.Context(), req.Header)
+			c, span := tracing.Tracer().Start(c, req.Method+" "+ctx.Path(),
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(semconv.HTTPServerAttributesFromHTTPRequest(serverName, ctx.Path(), req)...),
+			)
+			// 传递到NewContext创建的context
+			ctx.SetRequest(req.WithContext(c))
+
+			err := next(ctx)
+			status := ctx.Response().Status
+			span.SetAttributes(semconv.HTTPAttributesFromHTTPStatusCode(status)...)
+			span.SetStatus(semconv.SpanStatusFromHTTPStatusCode(status))
+			if err != nil {
+				span.RecordError(err)
+			}
+			span.End()
+			return err
+		}
+	}
+}
@@ -19,13 +19,37 @@ func InitRoutes(router *echo.Echo) error {
 	if err := addRoutes(router, v1.NewUser(srv.NewUser())); err != nil {
 		return err
 	}
+	if err := addRoutes(router, v1.NewApp(srv.NewApp())); err != nil {
+		return err
+	}
+	if err := addRoutes(router, v1.NewGrant(srv.NewGrant())); err != nil {
+		return err
+	}
+	if err := addRoutes(router, v1.NewRateLimit(srv.NewRateLimiter())); err != nil {
+		return err
+	}
+	if err := addRoutes(router, v1.NewCallLog(srv.NewCallLog())); err != nil {
+		return err
+	}
 	if err := addRoutes(router, v1.NewDict()); err != nil {
 		return err
 	}
-	if err := addRoutes(router, v1.NewDataSource(srv.NewDataSource())); err != nil {
+	dataSource := srv.NewDataSource()
+	if err := addRoutes(router, v1.NewDataSource(dataSource)); err != nil {
+		return err
+	}
+	dataSet := srv.NewDataSet()
+	if err := addRoutes(router, v1.NewDataSet(dataSet)); err != nil {
+		return err
+	}
+	if err := addRoutes(router, v1.NewDrift(srv.NewDrift(dataSet))); err != nil {
+		return err
+	}
+	bundle := srv.NewBundle(dataSource, dataSet)
+	if err := addRoutes(router, v1.NewBundle(bundle)); err != nil {
 		return err
 	}
-	if err := addRoutes(router, v1.NewDataSet(srv.NewDataSet())); err != nil {
+	if err := addRoutes(router, v1.NewGitOps(srv.NewGitOps(bundle))); err != nil {
 		return err
 	}
 	return nil
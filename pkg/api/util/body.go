@@ -0,0 +1,34 @@
+package util
+
+import (
+	"io/ioutil"
+	"net/http"
+
+	"github.com/xuanbo/ohmydata/pkg/config"
+
+	"github.com/labstack/echo/v4"
+)
+
+// 默认请求体大小限制，10MB
+const defaultMaxBodyBytes = 10 << 20
+
+// ReadBody 读取请求体，超过http.maxBodyBytes时返回413
+func ReadBody(ctx echo.Context) ([]byte, error) {
+	req := ctx.Request()
+	if req.Body == nil {
+		return nil, nil
+	}
+	limit := int64(config.GetInt("http.maxBodyBytes"))
+	if limit <= 0 {
+		limit = defaultMaxBodyBytes
+	}
+	b, err := ioutil.ReadAll(http.MaxBytesReader(ctx.Response(), req.Body, limit))
+	if err != nil {
+		// 读取到限制大小仍有数据
+		if int64(len(b)) >= limit {
+			return nil, echo.NewHTTPError(http.StatusRequestEntityTooLarge, "请求体过大")
+		}
+		return nil, err
+	}
+	return b, nil
+}
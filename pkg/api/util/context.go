@@ -3,4 +3,12 @@ package util
 const (
 	// UserID 用户ID
 	UserID = "USER_ID"
+	// IdempotencyKey 幂等键，请求头Idempotency-Key
+	IdempotencyKey = "IDEMPOTENCY_KEY"
+	// AppID 使用应用凭证调用API时的应用ID
+	AppID = "APP_ID"
+	// ClientIP 客户端IP
+	ClientIP = "CLIENT_IP"
+	// ResponseHeader 响应头，用于在服务层设置限流等响应头
+	ResponseHeader = "RESPONSE_HEADER"
 )
@@ -0,0 +1,136 @@
+package v1
+
+import (
+	"net/http"
+
+	"github.com/xuanbo/ohmydata/pkg/api/middleware"
+	"github.com/xuanbo/ohmydata/pkg/entity"
+	"github.com/xuanbo/ohmydata/pkg/model"
+	"github.com/xuanbo/ohmydata/pkg/srv"
+
+	"github.com/labstack/echo/v4"
+)
+
+// App 应用凭证API管理
+type App struct {
+	srv *srv.App
+}
+
+// NewApp 创建
+func NewApp(srv *srv.App) *App {
+	return &App{srv}
+}
+
+// Init 初始化
+func (s *App) Init() error {
+	return nil
+}
+
+// AddRoutes 添加路由
+func (s *App) AddRoutes(e *echo.Echo) {
+	g := e.Group("/v1")
+	{
+		g.POST("/app", s.Create)
+		g.PUT("/app", s.Modify)
+		g.GET("/app/:id", s.ID)
+		g.DELETE("/app/:id", s.Remove)
+		g.POST("/app/page", s.Page)
+		g.PUT("/app/:id/rotate", s.Rotate)
+		g.PUT("/app/:id/revoke", s.Revoke)
+	}
+}
+
+// Create 创建，返回的密钥、token仅此一次可见
+func (s *App) Create(ctx echo.Context) error {
+	var app entity.App
+	if err := ctx.Bind(&app); err != nil {
+		return err
+	}
+	c := ctx.(*middleware.Context).Ctx()
+	credential, err := s.srv.Create(c, &app)
+	if err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, model.OK(credential))
+}
+
+// Modify 更新
+func (s *App) Modify(ctx echo.Context) error {
+	var app entity.App
+	if err := ctx.Bind(&app); err != nil {
+		return err
+	}
+	c := ctx.(*middleware.Context).Ctx()
+	if err := s.srv.Modify(c, &app); err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, model.OK(app))
+}
+
+// Remove 删除
+func (s *App) Remove(ctx echo.Context) error {
+	id := ctx.Param("id")
+	c := ctx.(*middleware.Context).Ctx()
+	if err := s.srv.Remove(c, id); err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, model.OK(id))
+}
+
+// ID 主键查询
+func (s *App) ID(ctx echo.Context) error {
+	id := ctx.Param("id")
+	c := ctx.(*middleware.Context).Ctx()
+	app, err := s.srv.ID(c, id)
+	if err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, model.OK(app))
+}
+
+type appCondition struct {
+	Name string `json:"name" query:"name"`
+	Key  string `json:"key" query:"key"`
+	Page uint64 `json:"page" query:"page"`
+	Size uint64 `json:"size" query:"size"`
+}
+
+// Page 分页查询
+func (s *App) Page(ctx echo.Context) error {
+	var condition appCondition
+	if err := ctx.Bind(&condition); err != nil {
+		return err
+	}
+	pagination := model.NewPagination(condition.Page, condition.Size)
+	app := entity.App{
+		Name: condition.Name,
+		Key:  condition.Key,
+	}
+	c := ctx.(*middleware.Context).Ctx()
+	if err := s.srv.Page(c, &app, pagination); err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, model.OK(pagination))
+}
+
+// Rotate 轮换密钥，返回新的密钥、token
+func (s *App) Rotate(ctx echo.Context) error {
+	id := ctx.Param("id")
+	c := ctx.(*middleware.Context).Ctx()
+	credential, err := s.srv.Rotate(c, id)
+	if err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, model.OK(credential))
+}
+
+// Revoke 吊销
+func (s *App) Revoke(ctx echo.Context) error {
+	id := ctx.Param("id")
+	c := ctx.(*middleware.Context).Ctx()
+	app, err := s.srv.Revoke(c, id)
+	if err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, model.OK(app))
+}
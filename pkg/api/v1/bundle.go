@@ -0,0 +1,85 @@
+package v1
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/xuanbo/ohmydata/pkg/api/middleware"
+	"github.com/xuanbo/ohmydata/pkg/api/util"
+	"github.com/xuanbo/ohmydata/pkg/model"
+	"github.com/xuanbo/ohmydata/pkg/srv"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Bundle 导入导出API管理
+type Bundle struct {
+	srv *srv.Bundle
+}
+
+// NewBundle 创建
+func NewBundle(srv *srv.Bundle) *Bundle {
+	return &Bundle{srv}
+}
+
+// Init 初始化
+func (s *Bundle) Init() error {
+	return nil
+}
+
+// AddRoutes 添加路由
+func (s *Bundle) AddRoutes(e *echo.Echo) {
+	g := e.Group("/v1")
+	{
+		g.POST("/bundle/export", s.Export)
+		g.POST("/bundle/import", s.Import)
+	}
+}
+
+// Export 导出，format为yaml（默认）或json，返回文档内容
+func (s *Bundle) Export(ctx echo.Context) error {
+	var export srv.BundleExport
+	if err := ctx.Bind(&export); err != nil {
+		return err
+	}
+	c := ctx.(*middleware.Context).Ctx()
+	doc, err := s.srv.Export(c, &export)
+	if err != nil {
+		return err
+	}
+	format := ctx.QueryParam("format")
+	b, err := srv.EncodeBundle(doc, format)
+	if err != nil {
+		return err
+	}
+	contentType, filename := "application/x-yaml;charset=utf8", "ohmydata-bundle.yaml"
+	if format == srv.BundleJSON {
+		contentType, filename = "application/json;charset=utf8", "ohmydata-bundle.json"
+	}
+	ctx.Response().Header().Set(echo.HeaderContentDisposition, "attachment; filename="+filename)
+	return ctx.Blob(http.StatusOK, contentType, b)
+}
+
+// Import 导入，请求体为yaml或json文档，dryRun=true时仅返回变更，onConflict为fail（默认）、skip、overwrite
+func (s *Bundle) Import(ctx echo.Context) error {
+	b, err := util.ReadBody(ctx)
+	if err != nil {
+		return err
+	}
+	doc, err := srv.DecodeBundle(b)
+	if err != nil {
+		return err
+	}
+	opt := &srv.BundleImport{OnConflict: ctx.QueryParam("onConflict")}
+	if dryRun := ctx.QueryParam("dryRun"); dryRun != "" {
+		if opt.DryRun, err = strconv.ParseBool(dryRun); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "dryRun参数错误")
+		}
+	}
+	c := ctx.(*middleware.Context).Ctx()
+	result, err := s.srv.Import(c, doc, opt)
+	if err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, model.OK(result))
+}
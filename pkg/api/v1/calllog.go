@@ -0,0 +1,79 @@
+package v1
+
+import (
+	"net/http"
+
+	"github.com/xuanbo/ohmydata/pkg/api/middleware"
+	"github.com/xuanbo/ohmydata/pkg/entity"
+	"github.com/xuanbo/ohmydata/pkg/model"
+	"github.com/xuanbo/ohmydata/pkg/srv"
+
+	"github.com/labstack/echo/v4"
+)
+
+// CallLog API调用记录以及统计
+type CallLog struct {
+	srv *srv.CallLog
+}
+
+// NewCallLog 创建
+func NewCallLog(srv *srv.CallLog) *CallLog {
+	return &CallLog{srv}
+}
+
+// Init 初始化
+func (s *CallLog) Init() error {
+	// 异步写入调用记录
+	srv.SyncCallLog(s.srv)
+	return nil
+}
+
+// AddRoutes 添加路由
+func (s *CallLog) AddRoutes(e *echo.Echo) {
+	g := e.Group("/v1")
+	{
+		g.POST("/api-call/page", s.Page)
+		g.POST("/api-call/stats", s.Stats)
+	}
+}
+
+type apiCallCondition struct {
+	DataSetID string `json:"dataSetId" query:"dataSetId"`
+	Caller    string `json:"caller" query:"caller"`
+	Status    int    `json:"status" query:"status"`
+	Page      uint64 `json:"page" query:"page"`
+	Size      uint64 `json:"size" query:"size"`
+}
+
+// Page 分页查询调用记录
+func (s *CallLog) Page(ctx echo.Context) error {
+	var condition apiCallCondition
+	if err := ctx.Bind(&condition); err != nil {
+		return err
+	}
+	pagination := model.NewPagination(condition.Page, condition.Size)
+	call := entity.APICall{
+		DataSetID: condition.DataSetID,
+		Caller:    condition.Caller,
+		Status:    condition.Status,
+	}
+	c := ctx.(*middleware.Context).Ctx()
+	if err := s.srv.Page(c, &call, pagination); err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, model.OK(pagination))
+}
+
+// Stats 调用统计
+func (s *CallLog) Stats(ctx echo.Context) error {
+	var query srv.CallStatsQuery
+	if err := ctx.Bind(&query); err != nil {
+		return err
+	}
+	c := ctx.(*middleware.Context).Ctx()
+	stats, err := s.srv.Stats(c, &query)
+	if err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, model.OK(stats))
+}
@@ -1,11 +1,14 @@
 package v1
 
 import (
+	"context"
+	"encoding/json"
 	"net/http"
 	"strconv"
 	"strings"
 
 	"github.com/xuanbo/ohmydata/pkg/api/middleware"
+	"github.com/xuanbo/ohmydata/pkg/api/util"
 	"github.com/xuanbo/ohmydata/pkg/entity"
 	"github.com/xuanbo/ohmydata/pkg/model"
 	"github.com/xuanbo/ohmydata/pkg/srv"
@@ -40,17 +43,37 @@ func (s *DataSet) AddRoutes(e *echo.Echo) {
 		g.GET("/data-set/:id", s.ID)
 		g.GET("/data-set/:id/detail", s.Detail)
 		g.PUT("/data-set/:id/publish-status/:publishStatus", s.ChangePublishStatus)
+		g.POST("/data-set/:id/transition", s.Transition)
+		g.GET("/data-set/:id/reviews", s.Reviews)
+		g.GET("/data-set/:id/versions", s.Versions)
+		g.GET("/data-set/:id/versions/diff", s.DiffVersions)
+		g.GET("/data-set/:id/versions/:version", s.Version)
+		g.PUT("/data-set/:id/versions/:version/rollback", s.Rollback)
 		g.GET("/data-set/:id/doc", s.RenderAPIDoc)
+		g.GET("/data-set/:id/openapi.json", s.DataSetOpenAPI)
+		g.GET("/openapi.json", s.OpenAPI)
 		g.POST("/data-set/exp", s.ParseExpression)
 		g.POST("/data-set/preview", s.PreviewData)
+		g.POST("/data-set/preview/render", s.Render)
+		g.POST("/data-set/explain", s.Explain)
+		g.POST("/data-set/scaffold", s.Scaffold)
+		g.POST("/data-set/response-params/infer", s.InferResponseParams)
+		g.POST("/data-set/:id/response-params/diff", s.DiffResponseParams)
 		g.DELETE("/data-set/:id", s.Remove)
 		g.POST("/data-set/page", s.Page)
 		g.GET("/data-set/routes", s.APIRoutes)
 	}
 
 	// 数据集API
+	e.POST("/api/_batch", s.ServeBatch)
+	e.GET("/api/_apis", s.Available)
 	e.GET("/api/*", s.ServeAPI)
 	e.POST("/api/*", s.ServeAPI)
+	e.PUT("/api/*", s.ServeAPI)
+	e.DELETE("/api/*", s.ServeAPI)
+	// GraphQL
+	e.GET("/graphql", s.GraphQL)
+	e.POST("/graphql", s.GraphQL)
 }
 
 // Create 创建
@@ -126,6 +149,92 @@ func (s *DataSet) ChangePublishStatus(ctx echo.Context) error {
 	return ctx.JSON(http.StatusOK, model.OK(id))
 }
 
+// Transition 审核状态流转
+func (s *DataSet) Transition(ctx echo.Context) error {
+	id := ctx.Param("id")
+	var transition srv.Transition
+	if err := ctx.Bind(&transition); err != nil {
+		return err
+	}
+	c := ctx.(*middleware.Context).Ctx()
+	review, err := s.srv.Transition(c, id, &transition)
+	if err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, model.OK(review))
+}
+
+// Reviews 审核记录
+func (s *DataSet) Reviews(ctx echo.Context) error {
+	id := ctx.Param("id")
+	c := ctx.(*middleware.Context).Ctx()
+	list, err := s.srv.Reviews(c, id)
+	if err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, model.OK(list))
+}
+
+// Versions 版本列表
+func (s *DataSet) Versions(ctx echo.Context) error {
+	id := ctx.Param("id")
+	c := ctx.(*middleware.Context).Ctx()
+	list, err := s.srv.Versions(c, id)
+	if err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, model.OK(list))
+}
+
+// Version 版本快照
+func (s *DataSet) Version(ctx echo.Context) error {
+	id := ctx.Param("id")
+	version, err := strconv.ParseUint(ctx.Param("version"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "version必须为正整数")
+	}
+	c := ctx.(*middleware.Context).Ctx()
+	dataSet, err := s.srv.Version(c, id, uint(version))
+	if err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, model.OK(dataSet))
+}
+
+// DiffVersions 比较两个版本，参数from、to为版本号
+func (s *DataSet) DiffVersions(ctx echo.Context) error {
+	id := ctx.Param("id")
+	from, err := strconv.ParseUint(ctx.QueryParam("from"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "from必须为正整数")
+	}
+	to, err := strconv.ParseUint(ctx.QueryParam("to"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "to必须为正整数")
+	}
+	c := ctx.(*middleware.Context).Ctx()
+	diff, err := s.srv.DiffVersions(c, id, uint(from), uint(to))
+	if err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, model.OK(diff))
+}
+
+// Rollback 回滚到指定版本
+func (s *DataSet) Rollback(ctx echo.Context) error {
+	id := ctx.Param("id")
+	version, err := strconv.ParseUint(ctx.Param("version"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "version必须为正整数")
+	}
+	c := ctx.(*middleware.Context).Ctx()
+	dataSet, err := s.srv.Rollback(c, id, uint(version))
+	if err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, model.OK(dataSet))
+}
+
 type dataSetCondition struct {
 	Name string `json:"name" query:"name"`
 	Path string `json:"path" query:"path"`
@@ -162,6 +271,27 @@ func (s *DataSet) RenderAPIDoc(ctx echo.Context) error {
 	return ctx.JSON(http.StatusOK, model.OK(doc))
 }
 
+// OpenAPI 已发布数据集的OpenAPI 3文档，不使用统一响应格式，便于网关、代码生成工具导入
+func (s *DataSet) OpenAPI(ctx echo.Context) error {
+	c := ctx.(*middleware.Context).Ctx()
+	doc, err := s.srv.OpenAPI(c)
+	if err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, doc)
+}
+
+// DataSetOpenAPI 单个已发布数据集的OpenAPI 3文档
+func (s *DataSet) DataSetOpenAPI(ctx echo.Context) error {
+	id := ctx.Param("id")
+	c := ctx.(*middleware.Context).Ctx()
+	doc, err := s.srv.DataSetOpenAPI(c, id)
+	if err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, doc)
+}
+
 type dataSetExpressionCondition struct {
 	Expression string `json:"expression"`
 }
@@ -204,6 +334,92 @@ func (s *DataSet) PreviewData(ctx echo.Context) error {
 	return ctx.JSON(http.StatusOK, model.OK(v))
 }
 
+// Render 渲染表达式，不执行
+func (s *DataSet) Render(ctx echo.Context) error {
+	var condition dataSetPreviewCondition
+	if err := ctx.Bind(&condition); err != nil {
+		return err
+	}
+	if condition.DataSet == nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "dataSet必须存在")
+	}
+	if condition.Params == nil {
+		condition.Params = make(map[string]interface{})
+	}
+	c := ctx.(*middleware.Context).Ctx()
+	v, err := s.srv.Render(c, condition.DataSet, condition.Params)
+	if err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, model.OK(v))
+}
+
+// InferResponseParams 推断响应参数
+func (s *DataSet) InferResponseParams(ctx echo.Context) error {
+	var condition dataSetPreviewCondition
+	if err := ctx.Bind(&condition); err != nil {
+		return err
+	}
+	if condition.DataSet == nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "dataSet必须存在")
+	}
+	c := ctx.(*middleware.Context).Ctx()
+	list, err := s.srv.InferResponseParams(c, condition.DataSet, condition.Params)
+	if err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, model.OK(list))
+}
+
+// DiffResponseParams 推断响应参数并与已保存的响应参数比较，请求体为样例参数
+func (s *DataSet) DiffResponseParams(ctx echo.Context) error {
+	id := ctx.Param("id")
+	params := make(map[string]interface{})
+	if err := ctx.Bind(&params); err != nil {
+		return err
+	}
+	c := ctx.(*middleware.Context).Ctx()
+	diff, err := s.srv.DiffResponseParams(c, id, params)
+	if err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, model.OK(diff))
+}
+
+// Scaffold 根据表生成数据集
+func (s *DataSet) Scaffold(ctx echo.Context) error {
+	var scaffold srv.Scaffold
+	if err := ctx.Bind(&scaffold); err != nil {
+		return err
+	}
+	c := ctx.(*middleware.Context).Ctx()
+	list, err := s.srv.Scaffold(c, &scaffold)
+	if err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, model.OK(list))
+}
+
+// Explain 执行计划
+func (s *DataSet) Explain(ctx echo.Context) error {
+	var condition dataSetPreviewCondition
+	if err := ctx.Bind(&condition); err != nil {
+		return err
+	}
+	if condition.DataSet == nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "dataSet必须存在")
+	}
+	c := ctx.(*middleware.Context).Ctx()
+	v, err := s.srv.Explain(c, condition.DataSet, condition.Params)
+	if err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, model.OK(v))
+}
+
+// headerIdempotencyKey 写操作幂等请求头
+const headerIdempotencyKey = "Idempotency-Key"
+
 // ServeAPI 提供API服务
 func (s *DataSet) ServeAPI(ctx echo.Context) error {
 	var (
@@ -227,13 +443,63 @@ func (s *DataSet) ServeAPI(ctx echo.Context) error {
 	path := ctx.Request().URL.Path
 	path = strings.TrimPrefix(path, "/api/")
 	c := ctx.(*middleware.Context).Ctx()
-	pagination, err := s.srv.ServeAPI(c, path, params)
+	// 写操作幂等键
+	if key := ctx.Request().Header.Get(headerIdempotencyKey); key != "" {
+		c = context.WithValue(c, util.IdempotencyKey, key)
+	}
+	pagination, err := s.srv.ServeAPI(c, ctx.Request().Method, path, params)
 	if err != nil {
 		return err
 	}
 	return ctx.JSON(http.StatusOK, model.OK(pagination))
 }
 
+// ServeBatch 批量调用API，请求体为[{path, method, params}]，按顺序返回每个API的结果
+func (s *DataSet) ServeBatch(ctx echo.Context) error {
+	var calls []*srv.BatchCall
+	if err := ctx.Bind(&calls); err != nil {
+		return err
+	}
+	c := ctx.(*middleware.Context).Ctx()
+	results, err := s.srv.ServeBatch(c, calls)
+	if err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, model.OK(results))
+}
+
+// Available 调用者可调用的API
+func (s *DataSet) Available(ctx echo.Context) error {
+	c := ctx.(*middleware.Context).Ctx()
+	apis, err := s.srv.Available(c)
+	if err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, model.OK(apis))
+}
+
+// GraphQL 已发布数据集的GraphQL查询，响应为GraphQL标准格式，不使用统一响应格式
+func (s *DataSet) GraphQL(ctx echo.Context) error {
+	var req srv.GraphQLRequest
+	if ctx.Request().Method == http.MethodGet {
+		req.Query = ctx.QueryParam("query")
+		req.OperationName = ctx.QueryParam("operationName")
+		if variables := ctx.QueryParam("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &req.Variables); err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, "variables必须是JSON对象")
+			}
+		}
+	} else if err := ctx.Bind(&req); err != nil {
+		return err
+	}
+	c := ctx.(*middleware.Context).Ctx()
+	result, err := s.srv.GraphQL(c, &req)
+	if err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, result)
+}
+
 // APIRoutes 当前API路由
 func (s *DataSet) APIRoutes(ctx echo.Context) error {
 	return ctx.JSON(http.StatusOK, model.OK(s.srv.APIRoutes()))
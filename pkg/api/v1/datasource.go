@@ -42,9 +42,14 @@ func (s *DataSource) AddRoutes(e *echo.Echo) {
 
 		// 数据库操作
 		g.GET("/data-source/:id/tables", s.TableNames)
+		g.GET("/data-source/:id/table-metas", s.TableMetas)
 		g.GET("/data-source/:id/table", s.Table)
 		g.POST("/data-source/:id/data", s.QueryTable)
 		g.POST("/data-source/:id/query", s.Query)
+
+		// 运行中的查询
+		g.GET("/data-source/:id/running", s.Running)
+		g.DELETE("/data-source/:id/running/:runningId", s.Kill)
 	}
 }
 
@@ -118,6 +123,17 @@ func (s *DataSource) TableNames(ctx echo.Context) error {
 	return ctx.JSON(http.StatusOK, model.OK(list))
 }
 
+// TableMetas 查询数据源表及类型
+func (s *DataSource) TableMetas(ctx echo.Context) error {
+	id := ctx.Param("id")
+	c := ctx.(*middleware.Context).Ctx()
+	list, err := s.srv.TableMetas(c, id)
+	if err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, model.OK(list))
+}
+
 // Table 查询数据源表结构
 func (s *DataSource) Table(ctx echo.Context) error {
 	id := ctx.Param("id")
@@ -214,3 +230,21 @@ func (s *DataSource) Query(ctx echo.Context) error {
 	}
 	return ctx.JSON(http.StatusOK, model.OK(pagination))
 }
+
+// Running 运行中的查询
+func (s *DataSource) Running(ctx echo.Context) error {
+	id := ctx.Param("id")
+	c := ctx.(*middleware.Context).Ctx()
+	return ctx.JSON(http.StatusOK, model.OK(s.srv.Running(c, id)))
+}
+
+// Kill 终止运行中的查询
+func (s *DataSource) Kill(ctx echo.Context) error {
+	id := ctx.Param("id")
+	runningID := ctx.Param("runningId")
+	c := ctx.(*middleware.Context).Ctx()
+	if err := s.srv.Kill(c, id, runningID); err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, model.OK(runningID))
+}
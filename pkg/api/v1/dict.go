@@ -32,6 +32,10 @@ func (d *Dict) AddRoutes(e *echo.Echo) {
 		g.GET("/dict/param-locations", d.ParamLocations)
 		g.GET("/dict/param-types", d.ParamTypes)
 		g.GET("/dict/convert-types", d.ConvertTypes)
+		g.GET("/dict/data-set-kinds", d.DataSetKinds)
+		g.GET("/dict/data-set-states", d.DataSetStates)
+		g.GET("/dict/grant-subjects", d.GrantSubjects)
+		g.GET("/dict/rate-limit-scopes", d.RateLimitScopes)
 	}
 }
 
@@ -133,3 +137,105 @@ var convertTypes = []*model.Dict{
 func (d *Dict) ConvertTypes(ctx echo.Context) error {
 	return ctx.JSON(http.StatusOK, model.OK(convertTypes))
 }
+
+var dataSetKinds = []*model.Dict{
+	{
+		Name:  "query",
+		Text:  "查询",
+		Value: entity.KindQuery,
+	},
+	{
+		Name:  "write",
+		Text:  "写操作",
+		Value: entity.KindWrite,
+	},
+}
+
+// DataSetKinds 数据集类型
+func (d *Dict) DataSetKinds(ctx echo.Context) error {
+	return ctx.JSON(http.StatusOK, model.OK(dataSetKinds))
+}
+
+var dataSetStates = []*model.Dict{
+	{
+		Name:  "draft",
+		Text:  "草稿",
+		Value: entity.StateDraft,
+	},
+	{
+		Name:  "pending",
+		Text:  "待审核",
+		Value: entity.StatePending,
+	},
+	{
+		Name:  "approved",
+		Text:  "审核通过",
+		Value: entity.StateApproved,
+	},
+	{
+		Name:  "published",
+		Text:  "已发布",
+		Value: entity.StatePublished,
+	},
+	{
+		Name:  "deprecated",
+		Text:  "已弃用",
+		Value: entity.StateDeprecated,
+	},
+	{
+		Name:  "retired",
+		Text:  "已下线",
+		Value: entity.StateRetired,
+	},
+}
+
+// DataSetStates 数据集审核状态
+func (d *Dict) DataSetStates(ctx echo.Context) error {
+	return ctx.JSON(http.StatusOK, model.OK(dataSetStates))
+}
+
+var grantSubjects = []*model.Dict{
+	{
+		Name:  "user",
+		Text:  "用户",
+		Value: entity.SubjectUser,
+	},
+	{
+		Name:  "group",
+		Text:  "用户组",
+		Value: entity.SubjectGroup,
+	},
+	{
+		Name:  "app",
+		Text:  "应用",
+		Value: entity.SubjectApp,
+	},
+}
+
+// GrantSubjects 授权对象类型
+func (d *Dict) GrantSubjects(ctx echo.Context) error {
+	return ctx.JSON(http.StatusOK, model.OK(grantSubjects))
+}
+
+var rateLimitScopes = []*model.Dict{
+	{
+		Name:  "dataset",
+		Text:  "数据集",
+		Value: entity.ScopeDataSet,
+	},
+	{
+		Name:  "app",
+		Text:  "应用",
+		Value: entity.ScopeApp,
+	},
+	{
+		Name:  "ip",
+		Text:  "客户端IP",
+		Value: entity.ScopeIP,
+	},
+}
+
+// RateLimitScopes 限流维度
+func (d *Dict) RateLimitScopes(ctx echo.Context) error {
+	return ctx.JSON(http.StatusOK, model.OK(rateLimitScopes))
+}
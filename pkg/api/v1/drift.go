@@ -0,0 +1,67 @@
+package v1
+
+import (
+	"net/http"
+
+	"github.com/xuanbo/ohmydata/pkg/api/middleware"
+	"github.com/xuanbo/ohmydata/pkg/model"
+	"github.com/xuanbo/ohmydata/pkg/srv"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Drift 结构漂移API管理
+type Drift struct {
+	srv *srv.Drift
+}
+
+// NewDrift 创建
+func NewDrift(srv *srv.Drift) *Drift {
+	return &Drift{srv}
+}
+
+// Init 初始化
+func (s *Drift) Init() error {
+	// 定时检测结构漂移
+	srv.SyncDrift(s.srv)
+	return nil
+}
+
+// AddRoutes 添加路由
+func (s *Drift) AddRoutes(e *echo.Echo) {
+	g := e.Group("/v1")
+	{
+		g.POST("/drift-event/page", s.Page)
+		g.POST("/drift-event/check", s.Check)
+	}
+}
+
+type driftEventCondition struct {
+	DataSetID string `json:"dataSetId" query:"dataSetId"`
+	Page      uint64 `json:"page" query:"page"`
+	Size      uint64 `json:"size" query:"size"`
+}
+
+// Page 分页查询漂移事件
+func (s *Drift) Page(ctx echo.Context) error {
+	var condition driftEventCondition
+	if err := ctx.Bind(&condition); err != nil {
+		return err
+	}
+	pagination := model.NewPagination(condition.Page, condition.Size)
+	c := ctx.(*middleware.Context).Ctx()
+	if err := s.srv.Page(c, condition.DataSetID, pagination); err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, model.OK(pagination))
+}
+
+// Check 立即检测，返回新记录的漂移事件
+func (s *Drift) Check(ctx echo.Context) error {
+	c := ctx.(*middleware.Context).Ctx()
+	events, err := s.srv.Check(c)
+	if err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, model.OK(events))
+}
@@ -0,0 +1,51 @@
+package v1
+
+import (
+	"net/http"
+
+	"github.com/xuanbo/ohmydata/pkg/api/middleware"
+	"github.com/xuanbo/ohmydata/pkg/model"
+	"github.com/xuanbo/ohmydata/pkg/srv"
+
+	"github.com/labstack/echo/v4"
+)
+
+// GitOps 目录同步API管理
+type GitOps struct {
+	srv *srv.GitOps
+}
+
+// NewGitOps 创建
+func NewGitOps(srv *srv.GitOps) *GitOps {
+	return &GitOps{srv}
+}
+
+// Init 初始化
+func (s *GitOps) Init() error {
+	// 同步目录并监听变更
+	return srv.SyncGitOps(s.srv)
+}
+
+// AddRoutes 添加路由
+func (s *GitOps) AddRoutes(e *echo.Echo) {
+	g := e.Group("/v1")
+	{
+		g.GET("/gitops/status", s.Status)
+		g.POST("/gitops/reconcile", s.Reconcile)
+	}
+}
+
+// Status 最近一次同步结果以及错误
+func (s *GitOps) Status(ctx echo.Context) error {
+	return ctx.JSON(http.StatusOK, model.OK(s.srv.Status()))
+}
+
+// Reconcile 立即同步
+func (s *GitOps) Reconcile(ctx echo.Context) error {
+	c := ctx.(*middleware.Context).Ctx()
+	status, err := s.srv.Reconcile(c)
+	if err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, model.OK(status))
+}
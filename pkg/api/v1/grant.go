@@ -0,0 +1,195 @@
+package v1
+
+import (
+	"net/http"
+
+	"github.com/xuanbo/ohmydata/pkg/api/middleware"
+	"github.com/xuanbo/ohmydata/pkg/entity"
+	"github.com/xuanbo/ohmydata/pkg/model"
+	"github.com/xuanbo/ohmydata/pkg/srv"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Grant API授权管理
+type Grant struct {
+	srv *srv.Grant
+}
+
+// NewGrant 创建
+func NewGrant(srv *srv.Grant) *Grant {
+	return &Grant{srv}
+}
+
+// Init 初始化
+func (s *Grant) Init() error {
+	return nil
+}
+
+// AddRoutes 添加路由
+func (s *Grant) AddRoutes(e *echo.Echo) {
+	g := e.Group("/v1")
+	{
+		// 用户组
+		g.POST("/user-group", s.CreateGroup)
+		g.PUT("/user-group", s.ModifyGroup)
+		g.GET("/user-group/all", s.Groups)
+		g.DELETE("/user-group/:id", s.RemoveGroup)
+		g.GET("/user-group/:id/members", s.Members)
+		g.PUT("/user-group/:id/members", s.SetMembers)
+		// 授权
+		g.POST("/grant", s.Create)
+		g.DELETE("/grant/:id", s.Remove)
+		g.POST("/grant/page", s.Page)
+		// 审计事件
+		g.POST("/audit-event/page", s.AuditPage)
+	}
+}
+
+// CreateGroup 新增用户组
+func (s *Grant) CreateGroup(ctx echo.Context) error {
+	var group entity.UserGroup
+	if err := ctx.Bind(&group); err != nil {
+		return err
+	}
+	c := ctx.(*middleware.Context).Ctx()
+	if err := s.srv.CreateGroup(c, &group); err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, model.OK(group))
+}
+
+// ModifyGroup 修改用户组
+func (s *Grant) ModifyGroup(ctx echo.Context) error {
+	var group entity.UserGroup
+	if err := ctx.Bind(&group); err != nil {
+		return err
+	}
+	c := ctx.(*middleware.Context).Ctx()
+	if err := s.srv.ModifyGroup(c, &group); err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, model.OK(group))
+}
+
+// Groups 查询所有用户组
+func (s *Grant) Groups(ctx echo.Context) error {
+	c := ctx.(*middleware.Context).Ctx()
+	list, err := s.srv.Groups(c)
+	if err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, model.OK(list))
+}
+
+// RemoveGroup 删除用户组
+func (s *Grant) RemoveGroup(ctx echo.Context) error {
+	id := ctx.Param("id")
+	c := ctx.(*middleware.Context).Ctx()
+	if err := s.srv.RemoveGroup(c, id); err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, model.OK(id))
+}
+
+// Members 用户组成员
+func (s *Grant) Members(ctx echo.Context) error {
+	id := ctx.Param("id")
+	c := ctx.(*middleware.Context).Ctx()
+	list, err := s.srv.Members(c, id)
+	if err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, model.OK(list))
+}
+
+// SetMembers 设置用户组成员，请求体为用户ID列表
+func (s *Grant) SetMembers(ctx echo.Context) error {
+	id := ctx.Param("id")
+	var userIDs []string
+	if err := ctx.Bind(&userIDs); err != nil {
+		return err
+	}
+	c := ctx.(*middleware.Context).Ctx()
+	if err := s.srv.SetMembers(c, id, userIDs); err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, model.OK(userIDs))
+}
+
+// Create 新增授权
+func (s *Grant) Create(ctx echo.Context) error {
+	var grant entity.Grant
+	if err := ctx.Bind(&grant); err != nil {
+		return err
+	}
+	c := ctx.(*middleware.Context).Ctx()
+	if err := s.srv.Create(c, &grant); err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, model.OK(grant))
+}
+
+// Remove 删除授权
+func (s *Grant) Remove(ctx echo.Context) error {
+	id := ctx.Param("id")
+	c := ctx.(*middleware.Context).Ctx()
+	if err := s.srv.Remove(c, id); err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, model.OK(id))
+}
+
+type grantCondition struct {
+	SubjectType entity.GrantSubject `json:"subjectType" query:"subjectType"`
+	SubjectID   string              `json:"subjectId" query:"subjectId"`
+	DataSetID   string              `json:"dataSetId" query:"dataSetId"`
+	Page        uint64              `json:"page" query:"page"`
+	Size        uint64              `json:"size" query:"size"`
+}
+
+// Page 分页查询授权
+func (s *Grant) Page(ctx echo.Context) error {
+	var condition grantCondition
+	if err := ctx.Bind(&condition); err != nil {
+		return err
+	}
+	pagination := model.NewPagination(condition.Page, condition.Size)
+	grant := entity.Grant{
+		SubjectType: condition.SubjectType,
+		SubjectID:   condition.SubjectID,
+		DataSetID:   condition.DataSetID,
+	}
+	c := ctx.(*middleware.Context).Ctx()
+	if err := s.srv.Page(c, &grant, pagination); err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, model.OK(pagination))
+}
+
+type auditEventCondition struct {
+	Action    string `json:"action" query:"action"`
+	Caller    string `json:"caller" query:"caller"`
+	DataSetID string `json:"dataSetId" query:"dataSetId"`
+	Page      uint64 `json:"page" query:"page"`
+	Size      uint64 `json:"size" query:"size"`
+}
+
+// AuditPage 分页查询审计事件
+func (s *Grant) AuditPage(ctx echo.Context) error {
+	var condition auditEventCondition
+	if err := ctx.Bind(&condition); err != nil {
+		return err
+	}
+	pagination := model.NewPagination(condition.Page, condition.Size)
+	event := entity.AuditEvent{
+		Action:    condition.Action,
+		Caller:    condition.Caller,
+		DataSetID: condition.DataSetID,
+	}
+	c := ctx.(*middleware.Context).Ctx()
+	if err := s.srv.AuditPage(c, &event, pagination); err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, model.OK(pagination))
+}
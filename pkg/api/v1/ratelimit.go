@@ -0,0 +1,97 @@
+package v1
+
+import (
+	"net/http"
+
+	"github.com/xuanbo/ohmydata/pkg/api/middleware"
+	"github.com/xuanbo/ohmydata/pkg/entity"
+	"github.com/xuanbo/ohmydata/pkg/model"
+	"github.com/xuanbo/ohmydata/pkg/srv"
+
+	"github.com/labstack/echo/v4"
+)
+
+// RateLimit 限流以及配额管理
+type RateLimit struct {
+	srv *srv.RateLimiter
+}
+
+// NewRateLimit 创建
+func NewRateLimit(srv *srv.RateLimiter) *RateLimit {
+	return &RateLimit{srv}
+}
+
+// Init 初始化
+func (s *RateLimit) Init() error {
+	return nil
+}
+
+// AddRoutes 添加路由
+func (s *RateLimit) AddRoutes(e *echo.Echo) {
+	g := e.Group("/v1")
+	{
+		g.POST("/rate-limit", s.Create)
+		g.PUT("/rate-limit", s.Modify)
+		g.GET("/rate-limit/all", s.All)
+		g.GET("/rate-limit/usage", s.Usage)
+		g.DELETE("/rate-limit/:id", s.Remove)
+	}
+}
+
+// Create 创建
+func (s *RateLimit) Create(ctx echo.Context) error {
+	var limit entity.RateLimit
+	if err := ctx.Bind(&limit); err != nil {
+		return err
+	}
+	c := ctx.(*middleware.Context).Ctx()
+	if err := s.srv.Create(c, &limit); err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, model.OK(limit))
+}
+
+// Modify 更新
+func (s *RateLimit) Modify(ctx echo.Context) error {
+	var limit entity.RateLimit
+	if err := ctx.Bind(&limit); err != nil {
+		return err
+	}
+	c := ctx.(*middleware.Context).Ctx()
+	if err := s.srv.Modify(c, &limit); err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, model.OK(limit))
+}
+
+// Remove 删除
+func (s *RateLimit) Remove(ctx echo.Context) error {
+	id := ctx.Param("id")
+	c := ctx.(*middleware.Context).Ctx()
+	if err := s.srv.Remove(c, id); err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, model.OK(id))
+}
+
+// All 查询所有
+func (s *RateLimit) All(ctx echo.Context) error {
+	c := ctx.(*middleware.Context).Ctx()
+	list, err := s.srv.All(c)
+	if err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, model.OK(list))
+}
+
+// Usage 当前用量，参数scope、target
+func (s *RateLimit) Usage(ctx echo.Context) error {
+	scope := entity.RateLimitScope(ctx.QueryParam("scope"))
+	target := ctx.QueryParam("target")
+	c := ctx.(*middleware.Context).Ctx()
+	usage, err := s.srv.Usage(c, scope, target)
+	if err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, model.OK(usage))
+}
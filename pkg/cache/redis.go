@@ -50,6 +50,18 @@ func Set(ctx context.Context, key string, value interface{}, ttl time.Duration)
 	return client.Set(ctx, key, b, ttl).Err()
 }
 
+// SetNX 不存在时设置缓存，小于1s默认为1h
+func SetNX(ctx context.Context, key string, value interface{}, ttl time.Duration) (bool, error) {
+	if ttl < time.Second {
+		ttl = time.Hour
+	}
+	b, err := json.Marshal(value)
+	if err != nil {
+		return false, err
+	}
+	return client.SetNX(ctx, key, b, ttl).Result()
+}
+
 // Get 查询缓存
 func Get(ctx context.Context, key string, value interface{}) error {
 	b, err := client.Get(ctx, key).Bytes()
@@ -89,3 +101,8 @@ func DelMatch(ctx context.Context, match string) error {
 	}
 	return nil
 }
+
+// Run 执行lua脚本
+func Run(ctx context.Context, script *redis.Script, keys []string, args ...interface{}) (interface{}, error) {
+	return script.Run(ctx, client, keys, args...).Result()
+}
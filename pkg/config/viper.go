@@ -45,6 +45,16 @@ func GetInt(key string) int {
 	return internalViper.GetInt(key)
 }
 
+// GetBool 获取配置
+func GetBool(key string) bool {
+	return internalViper.GetBool(key)
+}
+
+// GetFloat64 获取配置
+func GetFloat64(key string) float64 {
+	return internalViper.GetFloat64(key)
+}
+
 // GetString 获取配置
 func GetString(key string) string {
 	return internalViper.GetString(key)
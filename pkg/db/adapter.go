@@ -2,6 +2,7 @@ package db
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 
@@ -14,6 +15,9 @@ var (
 	adapterFactories AdapterFactories
 )
 
+// ErrWriteNotAllowed 数据源未开启写操作
+var ErrWriteNotAllowed = errors.New("数据源未开启写操作")
+
 // Adapter 数据库适配层
 type Adapter interface {
 	// Ping 数据库是否连通
@@ -22,17 +26,56 @@ type Adapter interface {
 	Close() error
 	// TableNames 表名
 	TableNames(ctx context.Context) ([]string, error)
+	// TableMetas 表名及类型
+	TableMetas(ctx context.Context) ([]*TableMeta, error)
 	// Table 表结构
 	Table(ctx context.Context, name string) (*Table, error)
 	// QueryTable 查询表
 	QueryTable(ctx context.Context, tableName string, page *model.Pagination) error
-	// Query 数据库查询
-	Query(ctx context.Context, exp string, page *model.Pagination) error
+	// Query 数据库查询，args为绑定参数
+	Query(ctx context.Context, exp string, page *model.Pagination, args ...interface{}) error
+	// Exec 事务中执行写操作（INSERT、UPDATE、DELETE），数据源需开启写操作
+	Exec(ctx context.Context, exp string, args ...interface{}) (*ExecResult, error)
+}
+
+// Paginator 分页语句，适配层按需实现，用于展示实际发送到数据库的语句
+type Paginator interface {
+	// PageSQL 分页包装后的查询语句以及计数语句，不查询总数时计数语句为空
+	PageSQL(exp string, page *model.Pagination) (pageSQL string, countSQL string)
+}
+
+// ExecResult 写操作结果
+type ExecResult struct {
+	// 影响行数
+	RowsAffected int64 `json:"rowsAffected"`
+	// 生成的主键
+	GeneratedKeys []interface{} `json:"generatedKeys"`
+}
+
+// 表类型
+const (
+	// KindTable 表
+	KindTable = "TABLE"
+	// KindView 视图
+	KindView = "VIEW"
+	// KindIndex 索引，ElasticSearch
+	KindIndex = "INDEX"
+	// KindAlias 别名，ElasticSearch
+	KindAlias = "ALIAS"
+	// KindDataStream 数据流，ElasticSearch
+	KindDataStream = "DATA_STREAM"
+)
+
+// TableMeta 表名及类型
+type TableMeta struct {
+	Name string `json:"name"`
+	Kind string `json:"kind"`
 }
 
 // Table 表
 type Table struct {
 	Name    string    `json:"name"`
+	Kind    string    `json:"kind,omitempty"`
 	Columns []*Column `json:"columns"`
 }
 
@@ -43,6 +86,19 @@ type Column struct {
 	Length   int64  `json:"length"`
 	Scale    int64  `json:"scale"`
 	Nullable bool   `json:"nullable"`
+	// 是否主键
+	PrimaryKey bool `json:"primaryKey"`
+	// 主键是否由数据库生成（自增、标识列、默认值），新增时可不传
+	Generated bool `json:"generated"`
+	// 格式，如ElasticSearch日期字段的format
+	Format string `json:"format,omitempty"`
+}
+
+// PrimaryKey 主键字段，适配层查询表结构时使用
+type PrimaryKey struct {
+	Name string
+	// 是否由数据库生成
+	Generated bool
 }
 
 // AdapterFactory 数据库适配层工厂
@@ -101,6 +157,8 @@ type Adapters interface {
 	Put(id string, adapter Adapter) error
 	// Del 删除
 	Del(id string) error
+	// Range 遍历
+	Range(fn func(id string, adapter Adapter))
 }
 
 // MemoryAdapters 数据库适配层管理，基于内存管理
@@ -144,6 +202,15 @@ func (m *MemoryAdapters) Del(id string) error {
 	return adapter.Close()
 }
 
+// Range 遍历，遍历期间持有读锁，fn中不能增删适配层
+func (m *MemoryAdapters) Range(fn func(id string, adapter Adapter)) {
+	m.RLock()
+	defer m.RUnlock()
+	for id, adapter := range m.store {
+		fn(id, adapter)
+	}
+}
+
 // Init 初始化
 func init() {
 	adapters = &MemoryAdapters{
@@ -0,0 +1,65 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/xuanbo/ohmydata/pkg/entity"
+	"github.com/xuanbo/ohmydata/pkg/strutil"
+)
+
+// Prober 查询结果字段，适配层按需实现，不读取数据
+type Prober interface {
+	// Columns 查询结果的字段，args为绑定参数
+	Columns(ctx context.Context, exp string, args ...interface{}) ([]*Column, error)
+}
+
+// NewColumns 根据驱动返回的字段类型创建字段
+func NewColumns(types []*sql.ColumnType) []*Column {
+	columns := make([]*Column, len(types))
+	for i, columnType := range types {
+		column := &Column{
+			Name: columnType.Name(),
+			Type: columnType.DatabaseTypeName(),
+		}
+		columns[i] = column
+
+		if length, ok := columnType.Length(); ok {
+			column.Length = length
+		}
+		if length, scale, ok := columnType.DecimalSize(); ok {
+			column.Length = length
+			column.Scale = scale
+		}
+		if nullable, ok := columnType.Nullable(); ok {
+			column.Nullable = nullable
+		}
+	}
+	return columns
+}
+
+// ParamType 字段类型映射为参数类型，无法识别时为字符串
+func (c *Column) ParamType() entity.ParamType {
+	t := strings.TrimPrefix(strings.ToUpper(c.Type), "UNSIGNED ")
+	switch {
+	// PostgreSQL数组类型以_开头，如_INT4
+	case strings.HasPrefix(t, "_") || strings.HasSuffix(t, "[]") || t == "ARRAY" || t == "NESTED":
+		return entity.Array
+	case strutil.EqualAny(t, []string{"BOOL", "BOOLEAN"}):
+		return entity.Boolean
+	case strutil.EqualAny(t, []string{"TINYINT", "SMALLINT", "MEDIUMINT", "INT", "INTEGER", "INT2", "INT4", "BYTE", "SHORT"}):
+		return entity.Int
+	case strutil.EqualAny(t, []string{"BIGINT", "INT8", "LONG"}):
+		return entity.Long
+	case strutil.EqualAny(t, []string{"FLOAT", "FLOAT4", "REAL", "HALF_FLOAT"}):
+		return entity.Float
+	case strutil.EqualAny(t, []string{"DOUBLE", "FLOAT8", "DECIMAL", "NUMERIC", "SCALED_FLOAT"}):
+		return entity.Double
+	case strutil.EqualAny(t, []string{"DATE", "DATETIME", "TIMESTAMP", "TIMESTAMPTZ", "TIME", "TIMETZ", "YEAR"}):
+		return entity.DateTime
+	case strutil.EqualAny(t, []string{"JSON", "JSONB", "OBJECT"}):
+		return entity.Object
+	}
+	return entity.String
+}
@@ -0,0 +1,40 @@
+package db_test
+
+import (
+	"testing"
+
+	"github.com/xuanbo/ohmydata/pkg/db"
+	"github.com/xuanbo/ohmydata/pkg/entity"
+)
+
+func TestColumnParamType(t *testing.T) {
+	cases := map[string]entity.ParamType{
+		"VARCHAR":         entity.String,
+		"TEXT":            entity.String,
+		"keyword":         entity.String,
+		"BOOL":            entity.Boolean,
+		"boolean":         entity.Boolean,
+		"INT":             entity.Int,
+		"INT4":            entity.Int,
+		"UNSIGNED INT":    entity.Int,
+		"BIGINT":          entity.Long,
+		"UNSIGNED BIGINT": entity.Long,
+		"long":            entity.Long,
+		"FLOAT4":          entity.Float,
+		"DECIMAL":         entity.Double,
+		"NUMERIC":         entity.Double,
+		"DATETIME":        entity.DateTime,
+		"TIMESTAMPTZ":     entity.DateTime,
+		"datetime":        entity.DateTime,
+		"JSONB":           entity.Object,
+		"object":          entity.Object,
+		"_INT4":           entity.Array,
+		"nested":          entity.Array,
+	}
+	for columnType, paramType := range cases {
+		column := &db.Column{Name: "c", Type: columnType}
+		if got := column.ParamType(); got != paramType {
+			t.Errorf("%s: got %d, want %d", columnType, got, paramType)
+		}
+	}
+}
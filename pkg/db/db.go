@@ -20,8 +20,18 @@ var (
 	node   *snowflake.Node
 )
 
-// Init 初始化数据库
+// Init 初始化数据库并同步表结构
 func Init() error {
+	if err := Connect(); err != nil {
+		return err
+	}
+
+	// 同步表结构
+	return syncDB()
+}
+
+// Connect 连接数据库，不同步表结构
+func Connect() error {
 	url := config.GetString("mysql.url")
 	maxIdleConns := config.GetInt("mysql.maxIdleConns")
 	maxOpenConns := config.GetInt("mysql.maxOpenConns")
@@ -54,12 +64,6 @@ func Init() error {
 	if err != nil {
 		return err
 	}
-
-	// 同步表结构
-	if err := syncDB(); err != nil {
-		return err
-	}
-
 	return nil
 }
 
@@ -79,6 +83,11 @@ func syncDB() error {
 		&entity.User{},
 		&entity.DataSource{}, &entity.DataSet{},
 		&entity.RequestParam{}, &entity.ResponseParam{},
+		&entity.DataSetVersion{}, &entity.DataSetReview{},
+		&entity.DriftEvent{}, &entity.App{},
+		&entity.UserGroup{}, &entity.UserGroupMember{},
+		&entity.Grant{}, &entity.AuditEvent{},
+		&entity.RateLimit{}, &entity.APICall{},
 	); err != nil {
 		return err
 	}
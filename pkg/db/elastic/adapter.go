@@ -5,20 +5,30 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"sort"
 	"strings"
 
 	"github.com/xuanbo/ohmydata/pkg/db"
 	"github.com/xuanbo/ohmydata/pkg/entity"
 	"github.com/xuanbo/ohmydata/pkg/log"
 	"github.com/xuanbo/ohmydata/pkg/model"
+	"github.com/xuanbo/ohmydata/pkg/strutil"
 
 	elasticsearch "github.com/elastic/go-elasticsearch/v7"
 	"github.com/elastic/go-elasticsearch/v7/esapi"
 	"go.uber.org/zap"
 )
 
-// ErrNil 未初始化
-var ErrNil = errors.New("elastic: es client nil")
+var (
+	// ErrNil 未初始化
+	ErrNil = errors.New("elastic: es client nil")
+	// ErrExecNotSupported 不支持写操作
+	ErrExecNotSupported = errors.New("elastic: exec not supported")
+)
+
+// 查询响应中字段、游标等元数据预留的字节数
+const responseOverhead = 64 * 1024
 
 // adapter MySQL实现
 type adapter struct {
@@ -48,60 +58,125 @@ func (a *adapter) TableNames(ctx context.Context) ([]string, error) {
 	if a.es == nil {
 		return nil, ErrNil
 	}
-	// 执行SQL查询
-	var sqlQuery esapi.SQLQuery
-	resp, err := a.es.SQL.Query(strings.NewReader(`{"query": "show tables"}`), sqlQuery.WithContext(ctx))
+	tableMetas, err := a.TableMetas(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tableNames := make([]string, len(tableMetas))
+	for i, tableMeta := range tableMetas {
+		tableNames[i] = tableMeta.Name
+	}
+	return tableNames, nil
+}
+
+func (a *adapter) TableMetas(ctx context.Context) ([]*db.TableMeta, error) {
+	if a.es == nil {
+		return nil, ErrNil
+	}
+	tableMetas := make([]*db.TableMeta, 0, 16)
+
+	// 索引，忽略隐藏索引以及数据流的backing index（.ds-开头）
+	var catIndices esapi.CatIndices
+	resp, err := a.es.Cat.Indices(
+		catIndices.WithContext(ctx),
+		catIndices.WithFormat("json"),
+		catIndices.WithH("index"),
+		catIndices.WithS("index"),
+	)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
-	// 解析body
-	v, err := a.parseBody(resp)
+	var indices []struct {
+		Index string `json:"index"`
+	}
+	if err := a.decodeBody(resp, &indices); err != nil {
+		return nil, err
+	}
+	for _, e := range indices {
+		if strings.HasPrefix(e.Index, ".") {
+			continue
+		}
+		tableMetas = append(tableMetas, &db.TableMeta{Name: e.Index, Kind: db.KindIndex})
+	}
+
+	// 别名
+	var getAlias esapi.IndicesGetAlias
+	resp, err = a.es.Indices.GetAlias(getAlias.WithContext(ctx))
 	if err != nil {
 		return nil, err
 	}
-	// 提取表名
-	rows := v["rows"].([]interface{})
-	tableNames := make([]string, 0, 8)
-	for _, row := range rows {
-		e := row.([]interface{})
-		if "BASE TABLE" == e[1].(string) {
-			tableNames = append(tableNames, e[0].(string))
+	defer resp.Body.Close()
+	var indexAliases map[string]struct {
+		Aliases map[string]interface{} `json:"aliases"`
+	}
+	if err := a.decodeBody(resp, &indexAliases); err != nil {
+		return nil, err
+	}
+	aliases := make([]string, 0, 8)
+	for _, e := range indexAliases {
+		for alias := range e.Aliases {
+			if strings.HasPrefix(alias, ".") || strutil.EqualAny(alias, aliases) {
+				continue
+			}
+			aliases = append(aliases, alias)
 		}
 	}
-	return tableNames, nil
+	sort.Strings(aliases)
+	for _, alias := range aliases {
+		tableMetas = append(tableMetas, &db.TableMeta{Name: alias, Kind: db.KindAlias})
+	}
+
+	// 数据流，7.9以下版本不支持，忽略错误
+	dataStreams, err := a.dataStreams(ctx)
+	if err != nil {
+		log.Logger().Debug("查询数据流错误", zap.Error(err))
+	}
+	for _, dataStream := range dataStreams {
+		tableMetas = append(tableMetas, &db.TableMeta{Name: dataStream, Kind: db.KindDataStream})
+	}
+	return tableMetas, nil
 }
 
 func (a *adapter) Table(ctx context.Context, name string) (*db.Table, error) {
 	if a.es == nil {
 		return nil, ErrNil
 	}
-	// 执行SQL查询
+	// 查询mapping
 	log.Logger().Debug("查询表结构", zap.String("table", name))
-	body := fmt.Sprintf(`{"query": "desc %s"}`, "\\\""+name+"\\\"")
-	var sqlQuery esapi.SQLQuery
-	resp, err := a.es.SQL.Query(strings.NewReader(body), sqlQuery.WithContext(ctx))
+	var getMapping esapi.IndicesGetMapping
+	resp, err := a.es.Indices.GetMapping(getMapping.WithContext(ctx), getMapping.WithIndex(name))
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
-	// 解析body
-	v, err := a.parseBody(resp)
-	if err != nil {
+	var mappings map[string]struct {
+		Mappings struct {
+			Properties map[string]*property `json:"properties"`
+		} `json:"mappings"`
+	}
+	if err := a.decodeBody(resp, &mappings); err != nil {
 		return nil, err
 	}
-	// 提取表结构
-	rows := v["rows"].([]interface{})
+
+	// 别名、数据流对应多个索引，按索引名排序后合并字段
+	indexNames := make([]string, 0, len(mappings))
+	for indexName := range mappings {
+		indexNames = append(indexNames, indexName)
+	}
+	sort.Strings(indexNames)
 	table := &db.Table{
 		Name:    name,
+		Kind:    tableKind(name, indexNames),
 		Columns: make([]*db.Column, 0, 10),
 	}
-	for _, row := range rows {
-		e := row.([]interface{})
-		table.Columns = append(table.Columns, &db.Column{
-			Name: e[0].(string),
-			Type: e[2].(string),
-		})
+	for _, indexName := range indexNames {
+		columns := appendColumns(nil, "", mappings[indexName].Mappings.Properties)
+		for _, column := range columns {
+			if !containsColumn(table.Columns, column.Name) {
+				table.Columns = append(table.Columns, column)
+			}
+		}
 	}
 	return table, nil
 }
@@ -116,7 +191,7 @@ func (a *adapter) QueryTable(ctx context.Context, tableName string, page *model.
 	// 执行SQL查询
 	log.Logger().Debug("查询表数据", zap.String("table", tableName))
 	body := fmt.Sprintf(`{"query": "SELECT * FROM %s LIMIT %d"}`, "\\\""+tableName+"\\\"", page.Size)
-	list, err := a.doQuery(ctx, body)
+	list, err := a.doQuery(ctx, body, 0)
 	if err != nil {
 		return err
 	}
@@ -124,7 +199,7 @@ func (a *adapter) QueryTable(ctx context.Context, tableName string, page *model.
 	return nil
 }
 
-func (a *adapter) Query(ctx context.Context, exp string, page *model.Pagination) error {
+func (a *adapter) Query(ctx context.Context, exp string, page *model.Pagination, args ...interface{}) error {
 	if a.es == nil {
 		return ErrNil
 	}
@@ -132,29 +207,168 @@ func (a *adapter) Query(ctx context.Context, exp string, page *model.Pagination)
 		page.Size = 10
 	}
 	// 执行SQL查询
-	log.Logger().Debug("查询SQL", zap.String("sql", exp))
-	body := fmt.Sprintf(`{"query": "SELECT * FROM (%s) TMP_PAGE LIMIT %d"}`, exp, page.Size)
-	list, err := a.doQuery(ctx, body)
+	log.Logger().Debug("查询SQL", zap.String("sql", exp), zap.Any("args", args))
+	pageSQL, _ := a.PageSQL(exp, page)
+	body := map[string]interface{}{
+		"query": pageSQL,
+	}
+	// 绑定参数，?占位
+	if len(args) > 0 {
+		body["params"] = args
+	}
+	b, err := json.Marshal(body)
 	if err != nil {
 		return err
 	}
+	list, err := a.doQuery(ctx, string(b), page.MaxBytes)
+	if err != nil {
+		return err
+	}
+	if err := checkLimit(list, page); err != nil {
+		return err
+	}
 	page.Set(uint64(len(list)), list)
 	return nil
 }
 
+// Explain 通过SQL translate API翻译为DSL，ElasticSearch无扫描行数预估
+func (a *adapter) Explain(ctx context.Context, exp string, args ...interface{}) (*db.Plan, error) {
+	if a.es == nil {
+		return nil, ErrNil
+	}
+	body := map[string]interface{}{
+		"query": exp,
+	}
+	if len(args) > 0 {
+		body["params"] = args
+	}
+	b, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	var sqlTranslate esapi.SQLTranslate
+	resp, err := a.es.SQL.Translate(strings.NewReader(string(b)), sqlTranslate.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var raw json.RawMessage
+	if err := a.decodeBody(resp, &raw); err != nil {
+		return nil, err
+	}
+	return &db.Plan{Format: db.PlanElasticDSL, Raw: raw}, nil
+}
+
+// Columns 以LIMIT 0执行SQL查询，读取返回的字段
+func (a *adapter) Columns(ctx context.Context, exp string, args ...interface{}) ([]*db.Column, error) {
+	if a.es == nil {
+		return nil, ErrNil
+	}
+	body := map[string]interface{}{
+		"query": fmt.Sprintf("SELECT * FROM (%s) TMP_PROBE LIMIT 0", exp),
+	}
+	if len(args) > 0 {
+		body["params"] = args
+	}
+	b, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	var sqlQuery esapi.SQLQuery
+	resp, err := a.es.SQL.Query(strings.NewReader(string(b)), sqlQuery.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var v struct {
+		Columns []struct {
+			Name string `json:"name"`
+			Type string `json:"type"`
+		} `json:"columns"`
+	}
+	if err := a.decodeBody(resp, &v); err != nil {
+		return nil, err
+	}
+	columns := make([]*db.Column, 0, len(v.Columns))
+	for _, e := range v.Columns {
+		columns = append(columns, &db.Column{Name: e.Name, Type: e.Type, Nullable: true})
+	}
+	return columns, nil
+}
+
+// PageSQL 限制条数的查询语句，ElasticSearch SQL不支持计数与偏移
+func (a *adapter) PageSQL(exp string, page *model.Pagination) (string, string) {
+	size := page.Size
+	if size < 1 {
+		size = 10
+	}
+	return fmt.Sprintf("SELECT * FROM (%s) TMP_PAGE LIMIT %d", exp, size), ""
+}
+
+func (a *adapter) Exec(ctx context.Context, exp string, args ...interface{}) (*db.ExecResult, error) {
+	return nil, ErrExecNotSupported
+}
+
 func (a *adapter) parseBody(resp *esapi.Response) (map[string]interface{}, error) {
 	var v map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&v); err != nil {
-		return nil, fmt.Errorf("Error parsing the response body: %w", err)
+	if err := a.decodeBody(resp, &v); err != nil {
+		return nil, err
 	}
+	return v, nil
+}
+
+func (a *adapter) decodeBody(resp *esapi.Response, v interface{}) error {
 	if resp.IsError() {
-		reason := v["error"].(map[string]interface{})["reason"]
-		return nil, errors.New(reason.(string))
+		// error可能为对象或者字符串
+		var e struct {
+			Error json.RawMessage `json:"error"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&e); err != nil {
+			return fmt.Errorf("Error parsing the response body: %w", err)
+		}
+		var reason struct {
+			Reason string `json:"reason"`
+		}
+		if err := json.Unmarshal(e.Error, &reason); err == nil && reason.Reason != "" {
+			return errors.New(reason.Reason)
+		}
+		var message string
+		if err := json.Unmarshal(e.Error, &message); err == nil && message != "" {
+			return errors.New(message)
+		}
+		return errors.New(resp.Status())
 	}
-	return v, nil
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("Error parsing the response body: %w", err)
+	}
+	return nil
+}
+
+func (a *adapter) dataStreams(ctx context.Context) ([]string, error) {
+	var getDataStream esapi.IndicesGetDataStream
+	resp, err := a.es.Indices.GetDataStream(getDataStream.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var v struct {
+		DataStreams []struct {
+			Name string `json:"name"`
+		} `json:"data_streams"`
+	}
+	if err := a.decodeBody(resp, &v); err != nil {
+		return nil, err
+	}
+	dataStreams := make([]string, 0, len(v.DataStreams))
+	for _, e := range v.DataStreams {
+		dataStreams = append(dataStreams, e.Name)
+	}
+	sort.Strings(dataStreams)
+	return dataStreams, nil
 }
 
-func (a *adapter) doQuery(ctx context.Context, body string) ([]map[string]interface{}, error) {
+// doQuery 执行SQL查询，maxBytes大于0时限制读取的响应体大小
+func (a *adapter) doQuery(ctx context.Context, body string, maxBytes uint64) ([]map[string]interface{}, error) {
 	// 执行
 	var sqlQuery esapi.SQLQuery
 	resp, err := a.es.SQL.Query(strings.NewReader(body), sqlQuery.WithContext(ctx))
@@ -162,6 +376,10 @@ func (a *adapter) doQuery(ctx context.Context, body string) ([]map[string]interf
 		return nil, err
 	}
 	defer resp.Body.Close()
+	// 响应中的行为数组，小于按行JSON序列化的字节数，超过限制加上字段等元数据的大小时必然超限
+	if maxBytes > 0 && !resp.IsError() {
+		resp.Body = &limitReader{ReadCloser: resp.Body, n: int64(maxBytes + responseOverhead)}
+	}
 	// 解析body
 	v, err := a.parseBody(resp)
 	if err != nil {
@@ -189,6 +407,107 @@ func (a *adapter) doQuery(ctx context.Context, body string) ([]map[string]interf
 	return list, nil
 }
 
+// property mapping字段定义
+type property struct {
+	Type   string `json:"type"`
+	Format string `json:"format"`
+	// 对象、嵌套对象的子字段
+	Properties map[string]*property `json:"properties"`
+	// 多字段，如text类型的keyword子字段
+	Fields map[string]*property `json:"fields"`
+}
+
+// appendColumns 展开mapping字段，对象、嵌套对象以及多字段均以.拼接路径
+func appendColumns(columns []*db.Column, prefix string, properties map[string]*property) []*db.Column {
+	names := make([]string, 0, len(properties))
+	for name := range properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		p := properties[name]
+		path := prefix + name
+		fieldType := p.Type
+		if fieldType == "" && len(p.Properties) > 0 {
+			fieldType = "object"
+		}
+		columns = append(columns, &db.Column{
+			Name:     path,
+			Type:     fieldType,
+			Nullable: true,
+			Format:   p.Format,
+		})
+		columns = appendColumns(columns, path+".", p.Fields)
+		columns = appendColumns(columns, path+".", p.Properties)
+	}
+	return columns
+}
+
+func containsColumn(columns []*db.Column, name string) bool {
+	for _, column := range columns {
+		if column.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// tableKind 根据mapping返回的索引名推断类型
+func tableKind(name string, indexNames []string) string {
+	if len(indexNames) == 0 || strings.ContainsAny(name, "*,") {
+		return ""
+	}
+	if len(indexNames) == 1 && indexNames[0] == name {
+		return db.KindIndex
+	}
+	for _, indexName := range indexNames {
+		if !strings.HasPrefix(indexName, ".ds-"+name+"-") {
+			return db.KindAlias
+		}
+	}
+	return db.KindDataStream
+}
+
+// limitReader 读取超过n字节时返回model.ErrMaxBytes，避免超限的响应全部读入内存
+type limitReader struct {
+	io.ReadCloser
+	n int64
+}
+
+func (r *limitReader) Read(p []byte) (int, error) {
+	if r.n < 0 {
+		return 0, model.ErrMaxBytes
+	}
+	// 多读1字节以判断是否超限
+	if int64(len(p)) > r.n+1 {
+		p = p[:r.n+1]
+	}
+	n, err := r.ReadCloser.Read(p)
+	r.n -= int64(n)
+	if r.n < 0 {
+		return 0, model.ErrMaxBytes
+	}
+	return n, err
+}
+
+// checkLimit 校验行数、字节数（按行JSON序列化累计）是否超过分页对象的限制
+func checkLimit(list []map[string]interface{}, page *model.Pagination) error {
+	var bytes uint64
+	for i, row := range list {
+		if page.MaxBytes > 0 {
+			b, err := json.Marshal(row)
+			if err != nil {
+				return err
+			}
+			bytes += uint64(len(b))
+		}
+		if err := page.Check(uint64(i+1), bytes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // adapter MySQL实现
 type adapterFactory struct {
 }
@@ -0,0 +1,109 @@
+package elastic
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/xuanbo/ohmydata/pkg/db"
+	"github.com/xuanbo/ohmydata/pkg/model"
+)
+
+func TestAppendColumns(t *testing.T) {
+	cases := []struct {
+		name    string
+		mapping string
+		columns []string
+		types   []string
+	}{
+		{"empty", `{}`, []string{}, []string{}},
+		{"sorted", `{"name": {"type": "keyword"}, "age": {"type": "integer"}}`, []string{"age", "name"}, []string{"integer", "keyword"}},
+		{
+			"multi fields",
+			`{"title": {"type": "text", "fields": {"keyword": {"type": "keyword"}}}}`,
+			[]string{"title", "title.keyword"},
+			[]string{"text", "keyword"},
+		},
+		{
+			"object",
+			`{"user": {"properties": {"name": {"type": "keyword"}, "address": {"properties": {"city": {"type": "keyword"}}}}}}`,
+			[]string{"user", "user.address", "user.address.city", "user.name"},
+			[]string{"object", "object", "keyword", "keyword"},
+		},
+		{
+			"nested",
+			`{"tags": {"type": "nested", "properties": {"value": {"type": "keyword"}}}}`,
+			[]string{"tags", "tags.value"},
+			[]string{"nested", "keyword"},
+		},
+	}
+	for _, c := range cases {
+		var properties map[string]*property
+		if err := json.Unmarshal([]byte(c.mapping), &properties); err != nil {
+			t.Fatal(err)
+		}
+		columns := appendColumns(make([]*db.Column, 0), "", properties)
+		names := make([]string, len(columns))
+		types := make([]string, len(columns))
+		for i, column := range columns {
+			names[i], types[i] = column.Name, column.Type
+			if !column.Nullable {
+				t.Errorf("%s: %s should be nullable", c.name, column.Name)
+			}
+		}
+		if !reflect.DeepEqual(names, c.columns) || !reflect.DeepEqual(types, c.types) {
+			t.Errorf("%s: expected %v %v, actual: %v %v", c.name, c.columns, c.types, names, types)
+		}
+	}
+}
+
+func TestTableKind(t *testing.T) {
+	cases := []struct {
+		name       string
+		indexNames []string
+		kind       string
+	}{
+		{"user", nil, ""},
+		{"user", []string{"user"}, db.KindIndex},
+		{"user-*", []string{"user-1", "user-2"}, ""},
+		{"user,order", []string{"user", "order"}, ""},
+		{"user", []string{"user-v1"}, db.KindAlias},
+		{"user", []string{"user-v1", "user-v2"}, db.KindAlias},
+		{"logs", []string{".ds-logs-2021.01.01-000001", ".ds-logs-2021.01.02-000002"}, db.KindDataStream},
+		// 指向其他数据流的别名
+		{"logs", []string{".ds-logs-2021.01.01-000001", ".ds-events-2021.01.01-000001"}, db.KindAlias},
+	}
+	for _, c := range cases {
+		if kind := tableKind(c.name, c.indexNames); kind != c.kind {
+			t.Errorf("%s %v: expected %q, actual: %q", c.name, c.indexNames, c.kind, kind)
+		}
+	}
+}
+
+func TestLimitReader(t *testing.T) {
+	cases := []struct {
+		body  string
+		n     int64
+		err   error
+		bytes int
+	}{
+		{"", 0, nil, 0},
+		{"hello", 5, nil, 5},
+		{"hello", 10, nil, 5},
+		{"hello", 4, model.ErrMaxBytes, 0},
+		{"hello", 0, model.ErrMaxBytes, 0},
+	}
+	for _, c := range cases {
+		r := &limitReader{ReadCloser: ioutil.NopCloser(strings.NewReader(c.body)), n: c.n}
+		b, err := ioutil.ReadAll(r)
+		if err != c.err {
+			t.Errorf("%q limit %d: expected %v, actual: %v", c.body, c.n, c.err, err)
+			continue
+		}
+		if c.err == nil && len(b) != c.bytes {
+			t.Errorf("%q limit %d: expected %d bytes, actual: %d", c.body, c.n, c.bytes, len(b))
+		}
+	}
+}
@@ -74,6 +74,37 @@ func TestTableNames(t *testing.T) {
 	t.Logf("tableNames: %s", tableNames)
 }
 
+func TestTableMetas(t *testing.T) {
+	adapterFactory, err := db.GetAdapterFactory("elastic")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	dataSource := &entity.DataSource{
+		URL:      "http://192.168.101.32:9200",
+		Username: "elastic",
+		Password: "123456",
+	}
+	adapter, err := adapterFactory.Create(dataSource)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer adapter.Close()
+
+	tableMetas, err := adapter.TableMetas(context.TODO())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	b, err := json.Marshal(tableMetas)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	t.Logf("tableMetas: %s", string(b))
+}
+
 func TestTable(t *testing.T) {
 	adapterFactory, err := db.GetAdapterFactory("elastic")
 	if err != nil {
@@ -0,0 +1,52 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// 执行计划格式
+const (
+	// PlanMySQL EXPLAIN FORMAT=JSON
+	PlanMySQL = "MYSQL_JSON"
+	// PlanPostgreSQL EXPLAIN (FORMAT JSON)
+	PlanPostgreSQL = "POSTGRESQL_JSON"
+	// PlanElasticDSL SQL translate API翻译后的DSL
+	PlanElasticDSL = "ELASTIC_DSL"
+)
+
+// Explainer 查询执行计划，适配层按需实现，仅分析不执行
+type Explainer interface {
+	// Explain 执行计划，args为绑定参数
+	Explain(ctx context.Context, exp string, args ...interface{}) (*Plan, error)
+}
+
+// Plan 执行计划
+type Plan struct {
+	// 执行计划格式
+	Format string `json:"format"`
+	// 数据库返回的原始执行计划
+	Raw json.RawMessage `json:"raw"`
+	// 计划中的表扫描，数据库无预估时为空
+	Scans []*Scan `json:"scans"`
+}
+
+// Scan 表扫描
+type Scan struct {
+	Table string `json:"table"`
+	// 访问方式，如MySQL的ALL、ref，PostgreSQL的Seq Scan、Index Scan
+	Access string `json:"access"`
+	// 是否全表扫描
+	Full bool `json:"full"`
+	// 预估扫描行数
+	Rows float64 `json:"rows"`
+}
+
+// EstimatedRows 预估扫描总行数
+func (p *Plan) EstimatedRows() float64 {
+	var rows float64
+	for _, scan := range p.Scans {
+		rows += scan.Rows
+	}
+	return rows
+}
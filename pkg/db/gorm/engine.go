@@ -2,12 +2,19 @@ package gorm
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 
+	sqlstmt "github.com/xuanbo/ohmydata/pkg/db/stmt"
+	"github.com/xuanbo/ohmydata/pkg/model"
 	"github.com/xuanbo/ohmydata/pkg/model/condition"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 var (
@@ -249,3 +256,111 @@ func (e *Engine) Page(table string, dest interface{}, opts ...SelectOptionFunc)
 	}
 	return 0, ErrClauseNotSupported
 }
+
+// dialect 词法规则使用的方言
+func dialect(tx *gorm.DB) sqlstmt.Dialect {
+	if tx.Dialector.Name() == "postgres" {
+		return sqlstmt.PostgreSQL
+	}
+	return sqlstmt.MySQL
+}
+
+// Exec 事务中执行写操作，返回影响行数以及生成的主键
+// 包含RETURNING子句时（PostgreSQL）以返回结果的第一列作为主键，否则取LastInsertId（MySQL）
+func (e *Engine) Exec(ctx context.Context, exp string, vars ...interface{}) (int64, []interface{}, error) {
+	var (
+		rowsAffected int64
+		keys         []interface{}
+	)
+	err := e.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 按方言处理占位符
+		stmt := &gorm.Statement{DB: tx, Context: ctx}
+		if strings.Contains(exp, "@") {
+			clause.NamedExpr{SQL: exp, Vars: vars}.Build(stmt)
+		} else {
+			clause.Expr{SQL: exp, Vars: vars}.Build(stmt)
+		}
+		var (
+			s     = stmt.SQL.String()
+			begin = time.Now()
+			err   error
+		)
+		defer func() {
+			tx.Logger.Trace(ctx, begin, func() (string, int64) {
+				return tx.Dialector.Explain(s, stmt.Vars...), rowsAffected
+			}, err)
+		}()
+
+		if sqlstmt.HasReturning(s, dialect(tx)) {
+			var (
+				rows    *sql.Rows
+				columns []string
+			)
+			if rows, err = tx.Statement.ConnPool.QueryContext(ctx, s, stmt.Vars...); err != nil {
+				return err
+			}
+			defer rows.Close()
+			if columns, err = rows.Columns(); err != nil {
+				return err
+			}
+			for rows.Next() {
+				values := make([]interface{}, len(columns))
+				for i := range values {
+					values[i] = new(interface{})
+				}
+				if err = rows.Scan(values...); err != nil {
+					return err
+				}
+				if len(values) > 0 {
+					keys = append(keys, *(values[0].(*interface{})))
+				}
+				rowsAffected++
+			}
+			err = rows.Err()
+			return err
+		}
+
+		var result sql.Result
+		if result, err = tx.Statement.ConnPool.ExecContext(ctx, s, stmt.Vars...); err != nil {
+			return err
+		}
+		if rowsAffected, err = result.RowsAffected(); err != nil {
+			return err
+		}
+		// 驱动不支持时忽略
+		if id, err := result.LastInsertId(); err == nil && id > 0 {
+			keys = append(keys, id)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+	return rowsAffected, keys, nil
+}
+
+// ScanLimit 逐行读取查询结果，行数或者字节数（按行JSON序列化累计）超过分页对象的限制时中止
+func ScanLimit(tx *gorm.DB, rows *sql.Rows, page *model.Pagination) ([]map[string]interface{}, error) {
+	var (
+		data  = make([]map[string]interface{}, 0, 16)
+		bytes uint64
+	)
+	for rows.Next() {
+		m := make(map[string]interface{})
+		if err := tx.ScanRows(rows, &m); err != nil {
+			return nil, err
+		}
+		if page.MaxBytes > 0 {
+			b, err := json.Marshal(m)
+			if err != nil {
+				return nil, err
+			}
+			bytes += uint64(len(b))
+		}
+		data = append(data, m)
+		if err := page.Check(uint64(len(data)), bytes); err != nil {
+			return nil, err
+		}
+	}
+	return data, rows.Err()
+}
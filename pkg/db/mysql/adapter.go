@@ -2,7 +2,11 @@ package mysql
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/xuanbo/ohmydata/pkg/db"
@@ -21,8 +25,12 @@ var selectOptionFunc orm.SelectOptionFunc
 
 // adapter MySQL实现
 type adapter struct {
-	engine *orm.Engine
-	db     *gorm.DB
+	engine     *orm.Engine
+	db         *gorm.DB
+	allowWrite bool
+	readOnly   bool
+	// 连接地址，终止会话时使用独立连接
+	url string
 }
 
 func (a *adapter) Ping(ctx context.Context) error {
@@ -41,6 +49,14 @@ func (a *adapter) Close() error {
 	return db.Close()
 }
 
+func (a *adapter) Stats() (sql.DBStats, error) {
+	db, err := a.db.DB()
+	if err != nil {
+		return sql.DBStats{}, err
+	}
+	return db.Stats(), nil
+}
+
 func (a *adapter) TableNames(ctx context.Context) ([]string, error) {
 	var tableNames []string
 	if err := a.db.WithContext(ctx).Raw("SHOW TABLES").Scan(&tableNames).Error; err != nil {
@@ -49,6 +65,28 @@ func (a *adapter) TableNames(ctx context.Context) ([]string, error) {
 	return tableNames, nil
 }
 
+func (a *adapter) TableMetas(ctx context.Context) ([]*db.TableMeta, error) {
+	rows, err := a.db.WithContext(ctx).Raw("SHOW FULL TABLES").Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tableMetas := make([]*db.TableMeta, 0, 8)
+	for rows.Next() {
+		var name, tableType string
+		if err := rows.Scan(&name, &tableType); err != nil {
+			return nil, err
+		}
+		kind := db.KindTable
+		if tableType == "VIEW" {
+			kind = db.KindView
+		}
+		tableMetas = append(tableMetas, &db.TableMeta{Name: name, Kind: kind})
+	}
+	return tableMetas, rows.Err()
+}
+
 func (a *adapter) Table(ctx context.Context, name string) (*db.Table, error) {
 	querySQL := fmt.Sprintf("SELECT * FROM %s LIMIT 1", name)
 	rows, err := a.db.WithContext(ctx).Raw(querySQL).Rows()
@@ -64,38 +102,45 @@ func (a *adapter) Table(ctx context.Context, name string) (*db.Table, error) {
 
 	table := &db.Table{
 		Name:    name,
-		Columns: make([]*db.Column, len(types)),
+		Columns: db.NewColumns(types),
 	}
-	for i, columnType := range types {
-		column := &db.Column{
-			Name: columnType.Name(),
-			Type: columnType.DatabaseTypeName(),
-		}
-		table.Columns[i] = column
-
-		var (
-			length   int64
-			scale    int64
-			nullable bool
-			ok       bool
-		)
-		length, ok = columnType.Length()
-		if ok {
-			table.Columns[i].Length = length
-		}
-		length, scale, ok = columnType.DecimalSize()
-		if ok {
-			table.Columns[i].Length = length
-			table.Columns[i].Scale = scale
-		}
-		nullable, ok = columnType.Nullable()
-		if ok {
-			table.Columns[i].Nullable = nullable
+	keys, err := a.primaryKeys(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	for _, column := range table.Columns {
+		for _, key := range keys {
+			if column.Name == key.Name {
+				column.PrimaryKey = true
+				column.Generated = key.Generated
+			}
 		}
 	}
 	return table, nil
 }
 
+// primaryKeys 主键字段，表名可带库名前缀，自增或默认值表达式的主键由数据库生成
+func (a *adapter) primaryKeys(ctx context.Context, name string) ([]*db.PrimaryKey, error) {
+	var (
+		keys   []*db.PrimaryKey
+		schema = "DATABASE()"
+		args   = []interface{}{name}
+	)
+	if i := strings.LastIndex(name, "."); i > 0 {
+		schema = "?"
+		args = []interface{}{name[:i], name[i+1:]}
+	}
+	querySQL := "SELECT k.COLUMN_NAME AS name, " +
+		"(c.EXTRA LIKE '%auto_increment%' OR c.EXTRA LIKE '%DEFAULT_GENERATED%') AS generated " +
+		"FROM information_schema.KEY_COLUMN_USAGE k JOIN information_schema.COLUMNS c " +
+		"ON c.TABLE_SCHEMA = k.TABLE_SCHEMA AND c.TABLE_NAME = k.TABLE_NAME AND c.COLUMN_NAME = k.COLUMN_NAME " +
+		"WHERE k.TABLE_SCHEMA = " + schema + " AND k.TABLE_NAME = ? AND k.CONSTRAINT_NAME = 'PRIMARY' ORDER BY k.ORDINAL_POSITION"
+	if err := a.db.WithContext(ctx).Raw(querySQL, args...).Scan(&keys).Error; err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
 func (a *adapter) QueryTable(ctx context.Context, tableName string, page *model.Pagination) error {
 	var (
 		total uint64
@@ -119,16 +164,24 @@ func (a *adapter) QueryTable(ctx context.Context, tableName string, page *model.
 	return nil
 }
 
-func (a *adapter) Query(ctx context.Context, exp string, page *model.Pagination) error {
+func (a *adapter) Query(ctx context.Context, exp string, page *model.Pagination, args ...interface{}) error {
+	return a.query(ctx, func(tx *gorm.DB) error {
+		return a.doQuery(tx, exp, page, args...)
+	})
+}
+
+func (a *adapter) doQuery(tx *gorm.DB, exp string, page *model.Pagination, args ...interface{}) error {
 	var (
 		total uint64
 		data  []map[string]interface{}
+		err   error
 	)
 
+	pageSQL, countSQL := a.PageSQL(exp, page)
+
 	// 未分页限制查询
-	if page.Page == 0 {
-		pageSQL := fmt.Sprintf("SELECT * FROM (%s) TMP_PAGE LIMIT %d", exp, page.Offset)
-		if err := a.db.WithContext(ctx).Raw(pageSQL).Scan(&data).Error; err != nil {
+	if countSQL == "" {
+		if data, err = a.scan(tx, page, pageSQL, args...); err != nil {
 			return err
 		}
 		total = uint64(len(data))
@@ -137,8 +190,7 @@ func (a *adapter) Query(ctx context.Context, exp string, page *model.Pagination)
 		return nil
 	}
 
-	countSQL := fmt.Sprintf("SELECT COUNT(*) FROM (%s) TMP_COUNT", exp)
-	if err := a.db.WithContext(ctx).Raw(countSQL).Scan(&total).Error; err != nil {
+	if err := tx.Raw(countSQL, args...).Scan(&total).Error; err != nil {
 		return err
 	}
 
@@ -146,8 +198,7 @@ func (a *adapter) Query(ctx context.Context, exp string, page *model.Pagination)
 		return nil
 	}
 
-	pageSQL := fmt.Sprintf("SELECT * FROM (%s) TMP_PAGE LIMIT %d, %d", exp, page.Offset, page.Size)
-	if err := a.db.WithContext(ctx).Raw(pageSQL).Scan(&data).Error; err != nil {
+	if data, err = a.scan(tx, page, pageSQL, args...); err != nil {
 		return err
 	}
 
@@ -155,6 +206,138 @@ func (a *adapter) Query(ctx context.Context, exp string, page *model.Pagination)
 	return nil
 }
 
+func (a *adapter) Columns(ctx context.Context, exp string, args ...interface{}) ([]*db.Column, error) {
+	var columns []*db.Column
+	if err := a.query(ctx, func(tx *gorm.DB) error {
+		rows, err := tx.Raw(fmt.Sprintf("SELECT * FROM (%s) TMP_PROBE LIMIT 0", exp), args...).Rows()
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		types, err := rows.ColumnTypes()
+		if err != nil {
+			return err
+		}
+		columns = db.NewColumns(types)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return columns, nil
+}
+
+// PageSQL 分页包装后的查询语句以及计数语句，未分页时计数语句为空
+func (a *adapter) PageSQL(exp string, page *model.Pagination) (string, string) {
+	if page.Page == 0 {
+		return fmt.Sprintf("SELECT * FROM (%s) TMP_PAGE LIMIT %d", exp, page.Offset), ""
+	}
+	return fmt.Sprintf("SELECT * FROM (%s) TMP_PAGE LIMIT %d, %d", exp, page.Offset, page.Size), fmt.Sprintf("SELECT COUNT(*) FROM (%s) TMP_COUNT", exp)
+}
+
+// scan 逐行读取，受分页对象的行数、字节数限制
+func (a *adapter) scan(tx *gorm.DB, page *model.Pagination, querySQL string, args ...interface{}) ([]map[string]interface{}, error) {
+	rows, err := tx.Raw(querySQL, args...).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return orm.ScanLimit(tx, rows, page)
+}
+
+// query 开启只读时在只读事务中查询，登记了运行中的查询时固定连接并记录会话ID以便终止
+func (a *adapter) query(ctx context.Context, fc func(tx *gorm.DB) error) error {
+	tx := a.db.WithContext(ctx)
+	if running := db.RunningFromContext(ctx); running != nil {
+		sqlDB, err := a.db.DB()
+		if err != nil {
+			return err
+		}
+		conn, err := sqlDB.Conn(ctx)
+		if err != nil {
+			return err
+		}
+		// 先清除会话ID再归还连接，避免终止时误杀复用该连接的其他查询
+		defer func() {
+			running.ClearSession()
+			conn.Close()
+		}()
+		var sessionID int64
+		if err := conn.QueryRowContext(ctx, "SELECT CONNECTION_ID()").Scan(&sessionID); err != nil {
+			return err
+		}
+		running.SetSession(sessionID)
+		tx.Statement.ConnPool = conn
+	}
+	if !a.readOnly {
+		return fc(tx)
+	}
+	return tx.Transaction(fc, &sql.TxOptions{ReadOnly: true})
+}
+
+// Kill 在独立连接上终止会话中正在执行的语句，不占用连接池
+func (a *adapter) Kill(ctx context.Context, sessionID int64) error {
+	conn, err := sql.Open("mysql", a.url)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.ExecContext(ctx, fmt.Sprintf("KILL QUERY %d", sessionID))
+	return err
+}
+
+func (a *adapter) Explain(ctx context.Context, exp string, args ...interface{}) (*db.Plan, error) {
+	var raw string
+	if err := a.query(ctx, func(tx *gorm.DB) error {
+		return tx.Raw("EXPLAIN FORMAT=JSON "+exp, args...).Row().Scan(&raw)
+	}); err != nil {
+		return nil, err
+	}
+	var v interface{}
+	if err := json.Unmarshal([]byte(raw), &v); err != nil {
+		return nil, err
+	}
+	return &db.Plan{Format: db.PlanMySQL, Raw: json.RawMessage(raw), Scans: planScans(v, nil)}, nil
+}
+
+// planScans 遍历执行计划中的table节点，access_type为ALL时为全表扫描
+func planScans(v interface{}, scans []*db.Scan) []*db.Scan {
+	switch e := v.(type) {
+	case map[string]interface{}:
+		if table, ok := e["table"].(map[string]interface{}); ok {
+			if name, ok := table["table_name"].(string); ok {
+				access, _ := table["access_type"].(string)
+				rows, _ := table["rows_examined_per_scan"].(float64)
+				scans = append(scans, &db.Scan{Table: name, Access: access, Full: access == "ALL", Rows: rows})
+			}
+		}
+		// 按key排序遍历，保证顺序稳定
+		keys := make([]string, 0, len(e))
+		for k := range e {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			scans = planScans(e[k], scans)
+		}
+	case []interface{}:
+		for _, child := range e {
+			scans = planScans(child, scans)
+		}
+	}
+	return scans
+}
+
+func (a *adapter) Exec(ctx context.Context, exp string, args ...interface{}) (*db.ExecResult, error) {
+	if !a.allowWrite {
+		return nil, db.ErrWriteNotAllowed
+	}
+	rowsAffected, keys, err := a.engine.Exec(ctx, exp, args...)
+	if err != nil {
+		return nil, err
+	}
+	return &db.ExecResult{RowsAffected: rowsAffected, GeneratedKeys: keys}, nil
+}
+
 // adapter MySQL实现
 type adapterFactory struct {
 }
@@ -179,7 +362,7 @@ func (a *adapterFactory) Create(dataSource *entity.DataSource) (db.Adapter, erro
 	}
 	db.SetMaxIdleConns(dataSource.MaxIdleConns)
 	db.SetMaxOpenConns(dataSource.MaxOpenConns)
-	return &adapter{engine: orm.New(gormDB), db: gormDB}, nil
+	return &adapter{engine: orm.New(gormDB), db: gormDB, allowWrite: dataSource.AllowWrite, readOnly: dataSource.ReadOnly, url: dataSource.URL}, nil
 }
 
 // Register 注册
@@ -3,6 +3,7 @@ package mysql_test
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"testing"
 
 	"github.com/xuanbo/ohmydata/pkg/db"
@@ -73,6 +74,12 @@ func TestTable(t *testing.T) {
 		t.Error(err)
 		return
 	}
+	// 主键为应用生成的字符串
+	for _, column := range table.Columns {
+		if column.PrimaryKey && column.Generated {
+			t.Errorf("主键%s不应为数据库生成", column.Name)
+		}
+	}
 	b, err := json.Marshal(table)
 	if err != nil {
 		t.Error(err)
@@ -150,3 +157,95 @@ func TestQuery(t *testing.T) {
 	}
 	t.Logf("page: %s", string(b))
 }
+
+func TestExplain(t *testing.T) {
+	adapterFactory, err := db.GetAdapterFactory("mysql")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	dataSource := &entity.DataSource{
+		URL:          "root:123456@tcp(127.0.0.1:3306)/ohmydata?charset=utf8",
+		MaxIdleConns: 1,
+		MaxOpenConns: 8,
+	}
+	adapter, err := adapterFactory.Create(dataSource)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer adapter.Close()
+
+	plan, err := adapter.(db.Explainer).Explain(context.TODO(), "select * from oh_data_source where name = ?", "test")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	b, err := json.Marshal(plan)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	t.Logf("plan: %s", string(b))
+}
+
+func TestExec(t *testing.T) {
+	adapterFactory, err := db.GetAdapterFactory("mysql")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	dataSource := &entity.DataSource{
+		URL:          "root:123456@tcp(127.0.0.1:3306)/ohmydata?charset=utf8",
+		MaxIdleConns: 1,
+		MaxOpenConns: 8,
+	}
+	adapter, err := adapterFactory.Create(dataSource)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err := adapter.Exec(context.TODO(), "delete from oh_exec_test"); !errors.Is(err, db.ErrWriteNotAllowed) {
+		t.Errorf("未开启写操作时应拒绝执行: %v", err)
+	}
+	adapter.Close()
+
+	dataSource.AllowWrite = true
+	if adapter, err = adapterFactory.Create(dataSource); err != nil {
+		t.Error(err)
+		return
+	}
+	defer adapter.Close()
+
+	if _, err := adapter.Exec(context.TODO(), "create table if not exists oh_exec_test (id bigint auto_increment primary key, name varchar(64))"); err != nil {
+		t.Error(err)
+		return
+	}
+	defer adapter.Exec(context.TODO(), "drop table oh_exec_test")
+
+	inserted, err := adapter.Exec(context.TODO(), "insert into oh_exec_test(name) values (?)", "test")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if inserted.RowsAffected != 1 || len(inserted.GeneratedKeys) != 1 {
+		t.Errorf("插入结果错误: %+v", inserted)
+		return
+	}
+
+	result, err := adapter.Exec(context.TODO(), "update oh_exec_test set name = @name where name = @old",
+		map[string]interface{}{"name": "renamed", "old": "test"})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if result.RowsAffected != 1 {
+		t.Errorf("命名参数更新的影响行数错误: %d", result.RowsAffected)
+	}
+
+	// 主键冲突
+	if _, err := adapter.Exec(context.TODO(), "insert into oh_exec_test(id, name) values (?, ?)", inserted.GeneratedKeys[0], "dup"); err == nil {
+		t.Error("主键冲突时应返回错误")
+	}
+}
@@ -2,7 +2,10 @@ package postgres
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/xuanbo/ohmydata/pkg/db"
@@ -21,8 +24,12 @@ var selectOptionFunc orm.SelectOptionFunc
 
 // adapter PostgreSQL实现
 type adapter struct {
-	engine *orm.Engine
-	db     *gorm.DB
+	engine     *orm.Engine
+	db         *gorm.DB
+	allowWrite bool
+	readOnly   bool
+	// 连接地址，终止会话时使用独立连接
+	url string
 }
 
 func (a *adapter) Ping(ctx context.Context) error {
@@ -41,6 +48,14 @@ func (a *adapter) Close() error {
 	return db.Close()
 }
 
+func (a *adapter) Stats() (sql.DBStats, error) {
+	db, err := a.db.DB()
+	if err != nil {
+		return sql.DBStats{}, err
+	}
+	return db.Stats(), nil
+}
+
 func (a *adapter) TableNames(ctx context.Context) ([]string, error) {
 	var tableNames []string
 	if err := a.db.WithContext(ctx).Raw("select tablename from pg_tables where schemaname='public'").Scan(&tableNames).Error; err != nil {
@@ -49,6 +64,28 @@ func (a *adapter) TableNames(ctx context.Context) ([]string, error) {
 	return tableNames, nil
 }
 
+func (a *adapter) TableMetas(ctx context.Context) ([]*db.TableMeta, error) {
+	rows, err := a.db.WithContext(ctx).Raw("select table_name, table_type from information_schema.tables where table_schema='public'").Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tableMetas := make([]*db.TableMeta, 0, 8)
+	for rows.Next() {
+		var name, tableType string
+		if err := rows.Scan(&name, &tableType); err != nil {
+			return nil, err
+		}
+		kind := db.KindTable
+		if tableType == "VIEW" {
+			kind = db.KindView
+		}
+		tableMetas = append(tableMetas, &db.TableMeta{Name: name, Kind: kind})
+	}
+	return tableMetas, rows.Err()
+}
+
 func (a *adapter) Table(ctx context.Context, name string) (*db.Table, error) {
 	querySQL := fmt.Sprintf("SELECT * FROM %s LIMIT 1", name)
 	rows, err := a.db.WithContext(ctx).Raw(querySQL).Rows()
@@ -64,38 +101,37 @@ func (a *adapter) Table(ctx context.Context, name string) (*db.Table, error) {
 
 	table := &db.Table{
 		Name:    name,
-		Columns: make([]*db.Column, len(types)),
+		Columns: db.NewColumns(types),
 	}
-	for i, columnType := range types {
-		column := &db.Column{
-			Name: columnType.Name(),
-			Type: columnType.DatabaseTypeName(),
-		}
-		table.Columns[i] = column
-
-		var (
-			length   int64
-			scale    int64
-			nullable bool
-			ok       bool
-		)
-		length, ok = columnType.Length()
-		if ok {
-			table.Columns[i].Length = length
-		}
-		length, scale, ok = columnType.DecimalSize()
-		if ok {
-			table.Columns[i].Length = length
-			table.Columns[i].Scale = scale
-		}
-		nullable, ok = columnType.Nullable()
-		if ok {
-			table.Columns[i].Nullable = nullable
+	keys, err := a.primaryKeys(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	for _, column := range table.Columns {
+		for _, key := range keys {
+			if column.Name == key.Name {
+				column.PrimaryKey = true
+				column.Generated = key.Generated
+			}
 		}
 	}
 	return table, nil
 }
 
+// primaryKeys 主键字段，表名可带schema前缀，标识列或有默认值（如serial）的主键由数据库生成
+func (a *adapter) primaryKeys(ctx context.Context, name string) ([]*db.PrimaryKey, error) {
+	var keys []*db.PrimaryKey
+	querySQL := "SELECT a.attname AS name, " +
+		"(a.attidentity <> '' OR EXISTS (SELECT 1 FROM pg_attrdef d WHERE d.adrelid = a.attrelid AND d.adnum = a.attnum)) AS generated " +
+		"FROM pg_index i " +
+		"JOIN pg_attribute a ON a.attrelid = i.indrelid AND a.attnum = ANY(i.indkey) " +
+		"WHERE i.indrelid = CAST(? AS regclass) AND i.indisprimary ORDER BY array_position(CAST(i.indkey AS int2[]), a.attnum)"
+	if err := a.db.WithContext(ctx).Raw(querySQL, name).Scan(&keys).Error; err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
 func (a *adapter) QueryTable(ctx context.Context, tableName string, page *model.Pagination) error {
 	var (
 		total uint64
@@ -115,16 +151,24 @@ func (a *adapter) QueryTable(ctx context.Context, tableName string, page *model.
 	return nil
 }
 
-func (a *adapter) Query(ctx context.Context, exp string, page *model.Pagination) error {
+func (a *adapter) Query(ctx context.Context, exp string, page *model.Pagination, args ...interface{}) error {
+	return a.query(ctx, func(tx *gorm.DB) error {
+		return a.doQuery(tx, exp, page, args...)
+	})
+}
+
+func (a *adapter) doQuery(tx *gorm.DB, exp string, page *model.Pagination, args ...interface{}) error {
 	var (
 		total uint64
 		data  []map[string]interface{}
+		err   error
 	)
 
+	pageSQL, countSQL := a.PageSQL(exp, page)
+
 	// 未分页限制查询
-	if page.Page == 0 {
-		pageSQL := fmt.Sprintf("SELECT * FROM (%s) TMP_PAGE LIMIT %d", exp, page.Offset)
-		if err := a.db.WithContext(ctx).Raw(pageSQL).Scan(&data).Error; err != nil {
+	if countSQL == "" {
+		if data, err = a.scan(tx, page, pageSQL, args...); err != nil {
 			return err
 		}
 		total = uint64(len(data))
@@ -133,8 +177,7 @@ func (a *adapter) Query(ctx context.Context, exp string, page *model.Pagination)
 		return nil
 	}
 
-	countSQL := fmt.Sprintf("SELECT COUNT(*) FROM (%s) TMP_COUNT", exp)
-	if err := a.db.WithContext(ctx).Raw(countSQL).Scan(&total).Error; err != nil {
+	if err := tx.Raw(countSQL, args...).Scan(&total).Error; err != nil {
 		return err
 	}
 
@@ -142,8 +185,7 @@ func (a *adapter) Query(ctx context.Context, exp string, page *model.Pagination)
 		return nil
 	}
 
-	pageSQL := fmt.Sprintf("SELECT * FROM (%s) TMP_PAGE LIMIT %d OFFSET %d", exp, page.Size, page.Offset)
-	if err := a.db.WithContext(ctx).Raw(pageSQL).Scan(&data).Error; err != nil {
+	if data, err = a.scan(tx, page, pageSQL, args...); err != nil {
 		return err
 	}
 
@@ -151,6 +193,162 @@ func (a *adapter) Query(ctx context.Context, exp string, page *model.Pagination)
 	return nil
 }
 
+func (a *adapter) Columns(ctx context.Context, exp string, args ...interface{}) ([]*db.Column, error) {
+	var columns []*db.Column
+	if err := a.query(ctx, func(tx *gorm.DB) error {
+		rows, err := tx.Raw(fmt.Sprintf("SELECT * FROM (%s) TMP_PROBE LIMIT 0", exp), args...).Rows()
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		types, err := rows.ColumnTypes()
+		if err != nil {
+			return err
+		}
+		columns = db.NewColumns(types)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return columns, nil
+}
+
+// PageSQL 分页包装后的查询语句以及计数语句，未分页时计数语句为空
+func (a *adapter) PageSQL(exp string, page *model.Pagination) (string, string) {
+	if page.Page == 0 {
+		return fmt.Sprintf("SELECT * FROM (%s) TMP_PAGE LIMIT %d", exp, page.Offset), ""
+	}
+	return fmt.Sprintf("SELECT * FROM (%s) TMP_PAGE LIMIT %d OFFSET %d", exp, page.Size, page.Offset), fmt.Sprintf("SELECT COUNT(*) FROM (%s) TMP_COUNT", exp)
+}
+
+// scan 逐行读取，受分页对象的行数、字节数限制
+func (a *adapter) scan(tx *gorm.DB, page *model.Pagination, querySQL string, args ...interface{}) ([]map[string]interface{}, error) {
+	rows, err := tx.Raw(querySQL, args...).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return orm.ScanLimit(tx, rows, page)
+}
+
+// query 开启只读时在只读事务中查询，登记了运行中的查询时固定连接并记录会话ID以便终止
+func (a *adapter) query(ctx context.Context, fc func(tx *gorm.DB) error) error {
+	tx := a.db.WithContext(ctx)
+	if running := db.RunningFromContext(ctx); running != nil {
+		sqlDB, err := a.db.DB()
+		if err != nil {
+			return err
+		}
+		conn, err := sqlDB.Conn(ctx)
+		if err != nil {
+			return err
+		}
+		// 先清除会话ID再归还连接，避免终止时误杀复用该连接的其他查询
+		defer func() {
+			running.ClearSession()
+			conn.Close()
+		}()
+		var sessionID int64
+		if err := conn.QueryRowContext(ctx, "SELECT pg_backend_pid()").Scan(&sessionID); err != nil {
+			return err
+		}
+		running.SetSession(sessionID)
+		tx.Statement.ConnPool = conn
+	}
+	if !a.readOnly {
+		return fc(tx)
+	}
+	return tx.Transaction(fc, &sql.TxOptions{ReadOnly: true})
+}
+
+// Kill 在独立连接上终止会话中正在执行的语句，不占用连接池
+func (a *adapter) Kill(ctx context.Context, sessionID int64) error {
+	conn, err := sql.Open("pgx", a.url)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.ExecContext(ctx, "SELECT pg_cancel_backend($1)", sessionID)
+	return err
+}
+
+func (a *adapter) Explain(ctx context.Context, exp string, args ...interface{}) (*db.Plan, error) {
+	var (
+		raw   string
+		scans []*db.Scan
+	)
+	if err := a.query(ctx, func(tx *gorm.DB) error {
+		if err := tx.Raw("EXPLAIN (FORMAT JSON) "+exp, args...).Row().Scan(&raw); err != nil {
+			return err
+		}
+		var v interface{}
+		if err := json.Unmarshal([]byte(raw), &v); err != nil {
+			return err
+		}
+		scans = planScans(v, nil)
+		return relationRows(tx, scans)
+	}); err != nil {
+		return nil, err
+	}
+	return &db.Plan{Format: db.PlanPostgreSQL, Raw: json.RawMessage(raw), Scans: scans}, nil
+}
+
+// relationRows Plan Rows为过滤后的预估行数，全表扫描以统计信息中表的行数作为扫描行数
+func relationRows(tx *gorm.DB, scans []*db.Scan) error {
+	for _, scan := range scans {
+		if !scan.Full {
+			continue
+		}
+		var reltuples float64
+		if err := tx.Raw("SELECT COALESCE(MAX(reltuples), 0) FROM pg_class WHERE oid = to_regclass(format('%I', ?::text))", scan.Table).
+			Row().Scan(&reltuples); err != nil {
+			return err
+		}
+		// 未收集统计信息时为-1
+		if reltuples > scan.Rows {
+			scan.Rows = reltuples
+		}
+	}
+	return nil
+}
+
+// planScans 遍历执行计划中的节点，Seq Scan为全表扫描
+func planScans(v interface{}, scans []*db.Scan) []*db.Scan {
+	switch e := v.(type) {
+	case map[string]interface{}:
+		if name, ok := e["Relation Name"].(string); ok {
+			access, _ := e["Node Type"].(string)
+			rows, _ := e["Plan Rows"].(float64)
+			scans = append(scans, &db.Scan{Table: name, Access: access, Full: access == "Seq Scan", Rows: rows})
+		}
+		// 按key排序遍历，保证顺序稳定
+		keys := make([]string, 0, len(e))
+		for k := range e {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			scans = planScans(e[k], scans)
+		}
+	case []interface{}:
+		for _, child := range e {
+			scans = planScans(child, scans)
+		}
+	}
+	return scans
+}
+
+func (a *adapter) Exec(ctx context.Context, exp string, args ...interface{}) (*db.ExecResult, error) {
+	if !a.allowWrite {
+		return nil, db.ErrWriteNotAllowed
+	}
+	rowsAffected, keys, err := a.engine.Exec(ctx, exp, args...)
+	if err != nil {
+		return nil, err
+	}
+	return &db.ExecResult{RowsAffected: rowsAffected, GeneratedKeys: keys}, nil
+}
+
 // adapter MySQL实现
 type adapterFactory struct {
 }
@@ -172,7 +370,7 @@ func (a *adapterFactory) Create(dataSource *entity.DataSource) (db.Adapter, erro
 	}
 	db.SetMaxIdleConns(dataSource.MaxIdleConns)
 	db.SetMaxOpenConns(dataSource.MaxOpenConns)
-	return &adapter{engine: orm.New(gormDB), db: gormDB}, nil
+	return &adapter{engine: orm.New(gormDB), db: gormDB, allowWrite: dataSource.AllowWrite, readOnly: dataSource.ReadOnly, url: dataSource.URL}, nil
 }
 
 // Register 注册
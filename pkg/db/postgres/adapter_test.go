@@ -148,3 +148,35 @@ func TestQuery(t *testing.T) {
 	}
 	t.Logf("page: %s", string(b))
 }
+
+func TestExplain(t *testing.T) {
+	adapterFactory, err := db.GetAdapterFactory("postgres")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	dataSource := &entity.DataSource{
+		URL:          "host=localhost user=postgres password=123456 dbname=ohmydata port=5432 sslmode=disable TimeZone=Asia/Shanghai",
+		MaxIdleConns: 1,
+		MaxOpenConns: 8,
+	}
+	adapter, err := adapterFactory.Create(dataSource)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer adapter.Close()
+
+	plan, err := adapter.(db.Explainer).Explain(context.TODO(), "select * from oh_data_set where name = ?", "test")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	b, err := json.Marshal(plan)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	t.Logf("plan: %s", string(b))
+}
@@ -0,0 +1,178 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/xuanbo/ohmydata/pkg/log"
+
+	"go.uber.org/zap"
+)
+
+var runnings = &runningRegistry{store: make(map[string]*Running)}
+
+// ErrRunningNotFound 查询不存在或已结束
+var ErrRunningNotFound = errors.New("查询不存在或已结束")
+
+// KillTimeout 终止数据库会话的超时时间
+const KillTimeout = 5 * time.Second
+
+// Killer 终止数据库会话中正在执行的语句，适配层按需实现
+type Killer interface {
+	// Kill 终止会话中正在执行的语句，应使用独立连接，连接池可能已被运行中的查询占满
+	Kill(ctx context.Context, sessionID int64) error
+}
+
+// Running 运行中的查询
+type Running struct {
+	sync.Mutex
+	ID        string        `json:"id"`
+	SourceID  string        `json:"sourceId"`
+	DataSetID string        `json:"dataSetId"`
+	Caller    string        `json:"caller"`
+	SQL       string        `json:"sql"`
+	Args      []interface{} `json:"args"`
+	StartAt   time.Time     `json:"startAt"`
+	// 数据库会话ID，MySQL为CONNECTION_ID()，PostgreSQL为pg_backend_pid()
+	SessionID int64 `json:"sessionId"`
+
+	cancel context.CancelFunc
+	done   bool
+	// 正在终止时不为nil，终止完成后关闭
+	killing chan struct{}
+}
+
+// SetSession 登记数据库会话ID，适配层在固定连接上执行查询前调用
+func (r *Running) SetSession(sessionID int64) {
+	r.Lock()
+	r.SessionID = sessionID
+	r.Unlock()
+}
+
+// ClearSession 清除数据库会话ID，适配层归还连接前调用，避免终止已被其他查询复用的会话
+// 正在终止时等待终止完成，终止使用独立连接且有超时，等待不会占用连接池
+func (r *Running) ClearSession() {
+	r.Lock()
+	r.SessionID = 0
+	killing := r.killing
+	r.Unlock()
+	if killing != nil {
+		<-killing
+	}
+}
+
+type runningKey struct{}
+
+type runningRegistry struct {
+	sync.RWMutex
+	store map[string]*Running
+}
+
+// StartRunning 登记查询，返回可取消的上下文以及结束方法，查询结束后必须调用结束方法
+func StartRunning(ctx context.Context, running *Running) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	if running.ID == "" {
+		running.ID = NewID()
+	}
+	running.StartAt = time.Now()
+	running.cancel = cancel
+	ctx = context.WithValue(ctx, runningKey{}, running)
+
+	runnings.Lock()
+	runnings.store[running.ID] = running
+	runnings.Unlock()
+
+	return ctx, func() {
+		running.Lock()
+		running.done = true
+		running.SessionID = 0
+		running.Unlock()
+
+		runnings.Lock()
+		delete(runnings.store, running.ID)
+		runnings.Unlock()
+		cancel()
+	}
+}
+
+// RunningFromContext 上下文中登记的查询
+func RunningFromContext(ctx context.Context) *Running {
+	running, _ := ctx.Value(runningKey{}).(*Running)
+	return running
+}
+
+// ListRunning 数据源运行中的查询，按开始时间排序
+func ListRunning(sourceID string) []*Running {
+	list := make([]*Running, 0, 8)
+	runnings.RLock()
+	for _, running := range runnings.store {
+		if running.SourceID != sourceID {
+			continue
+		}
+		running.Lock()
+		list = append(list, &Running{
+			ID:        running.ID,
+			SourceID:  running.SourceID,
+			DataSetID: running.DataSetID,
+			Caller:    running.Caller,
+			SQL:       running.SQL,
+			Args:      running.Args,
+			StartAt:   running.StartAt,
+			SessionID: running.SessionID,
+		})
+		running.Unlock()
+	}
+	runnings.RUnlock()
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].StartAt.Before(list[j].StartAt)
+	})
+	return list
+}
+
+// KillRunning 终止查询：适配层支持时先在独立连接上终止数据库会话中的语句，再取消上下文
+func KillRunning(ctx context.Context, sourceID, id string) error {
+	runnings.RLock()
+	running, ok := runnings.store[id]
+	runnings.RUnlock()
+	if !ok || running.SourceID != sourceID {
+		return ErrRunningNotFound
+	}
+
+	// 标记为正在终止后释放锁，终止完成前连接不会归还给其他查询
+	running.Lock()
+	if running.done || running.killing != nil {
+		running.Unlock()
+		return ErrRunningNotFound
+	}
+	sessionID := running.SessionID
+	killing := make(chan struct{})
+	running.killing = killing
+	running.Unlock()
+	defer func() {
+		running.Lock()
+		running.killing = nil
+		running.Unlock()
+		close(killing)
+	}()
+
+	if sessionID > 0 {
+		adapter, err := GetAdapter(sourceID)
+		if err != nil {
+			return err
+		}
+		if killer, ok := adapter.(Killer); ok {
+			killCtx, cancel := context.WithTimeout(ctx, KillTimeout)
+			err := killer.Kill(killCtx, sessionID)
+			cancel()
+			if err != nil {
+				log.Logger().Warn("终止数据库会话错误", zap.String("id", id), zap.Int64("sessionId", sessionID), zap.Error(err))
+			}
+		}
+	}
+	running.cancel()
+	log.Logger().Info("终止查询", zap.String("id", id), zap.String("sourceId", sourceID), zap.String("sql", running.SQL))
+	return nil
+}
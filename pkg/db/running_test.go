@@ -0,0 +1,145 @@
+package db_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/xuanbo/ohmydata/pkg/db"
+	"github.com/xuanbo/ohmydata/pkg/log"
+)
+
+func init() {
+	// 日志
+	if err := log.Init(); err != nil {
+		panic(err)
+	}
+}
+
+func TestRunning(t *testing.T) {
+	first := &db.Running{ID: "running-1", SourceID: "source", SQL: "select 1"}
+	ctx, finishFirst := db.StartRunning(context.TODO(), first)
+	defer finishFirst()
+	second := &db.Running{ID: "running-2", SourceID: "source", SQL: "select 2"}
+	_, finishSecond := db.StartRunning(context.TODO(), second)
+	other := &db.Running{ID: "running-3", SourceID: "other", SQL: "select 3"}
+	_, finishOther := db.StartRunning(context.TODO(), other)
+	defer finishOther()
+
+	if db.RunningFromContext(ctx) != first {
+		t.Error("上下文中未登记查询")
+	}
+
+	// 按数据源、开始时间列出
+	list := db.ListRunning("source")
+	if len(list) != 2 || list[0].ID != first.ID || list[1].ID != second.ID {
+		t.Errorf("运行中的查询错误: %v", list)
+	}
+
+	// 结束后不再列出，也不能终止
+	finishSecond()
+	if list := db.ListRunning("source"); len(list) != 1 {
+		t.Errorf("结束的查询仍在列表中: %v", list)
+	}
+	if err := db.KillRunning(context.TODO(), "source", second.ID); err != db.ErrRunningNotFound {
+		t.Errorf("终止已结束的查询: %v", err)
+	}
+
+	// 数据源不匹配
+	if err := db.KillRunning(context.TODO(), "source", other.ID); err != db.ErrRunningNotFound {
+		t.Errorf("终止其他数据源的查询: %v", err)
+	}
+
+	// 登记了会话时终止数据库会话，数据源未注册时返回错误且不取消上下文
+	first.SetSession(100)
+	if err := db.KillRunning(context.TODO(), "source", first.ID); err == nil {
+		t.Error("数据源未注册时应返回错误")
+	}
+	if ctx.Err() != nil {
+		t.Error("终止会话失败时不应取消上下文")
+	}
+
+	// 连接归还后仅取消上下文
+	first.ClearSession()
+	if err := db.KillRunning(context.TODO(), "source", first.ID); err != nil {
+		t.Error(err)
+		return
+	}
+	if ctx.Err() != context.Canceled {
+		t.Errorf("上下文未取消: %v", ctx.Err())
+	}
+}
+
+// blockingKiller 终止会话时阻塞，直到release关闭
+type blockingKiller struct {
+	db.Adapter
+	started chan int64
+	release chan struct{}
+}
+
+func (k *blockingKiller) Kill(ctx context.Context, sessionID int64) error {
+	k.started <- sessionID
+	select {
+	case <-k.release:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (k *blockingKiller) Close() error {
+	return nil
+}
+
+func TestKillRunningBlocking(t *testing.T) {
+	killer := &blockingKiller{started: make(chan int64, 1), release: make(chan struct{})}
+	if err := db.PutAdapter("killer-source", killer); err != nil {
+		t.Error(err)
+		return
+	}
+	running := &db.Running{ID: "running-kill", SourceID: "killer-source", SQL: "select sleep(100)"}
+	ctx, finish := db.StartRunning(context.TODO(), running)
+	defer finish()
+	running.SetSession(200)
+
+	killed := make(chan error, 1)
+	go func() {
+		killed <- db.KillRunning(context.TODO(), "killer-source", running.ID)
+	}()
+	if sessionID := <-killer.started; sessionID != 200 {
+		t.Errorf("终止的会话错误: %d", sessionID)
+	}
+
+	// 终止期间不持有锁：可以列出查询，重复终止直接返回
+	if list := db.ListRunning("killer-source"); len(list) != 1 {
+		t.Errorf("运行中的查询错误: %v", list)
+	}
+	if err := db.KillRunning(context.TODO(), "killer-source", running.ID); err != db.ErrRunningNotFound {
+		t.Errorf("正在终止的查询重复终止: %v", err)
+	}
+
+	// 归还连接等待终止完成
+	cleared := make(chan struct{})
+	go func() {
+		running.ClearSession()
+		close(cleared)
+	}()
+	select {
+	case <-cleared:
+		t.Error("终止完成前不应归还连接")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(killer.release)
+	if err := <-killed; err != nil {
+		t.Error(err)
+	}
+	select {
+	case <-cleared:
+	case <-time.After(time.Second):
+		t.Error("终止完成后未归还连接")
+	}
+	if ctx.Err() != context.Canceled {
+		t.Errorf("上下文未取消: %v", ctx.Err())
+	}
+}
@@ -0,0 +1,32 @@
+package db
+
+import (
+	"database/sql"
+)
+
+// MetadataSource 元数据库在连接池状态中的名称
+const MetadataSource = "metadata"
+
+// StatsProvider 连接池状态，基于database/sql的适配层按需实现
+type StatsProvider interface {
+	// Stats 连接池状态
+	Stats() (sql.DBStats, error)
+}
+
+// AllStats 元数据库以及各数据源的连接池状态，key为数据源ID，元数据库为metadata
+func AllStats() map[string]sql.DBStats {
+	stats := make(map[string]sql.DBStats)
+	if gormDB != nil {
+		if db, err := gormDB.DB(); err == nil {
+			stats[MetadataSource] = db.Stats()
+		}
+	}
+	adapters.Range(func(id string, adapter Adapter) {
+		if provider, ok := adapter.(StatsProvider); ok {
+			if s, err := provider.Stats(); err == nil {
+				stats[id] = s
+			}
+		}
+	})
+	return stats
+}
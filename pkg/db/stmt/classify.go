@@ -0,0 +1,256 @@
+package stmt
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/xuanbo/ohmydata/pkg/strutil"
+)
+
+// Type 语句类型
+type Type uint8
+
+const (
+	// Unknown 未知
+	Unknown Type = iota
+	// Query 查询：SELECT、WITH、VALUES、TABLE
+	Query
+	// DML 数据操作：INSERT、UPDATE、DELETE、REPLACE、MERGE
+	DML
+	// DDL 数据定义：CREATE、ALTER、DROP、TRUNCATE、RENAME、COMMENT
+	DDL
+	// Other 其他：SET、GRANT、CALL、SHOW等
+	Other
+)
+
+// String 描述
+func (t Type) String() string {
+	switch t {
+	case Query:
+		return "QUERY"
+	case DML:
+		return "DML"
+	case DDL:
+		return "DDL"
+	case Other:
+		return "OTHER"
+	}
+	return "UNKNOWN"
+}
+
+var (
+	queryKeywords = []string{"SELECT", "WITH", "VALUES", "TABLE"}
+	dmlKeywords   = []string{"INSERT", "UPDATE", "DELETE", "REPLACE", "MERGE", "UPSERT"}
+	ddlKeywords   = []string{"CREATE", "ALTER", "DROP", "TRUNCATE", "RENAME", "COMMENT"}
+	// 查询语句中处于语句位置时视为非只读
+	forbiddenKeywords = []string{"INSERT", "UPDATE", "DELETE", "REPLACE", "MERGE", "UPSERT",
+		"CREATE", "ALTER", "DROP", "TRUNCATE", "RENAME", "COMMENT", "GRANT", "REVOKE"}
+	// 锁定读：FOR UPDATE、FOR SHARE、FOR NO KEY UPDATE、FOR KEY SHARE
+	lockKeywords = []string{"UPDATE", "SHARE", "NO", "KEY"}
+	// 运算符关键字，前一个Word为列名，如comment LIKE ?
+	operatorKeywords = []string{"AND", "OR", "NOT", "XOR", "IS", "IN", "LIKE", "ILIKE", "RLIKE", "REGEXP", "SIMILAR",
+		"BETWEEN", "ESCAPE", "COLLATE", "DIV", "MOD", "AS", "ASC", "DESC"}
+
+	// 模板动作
+	actionRegexp = regexp.MustCompile(`(?s)\{\{.*?\}\}`)
+)
+
+var (
+	// ErrEmpty 空语句
+	ErrEmpty = errors.New("SQL语句不能为空")
+	// ErrMultiple 多条语句
+	ErrMultiple = errors.New("仅允许单条SQL语句")
+	// ErrUnbalanced 括号不匹配
+	ErrUnbalanced = errors.New("SQL语句括号不匹配")
+)
+
+// Statement 语句
+type Statement struct {
+	Type Type
+	// 首个关键字
+	Keyword string
+	Tokens  []Token
+}
+
+// Classify 分词后按;拆分语句并判断类型
+func Classify(sql string, dialect Dialect) ([]*Statement, error) {
+	tokens, err := Tokenize(sql, dialect)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		statements = make([]*Statement, 0, 1)
+		start      = 0
+	)
+	for i := 0; i <= len(tokens); i++ {
+		if i < len(tokens) && !(tokens[i].Kind == Symbol && tokens[i].Text == ";") {
+			continue
+		}
+		if i > start {
+			statements = append(statements, newStatement(tokens[start:i]))
+		}
+		start = i + 1
+	}
+	return statements, nil
+}
+
+func newStatement(tokens []Token) *Statement {
+	statement := &Statement{Type: Unknown, Tokens: tokens}
+	// 跳过前置括号，如(SELECT ...) UNION (SELECT ...)
+	for _, token := range tokens {
+		if token.Kind == Symbol && token.Text == "(" {
+			continue
+		}
+		statement.Keyword = token.Keyword()
+		break
+	}
+	switch {
+	case statement.Keyword == "":
+	case strutil.EqualAny(statement.Keyword, queryKeywords):
+		statement.Type = Query
+	case strutil.EqualAny(statement.Keyword, dmlKeywords):
+		statement.Type = DML
+	case strutil.EqualAny(statement.Keyword, ddlKeywords):
+		statement.Type = DDL
+	default:
+		statement.Type = Other
+	}
+	return statement
+}
+
+// CheckRead 校验为单条只读查询语句，需在所有方言的词法规则下均成立
+func CheckRead(sql string) error {
+	for _, dialect := range Dialects {
+		statement, err := single(sql, dialect)
+		if err != nil {
+			return err
+		}
+		if statement.Type != Query {
+			return fmt.Errorf("仅允许查询语句，当前为%s语句: %s", statement.Type, statement.Keyword)
+		}
+		if !balanced(statement.Tokens) {
+			return ErrUnbalanced
+		}
+		// SELECT ... INTO、SELECT ... FOR UPDATE、SELECT ... LOCK IN SHARE MODE
+		for i, token := range statement.Tokens {
+			switch keyword := token.Keyword(); {
+			case keyword == "INTO":
+				return errors.New("查询语句不允许包含INTO")
+			case keyword == "FOR" && strutil.EqualAny(keywordAt(statement.Tokens, i+1), lockKeywords):
+				return errors.New("查询语句不允许包含FOR UPDATE等锁定读")
+			case keyword == "LOCK" && keywordAt(statement.Tokens, i+1) == "IN" && keywordAt(statement.Tokens, i+2) == "SHARE":
+				return errors.New("查询语句不允许包含LOCK IN SHARE MODE等锁定读")
+			}
+		}
+		// 如PostgreSQL的WITH d AS (DELETE ...)
+		for _, keyword := range nestedKeywords(statement.Tokens) {
+			if strutil.EqualAny(keyword, forbiddenKeywords) {
+				return fmt.Errorf("查询语句不允许包含%s", keyword)
+			}
+		}
+	}
+	return nil
+}
+
+// CheckWrite 校验为单条DML语句，需在所有方言的词法规则下均成立
+func CheckWrite(sql string) error {
+	for _, dialect := range Dialects {
+		statement, err := single(sql, dialect)
+		if err != nil {
+			return err
+		}
+		if statement.Type != DML {
+			return fmt.Errorf("仅允许INSERT、UPDATE、DELETE语句，当前为%s语句: %s", statement.Type, statement.Keyword)
+		}
+		for _, keyword := range nestedKeywords(statement.Tokens) {
+			if strutil.EqualAny(keyword, ddlKeywords) {
+				return fmt.Errorf("写操作语句不允许包含%s", keyword)
+			}
+		}
+	}
+	return nil
+}
+
+// HasReturning 语句是否包含RETURNING子句，忽略字符串、注释以及引号标识符中的同名内容，词法错误时返回false
+func HasReturning(sql string, dialect Dialect) bool {
+	tokens, err := Tokenize(sql, dialect)
+	if err != nil {
+		return false
+	}
+	for _, token := range tokens {
+		if token.Keyword() == "RETURNING" {
+			return true
+		}
+	}
+	return false
+}
+
+// StripActions 将模板动作{{...}}替换为占位符，用于校验未渲染的表达式
+func StripActions(expression string) string {
+	return actionRegexp.ReplaceAllString(expression, "?")
+}
+
+// balanced 括号是否匹配，右括号不能先于对应的左括号出现
+func balanced(tokens []Token) bool {
+	depth := 0
+	for _, token := range tokens {
+		if token.Kind != Symbol {
+			continue
+		}
+		switch token.Text {
+		case "(":
+			depth++
+		case ")":
+			if depth--; depth < 0 {
+				return false
+			}
+		}
+	}
+	return depth == 0
+}
+
+// nestedKeywords 括号内或括号后处于语句位置的关键字，如CTE主体、子查询以及CTE后的主语句
+// 仅当关键字后紧跟非运算符的标识符时视为语句，避免将同名列（如comment、update）误判为语句
+func nestedKeywords(tokens []Token) []string {
+	keywords := make([]string, 0)
+	for i := 0; i < len(tokens); i++ {
+		if tokens[i].Kind != Symbol || (tokens[i].Text != "(" && tokens[i].Text != ")") {
+			continue
+		}
+		j := i + 1
+		for j < len(tokens) && tokens[j].Kind == Symbol && tokens[j].Text == "(" {
+			j++
+		}
+		if j+1 >= len(tokens) || tokens[j].Kind != Word {
+			continue
+		}
+		next := tokens[j+1]
+		if next.Kind == QuotedIdent || (next.Kind == Word && !strutil.EqualAny(next.Keyword(), operatorKeywords)) {
+			keywords = append(keywords, tokens[j].Keyword())
+		}
+	}
+	return keywords
+}
+
+// keywordAt 第i个词法单元的关键字，越界返回空
+func keywordAt(tokens []Token, i int) string {
+	if i >= len(tokens) {
+		return ""
+	}
+	return tokens[i].Keyword()
+}
+
+func single(sql string, dialect Dialect) (*Statement, error) {
+	statements, err := Classify(sql, dialect)
+	if err != nil {
+		return nil, err
+	}
+	if len(statements) == 0 {
+		return nil, ErrEmpty
+	}
+	if len(statements) > 1 {
+		return nil, ErrMultiple
+	}
+	return statements[0], nil
+}
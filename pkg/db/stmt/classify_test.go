@@ -0,0 +1,108 @@
+package stmt_test
+
+import (
+	"testing"
+
+	"github.com/xuanbo/ohmydata/pkg/db/stmt"
+)
+
+func TestCheckRead(t *testing.T) {
+	allowed := []string{
+		"select * from oh_data_source",
+		"SELECT name FROM t WHERE note = 'drop table t; delete from t'",
+		"select update_time, created_by from t -- ; drop table t",
+		"/* drop */ select 1",
+		"with a as (select 1) select * from a",
+		"(select 1) union (select 2);",
+		"select `delete` from t",
+		"select comment, update, delete, grant from t where (comment like ?)",
+		"select * from t where id in (select id from t2) and (update = 1)",
+		"select replace(name, 'a', 'b') from t",
+		"select ')' from t where a = '('",
+		"select lock from t where lock in (1, 2)",
+	}
+	for _, sql := range allowed {
+		if err := stmt.CheckRead(sql); err != nil {
+			t.Errorf("%s: %v", sql, err)
+		}
+	}
+
+	rejected := []string{
+		"",
+		"drop table t",
+		"update t set a = 1",
+		"select 1; drop table t",
+		"select 1 # ; drop table t",
+		"select 1--1; drop table t",
+		"select 'a\\'; drop table t; -- '",
+		"select /*! 1; drop table t */",
+		"with d as (delete from t returning *) select * from d",
+		"select * into t2 from t",
+		"select * from t for update",
+		"select * from t for no key update",
+		"with a as (select 1) delete from t",
+		"select * from t where id in (update t set a = 1 returning id)",
+		"select 'unterminated",
+		"select $$; drop table t$$",
+		"select 1) t union select * from (select 1",
+		"select * from (select 1",
+		"select * from t lock in share mode",
+	}
+	for _, sql := range rejected {
+		if err := stmt.CheckRead(sql); err == nil {
+			t.Errorf("%s: expected error", sql)
+		} else {
+			t.Logf("%s: %v", sql, err)
+		}
+	}
+}
+
+func TestCheckWrite(t *testing.T) {
+	if err := stmt.CheckWrite("insert into t(name) values (?) on duplicate key update name = values(name)"); err != nil {
+		t.Error(err)
+	}
+	if err := stmt.CheckWrite("delete from t where id = ?"); err != nil {
+		t.Error(err)
+	}
+	if err := stmt.CheckWrite("update t set comment = ? where id = ?"); err != nil {
+		t.Error(err)
+	}
+	if err := stmt.CheckWrite("insert into t(name, comment) values (?, ?)"); err != nil {
+		t.Error(err)
+	}
+	if err := stmt.CheckWrite("select 1"); err == nil {
+		t.Error("expected error")
+	}
+	if err := stmt.CheckWrite("delete from t; drop table t"); err == nil {
+		t.Error("expected error")
+	}
+	if err := stmt.CheckWrite("delete from t where id in (drop table t2)"); err == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestHasReturning(t *testing.T) {
+	cases := map[string]bool{
+		"insert into t(name) values ($1) returning id":              true,
+		"UPDATE t SET name = $1 WHERE id = $2 RETURNING *":          true,
+		"update t set returning_customer = true where id = $1":      false,
+		"update t set note = 'returning' where id = $1":             false,
+		`update t set "returning" = $1 where id = $2`:               false,
+		"delete from t where id = $1 -- returning id":               false,
+		"insert into t(name) values ($$returning$$) returning t.id": true,
+		"update t set name = 'unterminated returning":               false,
+	}
+	for sql, want := range cases {
+		if got := stmt.HasReturning(sql, stmt.PostgreSQL); got != want {
+			t.Errorf("%s: expected %v, actual: %v", sql, want, got)
+		}
+	}
+}
+
+func TestStripActions(t *testing.T) {
+	exp := stmt.StripActions("select * from t where name = '{{.name}}' {{if .age}} and age = {{bind .age}} {{end}}")
+	if err := stmt.CheckRead(exp); err != nil {
+		t.Error(err)
+	}
+	t.Logf("exp: %s", exp)
+}
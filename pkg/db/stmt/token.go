@@ -0,0 +1,241 @@
+package stmt
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+)
+
+// Dialect 方言，影响注释、字符串的词法规则
+type Dialect uint8
+
+const (
+	// MySQL 支持#注释、反斜杠转义以及/*! */可执行注释
+	MySQL Dialect = iota
+	// PostgreSQL 支持嵌套注释、E''字符串以及$tag$字符串
+	PostgreSQL
+)
+
+// Dialects 所有方言
+var Dialects = []Dialect{MySQL, PostgreSQL}
+
+// TokenKind 词法单元类型
+type TokenKind uint8
+
+const (
+	// Word 关键字或者标识符
+	Word TokenKind = iota
+	// QuotedIdent 引号标识符
+	QuotedIdent
+	// String 字符串
+	String
+	// Number 数字
+	Number
+	// Symbol 符号
+	Symbol
+)
+
+var (
+	// ErrUnterminatedString 未闭合的字符串
+	ErrUnterminatedString = errors.New("SQL词法错误: 未闭合的字符串或标识符")
+	// ErrUnterminatedComment 未闭合的注释
+	ErrUnterminatedComment = errors.New("SQL词法错误: 未闭合的注释")
+)
+
+// Token 词法单元，注释与空白不产生词法单元
+type Token struct {
+	Kind TokenKind
+	Text string
+}
+
+// Keyword 关键字大写，非Word返回空
+func (t Token) Keyword() string {
+	if t.Kind != Word {
+		return ""
+	}
+	return strings.ToUpper(t.Text)
+}
+
+// Tokenize 分词
+func Tokenize(sql string, dialect Dialect) ([]Token, error) {
+	var (
+		tokens = make([]Token, 0, 32)
+		s      = []rune(sql)
+		n      = len(s)
+		// MySQL /*! */ 可执行注释内
+		executable bool
+	)
+	for i := 0; i < n; {
+		c := s[i]
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '-' && i+1 < n && s[i+1] == '-' && (dialect != MySQL || i+2 >= n || unicode.IsSpace(s[i+2]) || unicode.IsControl(s[i+2])):
+			// MySQL中--后需跟空白才是注释
+			i = skipLine(s, i)
+		case c == '#' && dialect == MySQL:
+			i = skipLine(s, i)
+		case c == '/' && i+1 < n && s[i+1] == '*':
+			if dialect == MySQL && i+2 < n && s[i+2] == '!' {
+				// 可执行注释，内容按SQL处理
+				i += 3
+				for i < n && unicode.IsDigit(s[i]) {
+					i++
+				}
+				executable = true
+				continue
+			}
+			end, err := skipComment(s, i, dialect == PostgreSQL)
+			if err != nil {
+				return nil, err
+			}
+			i = end
+		case c == '*' && executable && i+1 < n && s[i+1] == '/':
+			executable = false
+			i += 2
+		case c == '\'':
+			end, err := skipQuoted(s, i, '\'', dialect == MySQL)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, Token{Kind: String, Text: string(s[i:end])})
+			i = end
+		case c == '"':
+			// MySQL中为字符串，PostgreSQL中为标识符
+			end, err := skipQuoted(s, i, '"', dialect == MySQL)
+			if err != nil {
+				return nil, err
+			}
+			kind := QuotedIdent
+			if dialect == MySQL {
+				kind = String
+			}
+			tokens = append(tokens, Token{Kind: kind, Text: string(s[i:end])})
+			i = end
+		case c == '`':
+			// PostgreSQL中反引号为非法字符，统一按标识符处理
+			end, err := skipQuoted(s, i, '`', false)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, Token{Kind: QuotedIdent, Text: string(s[i:end])})
+			i = end
+		case (c == 'E' || c == 'e') && dialect == PostgreSQL && i+1 < n && s[i+1] == '\'':
+			end, err := skipQuoted(s, i+1, '\'', true)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, Token{Kind: String, Text: string(s[i:end])})
+			i = end
+		case c == '$' && dialect == PostgreSQL && isDollarTag(s, i):
+			end, err := skipDollarQuoted(s, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, Token{Kind: String, Text: string(s[i:end])})
+			i = end
+		case isWordStart(c):
+			end := i + 1
+			for end < n && isWordPart(s[end]) {
+				end++
+			}
+			tokens = append(tokens, Token{Kind: Word, Text: string(s[i:end])})
+			i = end
+		case unicode.IsDigit(c):
+			end := i + 1
+			for end < n && (unicode.IsDigit(s[end]) || s[end] == '.' || unicode.IsLetter(s[end])) {
+				end++
+			}
+			tokens = append(tokens, Token{Kind: Number, Text: string(s[i:end])})
+			i = end
+		default:
+			tokens = append(tokens, Token{Kind: Symbol, Text: string(c)})
+			i++
+		}
+	}
+	return tokens, nil
+}
+
+func isWordStart(c rune) bool {
+	return c == '_' || unicode.IsLetter(c)
+}
+
+func isWordPart(c rune) bool {
+	return c == '_' || c == '$' || unicode.IsLetter(c) || unicode.IsDigit(c)
+}
+
+// skipLine 跳过行注释
+func skipLine(s []rune, i int) int {
+	for i < len(s) && s[i] != '\n' {
+		i++
+	}
+	return i
+}
+
+// skipComment 跳过块注释，PostgreSQL支持嵌套
+func skipComment(s []rune, i int, nested bool) (int, error) {
+	depth := 0
+	for i < len(s) {
+		switch {
+		case s[i] == '/' && i+1 < len(s) && s[i+1] == '*':
+			if depth == 0 || nested {
+				depth++
+			}
+			i += 2
+		case s[i] == '*' && i+1 < len(s) && s[i+1] == '/':
+			depth--
+			i += 2
+			if depth == 0 {
+				return i, nil
+			}
+		default:
+			i++
+		}
+	}
+	return 0, ErrUnterminatedComment
+}
+
+// skipQuoted 跳过引号内容，引号重复表示转义，backslash为true时支持反斜杠转义
+func skipQuoted(s []rune, i int, quote rune, backslash bool) (int, error) {
+	for i++; i < len(s); i++ {
+		switch {
+		case backslash && s[i] == '\\':
+			i++
+		case s[i] == quote:
+			if i+1 < len(s) && s[i+1] == quote {
+				i++
+				continue
+			}
+			return i + 1, nil
+		}
+	}
+	return 0, ErrUnterminatedString
+}
+
+// isDollarTag 是否为$tag$，$1等参数占位不是
+func isDollarTag(s []rune, i int) bool {
+	for j := i + 1; j < len(s); j++ {
+		if s[j] == '$' {
+			return true
+		}
+		if !(s[j] == '_' || unicode.IsLetter(s[j]) || (j > i+1 && unicode.IsDigit(s[j]))) {
+			return false
+		}
+	}
+	return false
+}
+
+// skipDollarQuoted 跳过$tag$...$tag$
+func skipDollarQuoted(s []rune, i int) (int, error) {
+	end := i + 1
+	for s[end] != '$' {
+		end++
+	}
+	tag := s[i : end+1]
+	for j := end + 1; j+len(tag) <= len(s); j++ {
+		if string(s[j:j+len(tag)]) == string(tag) {
+			return j + len(tag), nil
+		}
+	}
+	return 0, ErrUnterminatedString
+}
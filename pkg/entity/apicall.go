@@ -0,0 +1,28 @@
+package entity
+
+// APICall 已发布API的调用记录
+type APICall struct {
+	ID        string `json:"id" gorm:"primaryKey;type:string;size:30"`
+	DataSetID string `json:"dataSetId" gorm:"type:string;size:30;index"`
+	// 请求方法，GraphQL调用为GRAPHQL
+	Method string `json:"method" gorm:"type:string;size:10"`
+	Path   string `json:"path" gorm:"type:string;size:200"`
+	// 调用者，用户ID或app:应用ID
+	Caller string `json:"caller" gorm:"type:string;size:50;index"`
+	IP     string `json:"ip" gorm:"type:string;size:50"`
+	// 响应状态码，与单独调用时的响应一致
+	Status  int  `json:"status"`
+	Success bool `json:"success" gorm:"type:bool"`
+	// 耗时，单位毫秒
+	Latency int64 `json:"latency"`
+	// 返回行数，写操作为影响行数
+	RowCount int64  `json:"rowCount"`
+	CacheHit bool   `json:"cacheHit" gorm:"type:bool"`
+	Error    string `json:"error" gorm:"type:string;size:500"`
+	CalledAt *Time  `json:"calledAt" gorm:"index"`
+}
+
+// TableName 表名
+func (APICall) TableName() string {
+	return "oh_api_call"
+}
@@ -0,0 +1,58 @@
+package entity
+
+import (
+	"github.com/xuanbo/ohmydata/pkg/api/util"
+
+	"gorm.io/gorm"
+)
+
+// App 调用已发布API的应用，使用key、secret认证，与管理端登录token分开
+type App struct {
+	Entity
+	Name        string `json:"name" gorm:"type:string;size:50"`
+	Description string `json:"description" gorm:"type:string;size:100"`
+	// 应用标识
+	Key string `json:"key" gorm:"type:string;size:32;uniqueIndex"`
+	// 应用密钥，仅在新增、轮换时返回
+	Secret string `json:"-" gorm:"type:string;size:64"`
+	// 轮换前的密钥，宽限期内仍可使用
+	PreviousSecret    string `json:"-" gorm:"type:string;size:64"`
+	PreviousExpiresAt *Time  `json:"previousExpiresAt"`
+	RotatedAt         *Time  `json:"rotatedAt"`
+	// 必须使用签名请求，不允许直接携带密钥、token
+	SignatureRequired bool `json:"signatureRequired" gorm:"type:bool"`
+	// 过期时间，为空表示不过期
+	ExpiresAt *Time `json:"expiresAt"`
+	// 吊销后不可再使用，不可恢复
+	Revoked   bool  `json:"revoked" gorm:"type:bool"`
+	RevokedAt *Time `json:"revokedAt"`
+}
+
+// TableName 表名
+func (App) TableName() string {
+	return "oh_app"
+}
+
+// BeforeCreate 创建前
+func (a *App) BeforeCreate(tx *gorm.DB) error {
+	ctx := tx.Statement.Context
+	user := ctx.Value(util.UserID)
+	if user != nil {
+		if userID, ok := user.(string); ok {
+			a.CreatedBy = userID
+		}
+	}
+	return nil
+}
+
+// BeforeUpdate 更新前
+func (a *App) BeforeUpdate(tx *gorm.DB) error {
+	ctx := tx.Statement.Context
+	user := ctx.Value(util.UserID)
+	if user != nil {
+		if userID, ok := user.(string); ok {
+			a.UpdatedBy = userID
+		}
+	}
+	return nil
+}
@@ -14,16 +14,32 @@ type DataSet struct {
 	Description string `json:"description" gorm:"type:string;size:100"`
 	// 自定义请求路径
 	Path string `json:"path" gorm:"type:string;size:100"`
+	// 数据集类型
+	Kind DataSetKind `json:"kind" gorm:"type:uint;size:1"`
+	// 写数据集的请求方法：POST、PUT、DELETE
+	Method string `json:"method" gorm:"type:string;size:10"`
 	// 查询模板
 	Expression string `json:"expression" gorm:"type:string;size:1000"`
 	// 发布状态
 	PublishStatus bool `json:"publishStatus" gorm:"type:bool"`
+	// 审核状态以及审核人，修改后需重新审核
+	State    DataSetState `json:"state" gorm:"type:uint;size:1"`
+	Reviewer string       `json:"reviewer" gorm:"type:string;size:30"`
+	// 当前版本以及发布的版本，API按发布的版本提供服务
+	Version          uint `json:"version" gorm:"type:uint;size:10"`
+	PublishedVersion uint `json:"publishedVersion" gorm:"type:uint;size:10"`
 	// 分页
 	EnablePage bool `json:"enablePage" gorm:"type:bool"`
 	BatchLimit uint `json:"batchLimit" gorm:"type:uint;size:10"`
 	// 缓存
 	EnableCache   bool `json:"enableCache" gorm:"type:bool"`
 	ExpireSeconds uint `json:"expireSeconds" gorm:"type:uint;size:10"`
+	// 查询限制，0表示不限制：超时时间（秒，覆盖全局http.timeout）、最大行数、最大响应字节数
+	TimeoutSeconds uint `json:"timeoutSeconds" gorm:"type:uint;size:10"`
+	MaxRows        uint `json:"maxRows" gorm:"type:uint;size:10"`
+	MaxBytes       uint `json:"maxBytes" gorm:"type:uint;size:10"`
+	// 由GitOps目录管理，不允许在界面修改
+	Managed bool `json:"managed" gorm:"type:bool"`
 
 	// 参数
 	RequestParams  []*RequestParam  `json:"requestParams" gorm:"-"`
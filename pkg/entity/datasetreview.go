@@ -0,0 +1,39 @@
+package entity
+
+import (
+	"github.com/xuanbo/ohmydata/pkg/api/util"
+
+	"gorm.io/gorm"
+)
+
+// DataSetReview 数据集审核记录，记录每次状态变更以及评论，创建人为操作人
+type DataSetReview struct {
+	Entity
+	DataSetID string `json:"dataSetId" gorm:"type:string;size:30;index"`
+	// 操作时的数据集版本
+	Version uint         `json:"version" gorm:"type:uint;size:10"`
+	Action  ReviewAction `json:"action" gorm:"type:string;size:20"`
+	// 状态变更，评论时相同
+	FromState DataSetState `json:"fromState" gorm:"type:uint;size:1"`
+	ToState   DataSetState `json:"toState" gorm:"type:uint;size:1"`
+	// 提交审核时指定的审核人
+	Reviewer string `json:"reviewer" gorm:"type:string;size:30"`
+	Comment  string `json:"comment" gorm:"type:string;size:500"`
+}
+
+// TableName 表名
+func (DataSetReview) TableName() string {
+	return "oh_data_set_review"
+}
+
+// BeforeCreate 创建前
+func (r *DataSetReview) BeforeCreate(tx *gorm.DB) error {
+	ctx := tx.Statement.Context
+	user := ctx.Value(util.UserID)
+	if user != nil {
+		if userID, ok := user.(string); ok {
+			r.CreatedBy = userID
+		}
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package entity
+
+import (
+	"github.com/xuanbo/ohmydata/pkg/api/util"
+
+	"gorm.io/gorm"
+)
+
+// DataSetVersion 数据集版本，每次保存生成不可变快照
+type DataSetVersion struct {
+	Entity
+	DataSetID string `json:"dataSetId" gorm:"type:string;size:30;uniqueIndex:idx_data_set_version"`
+	Version   uint   `json:"version" gorm:"type:uint;size:10;uniqueIndex:idx_data_set_version"`
+	// 数据集快照，包含表达式、参数以及配置，JSON格式
+	Snapshot string `json:"-" gorm:"type:text"`
+}
+
+// TableName 表名
+func (DataSetVersion) TableName() string {
+	return "oh_data_set_version"
+}
+
+// BeforeCreate 创建前
+func (v *DataSetVersion) BeforeCreate(tx *gorm.DB) error {
+	ctx := tx.Statement.Context
+	user := ctx.Value(util.UserID)
+	if user != nil {
+		if userID, ok := user.(string); ok {
+			v.CreatedBy = userID
+		}
+	}
+	return nil
+}
@@ -17,6 +17,12 @@ type DataSource struct {
 	Password     string `json:"password" gorm:"type:string;size:100"`
 	MaxIdleConns int    `json:"maxIdleConns" gorm:"type:uint;size:3"`
 	MaxOpenConns int    `json:"maxOpenConns" gorm:"type:uint;size:5"`
+	// 允许写操作
+	AllowWrite bool `json:"allowWrite" gorm:"type:bool"`
+	// 查询使用只读事务，驱动支持时生效
+	ReadOnly bool `json:"readOnly" gorm:"type:bool"`
+	// 由GitOps目录管理，不允许在界面修改
+	Managed bool `json:"managed" gorm:"type:bool"`
 }
 
 // TableName 表名
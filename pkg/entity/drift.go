@@ -0,0 +1,22 @@
+package entity
+
+// DriftEvent 数据集结构漂移事件，查询结果字段与响应参数不一致
+type DriftEvent struct {
+	Entity
+	DataSetID   string `json:"dataSetId" gorm:"type:string;size:30;index"`
+	DataSetName string `json:"dataSetName" gorm:"type:string;size:50"`
+	// 新增、移除的字段，逗号分隔
+	Added   string `json:"added" gorm:"type:string;size:1000"`
+	Removed string `json:"removed" gorm:"type:string;size:1000"`
+	// 类型变更的字段，如age(String->Long)，逗号分隔
+	Changed string `json:"changed" gorm:"type:string;size:1000"`
+	// 漂移内容摘要，相同内容不重复记录
+	Digest string `json:"-" gorm:"type:string;size:32"`
+	// 是否为恢复事件，结构恢复与响应参数一致时记录
+	Resolved bool `json:"resolved" gorm:"type:bool"`
+}
+
+// TableName 表名
+func (DriftEvent) TableName() string {
+	return "oh_drift_event"
+}
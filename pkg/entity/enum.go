@@ -45,3 +45,79 @@ const (
 	// ConvertRename 重命名
 	ConvertRename
 )
+
+// DataSetKind 数据集类型
+type DataSetKind uint8
+
+const (
+	// KindQuery 查询
+	KindQuery DataSetKind = iota
+	// KindWrite 写操作
+	KindWrite
+)
+
+// DataSetState 数据集审核状态
+type DataSetState uint8
+
+const (
+	// StateDraft 草稿，新建或修改后
+	StateDraft DataSetState = iota
+	// StatePending 待审核
+	StatePending
+	// StateApproved 审核通过，可发布
+	StateApproved
+	// StatePublished 已发布
+	StatePublished
+	// StateDeprecated 已弃用，仍提供服务
+	StateDeprecated
+	// StateRetired 已下线
+	StateRetired
+)
+
+// ReviewAction 审核动作
+type ReviewAction string
+
+const (
+	// ActionSubmit 提交审核
+	ActionSubmit ReviewAction = "submit"
+	// ActionWithdraw 撤回审核
+	ActionWithdraw ReviewAction = "withdraw"
+	// ActionApprove 审核通过
+	ActionApprove ReviewAction = "approve"
+	// ActionReject 审核驳回
+	ActionReject ReviewAction = "reject"
+	// ActionPublish 发布
+	ActionPublish ReviewAction = "publish"
+	// ActionDeprecate 弃用
+	ActionDeprecate ReviewAction = "deprecate"
+	// ActionRetire 下线
+	ActionRetire ReviewAction = "retire"
+	// ActionEdit 修改，回到草稿
+	ActionEdit ReviewAction = "edit"
+	// ActionComment 评论，不改变状态
+	ActionComment ReviewAction = "comment"
+)
+
+// GrantSubject 授权对象类型
+type GrantSubject string
+
+const (
+	// SubjectUser 用户
+	SubjectUser GrantSubject = "user"
+	// SubjectGroup 用户组
+	SubjectGroup GrantSubject = "group"
+	// SubjectApp 应用
+	SubjectApp GrantSubject = "app"
+)
+
+// RateLimitScope 限流维度
+type RateLimitScope string
+
+const (
+	// ScopeDataSet 数据集，所有调用者共享
+	ScopeDataSet RateLimitScope = "dataset"
+	// ScopeApp 应用，应用调用的所有API共享
+	ScopeApp RateLimitScope = "app"
+	// ScopeIP 客户端IP，该IP调用的所有API共享
+	ScopeIP RateLimitScope = "ip"
+)
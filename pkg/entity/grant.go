@@ -0,0 +1,51 @@
+package entity
+
+import (
+	"github.com/xuanbo/ohmydata/pkg/api/util"
+
+	"gorm.io/gorm"
+)
+
+// Grant API授权，授权对象可调用指定数据集或路径前缀下的数据集
+type Grant struct {
+	Entity
+	SubjectType GrantSubject `json:"subjectType" gorm:"type:string;size:10;index:idx_grant_subject"`
+	SubjectID   string       `json:"subjectId" gorm:"type:string;size:30;index:idx_grant_subject"`
+	// 数据集ID、路径前缀二选一，路径前缀按路径段匹配，如user匹配user、user/:id
+	DataSetID  string `json:"dataSetId" gorm:"type:string;size:30"`
+	PathPrefix string `json:"pathPrefix" gorm:"type:string;size:100"`
+}
+
+// TableName 表名
+func (Grant) TableName() string {
+	return "oh_grant"
+}
+
+// BeforeCreate 创建前
+func (g *Grant) BeforeCreate(tx *gorm.DB) error {
+	ctx := tx.Statement.Context
+	user := ctx.Value(util.UserID)
+	if user != nil {
+		if userID, ok := user.(string); ok {
+			g.CreatedBy = userID
+		}
+	}
+	return nil
+}
+
+// AuditEvent 审计事件，记录拒绝访问等
+type AuditEvent struct {
+	Entity
+	Action string `json:"action" gorm:"type:string;size:20;index"`
+	// 调用者，用户ID或app:应用ID
+	Caller    string `json:"caller" gorm:"type:string;size:50;index"`
+	Method    string `json:"method" gorm:"type:string;size:10"`
+	Path      string `json:"path" gorm:"type:string;size:200"`
+	DataSetID string `json:"dataSetId" gorm:"type:string;size:30"`
+	Reason    string `json:"reason" gorm:"type:string;size:200"`
+}
+
+// TableName 表名
+func (AuditEvent) TableName() string {
+	return "oh_audit_event"
+}
@@ -0,0 +1,50 @@
+package entity
+
+import (
+	"github.com/xuanbo/ohmydata/pkg/api/util"
+
+	"gorm.io/gorm"
+)
+
+// RateLimit 限流以及配额，按数据集、应用、IP配置，目标为*时作为该维度的默认配置
+type RateLimit struct {
+	Entity
+	Scope RateLimitScope `json:"scope" gorm:"type:string;size:10;uniqueIndex:idx_rate_limit_target"`
+	// 数据集ID、应用ID、IP或*
+	Target string `json:"target" gorm:"type:string;size:50;uniqueIndex:idx_rate_limit_target"`
+	// 每秒请求数以及突发请求数，0表示不限制
+	Rate  float64 `json:"rate"`
+	Burst uint    `json:"burst" gorm:"type:uint;size:10"`
+	// 每日、每月配额，0表示不限制
+	DailyQuota   uint `json:"dailyQuota" gorm:"type:uint;size:10"`
+	MonthlyQuota uint `json:"monthlyQuota" gorm:"type:uint;size:10"`
+}
+
+// TableName 表名
+func (RateLimit) TableName() string {
+	return "oh_rate_limit"
+}
+
+// BeforeCreate 创建前
+func (r *RateLimit) BeforeCreate(tx *gorm.DB) error {
+	ctx := tx.Statement.Context
+	user := ctx.Value(util.UserID)
+	if user != nil {
+		if userID, ok := user.(string); ok {
+			r.CreatedBy = userID
+		}
+	}
+	return nil
+}
+
+// BeforeUpdate 更新前
+func (r *RateLimit) BeforeUpdate(tx *gorm.DB) error {
+	ctx := tx.Statement.Context
+	user := ctx.Value(util.UserID)
+	if user != nil {
+		if userID, ok := user.(string); ok {
+			r.UpdatedBy = userID
+		}
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package entity
+
+import (
+	"github.com/xuanbo/ohmydata/pkg/api/util"
+
+	"gorm.io/gorm"
+)
+
+// UserGroup 用户组，用于批量授权
+type UserGroup struct {
+	Entity
+	Name        string `json:"name" gorm:"type:string;size:50"`
+	Description string `json:"description" gorm:"type:string;size:100"`
+}
+
+// TableName 表名
+func (UserGroup) TableName() string {
+	return "oh_user_group"
+}
+
+// BeforeCreate 创建前
+func (g *UserGroup) BeforeCreate(tx *gorm.DB) error {
+	ctx := tx.Statement.Context
+	user := ctx.Value(util.UserID)
+	if user != nil {
+		if userID, ok := user.(string); ok {
+			g.CreatedBy = userID
+		}
+	}
+	return nil
+}
+
+// BeforeUpdate 更新前
+func (g *UserGroup) BeforeUpdate(tx *gorm.DB) error {
+	ctx := tx.Statement.Context
+	user := ctx.Value(util.UserID)
+	if user != nil {
+		if userID, ok := user.(string); ok {
+			g.UpdatedBy = userID
+		}
+	}
+	return nil
+}
+
+// UserGroupMember 用户组成员
+type UserGroupMember struct {
+	Entity
+	GroupID string `json:"groupId" gorm:"type:string;size:30;index"`
+	UserID  string `json:"userId" gorm:"type:string;size:30;index"`
+}
+
+// TableName 表名
+func (UserGroupMember) TableName() string {
+	return "oh_user_group_member"
+}
+
+// BeforeCreate 创建前
+func (m *UserGroupMember) BeforeCreate(tx *gorm.DB) error {
+	ctx := tx.Statement.Context
+	user := ctx.Value(util.UserID)
+	if user != nil {
+		if userID, ok := user.(string); ok {
+			m.CreatedBy = userID
+		}
+	}
+	return nil
+}
@@ -10,8 +10,11 @@ import (
 
 var logger *zap.Logger
 
-// Init 初始化
-func Init() error {
+// Init 初始化，默认输出到标准输出
+func Init(outputs ...string) error {
+	if len(outputs) == 0 {
+		outputs = []string{"stdout"}
+	}
 	encoderConfig := zapcore.EncoderConfig{
 		TimeKey:    "time",
 		LevelKey:   "level",
@@ -49,11 +52,11 @@ func Init() error {
 	}
 
 	config := zap.Config{
-		Level:            atom,               // 日志级别
-		Development:      true,               // 开发模式，堆栈跟踪
-		Encoding:         format,             // 输出格式 console 或 json
-		EncoderConfig:    encoderConfig,      // 编码器配置
-		OutputPaths:      []string{"stdout"}, // 输出到指定文件 stdout（标准输出，正常颜色） stderr（错误输出，红色）
+		Level:            atom,          // 日志级别
+		Development:      true,          // 开发模式，堆栈跟踪
+		Encoding:         format,        // 输出格式 console 或 json
+		EncoderConfig:    encoderConfig, // 编码器配置
+		OutputPaths:      outputs,       // 输出到指定文件 stdout（标准输出，正常颜色） stderr（错误输出，红色）
 		ErrorOutputPaths: []string{"stderr"},
 	}
 
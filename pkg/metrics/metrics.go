@@ -0,0 +1,134 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "ohmydata"
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP请求数，route为路由模板",
+	}, []string{"method", "route", "status"})
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP请求耗时",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+	apiRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "api_requests_total",
+		Help:      "已发布API调用数，path为数据集路径",
+	}, []string{"method", "path", "status"})
+	apiDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "api_request_duration_seconds",
+		Help:      "已发布API调用耗时",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "path", "status"})
+	queryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "adapter_query_duration_seconds",
+		Help:      "数据源查询耗时，operation为query、exec",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"source", "operation"})
+	queryErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "adapter_query_errors_total",
+		Help:      "数据源查询错误数",
+	}, []string{"source", "operation"})
+	cacheRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "dataset_cache_requests_total",
+		Help:      "数据集结果缓存查询数，result为hit、miss",
+	}, []string{"path", "result"})
+	routerRoutes = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "router_routes",
+		Help:      "已加载的API路由数",
+	})
+	routerSyncDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "router_sync_duration_seconds",
+		Help:      "同步已发布数据集的耗时",
+		Buckets:   prometheus.DefBuckets,
+	})
+	routerSyncErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "router_sync_errors_total",
+		Help:      "同步已发布数据集的错误数",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(
+		httpRequests, httpDuration,
+		apiRequests, apiDuration,
+		queryDuration, queryErrors,
+		cacheRequests,
+		routerRoutes, routerSyncDuration, routerSyncErrors,
+	)
+}
+
+// Handler /metrics处理器
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// ObserveHTTP 记录HTTP请求
+func ObserveHTTP(method, route string, status int, d time.Duration) {
+	code := strconv.Itoa(status)
+	httpRequests.WithLabelValues(method, route, code).Inc()
+	httpDuration.WithLabelValues(method, route, code).Observe(d.Seconds())
+}
+
+// ObserveAPI 记录已发布API调用，未匹配到数据集时path为空
+func ObserveAPI(method, path string, status int, d time.Duration) {
+	if path == "" {
+		path = "unmatched"
+	}
+	code := strconv.Itoa(status)
+	apiRequests.WithLabelValues(method, path, code).Inc()
+	apiDuration.WithLabelValues(method, path, code).Observe(d.Seconds())
+}
+
+// ObserveQuery 记录数据源查询
+func ObserveQuery(source, operation string, d time.Duration, err error) {
+	queryDuration.WithLabelValues(source, operation).Observe(d.Seconds())
+	if err != nil {
+		queryErrors.WithLabelValues(source, operation).Inc()
+	}
+}
+
+// ObserveCache 记录数据集结果缓存是否命中
+func ObserveCache(path string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	cacheRequests.WithLabelValues(path, result).Inc()
+}
+
+// ObserveRouterSync 记录数据集同步耗时以及加载的路由数，出错时不更新路由数
+func ObserveRouterSync(routes int, d time.Duration, err error) {
+	routerSyncDuration.Observe(d.Seconds())
+	if err != nil {
+		routerSyncErrors.Inc()
+		return
+	}
+	routerRoutes.Set(float64(routes))
+}
+
+// RegisterDBStats 注册连接池指标，每次采集时调用stats获取各数据源的连接池状态
+func RegisterDBStats(stats func() map[string]sql.DBStats) error {
+	return prometheus.Register(newDBStatsCollector(stats))
+}
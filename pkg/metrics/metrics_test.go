@@ -0,0 +1,60 @@
+package metrics_test
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/xuanbo/ohmydata/pkg/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestMetrics(t *testing.T) {
+	metrics.ObserveHTTP("GET", "/v1/data-set/:id", 200, time.Millisecond)
+	metrics.ObserveAPI("GET", "user/:id", 200, time.Millisecond)
+	metrics.ObserveAPI("GET", "", 404, time.Millisecond)
+	metrics.ObserveQuery("1", "query", time.Millisecond, errors.New("timeout"))
+	metrics.ObserveCache("user/:id", true)
+	metrics.ObserveRouterSync(3, time.Millisecond, nil)
+	if err := metrics.RegisterDBStats(func() map[string]sql.DBStats {
+		return map[string]sql.DBStats{"metadata": {MaxOpenConnections: 10, InUse: 2}}
+	}); err != nil {
+		t.Error(err)
+		return
+	}
+
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	values := make(map[string]float64)
+	for _, family := range families {
+		for _, m := range family.GetMetric() {
+			switch {
+			case m.GetCounter() != nil:
+				values[family.GetName()] += m.GetCounter().GetValue()
+			case m.GetGauge() != nil:
+				values[family.GetName()] += m.GetGauge().GetValue()
+			case m.GetHistogram() != nil:
+				values[family.GetName()] += float64(m.GetHistogram().GetSampleCount())
+			}
+		}
+	}
+	for name, want := range map[string]float64{
+		"ohmydata_http_requests_total":          1,
+		"ohmydata_api_requests_total":           2,
+		"ohmydata_api_request_duration_seconds": 2,
+		"ohmydata_adapter_query_errors_total":   1,
+		"ohmydata_dataset_cache_requests_total": 1,
+		"ohmydata_router_routes":                3,
+		"ohmydata_db_max_open_connections":      10,
+		"ohmydata_db_in_use_connections":        2,
+	} {
+		if values[name] != want {
+			t.Errorf("%s = %v, want %v", name, values[name], want)
+		}
+	}
+}
@@ -1,6 +1,17 @@
 package model
 
-import "github.com/xuanbo/ohmydata/pkg/model/condition"
+import (
+	"errors"
+
+	"github.com/xuanbo/ohmydata/pkg/model/condition"
+)
+
+var (
+	// ErrMaxRows 超过最大行数
+	ErrMaxRows = errors.New("查询结果超过最大行数限制")
+	// ErrMaxBytes 超过最大字节数
+	ErrMaxBytes = errors.New("查询结果超过最大字节数限制")
+)
 
 // Pagination 分页
 type Pagination struct {
@@ -10,6 +21,9 @@ type Pagination struct {
 	Total  uint64            `json:"total"`
 	Clause *condition.Clause `json:"clause"`
 	Data   interface{}       `json:"data"`
+	// 查询限制，0表示不限制
+	MaxRows  uint64 `json:"-"`
+	MaxBytes uint64 `json:"-"`
 }
 
 // NewPagination 创建分页对象
@@ -29,3 +43,14 @@ func (p *Pagination) Set(total uint64, data interface{}) {
 	p.Total = total
 	p.Data = data
 }
+
+// Check 校验已读取的行数、字节数是否超过限制
+func (p *Pagination) Check(rows, bytes uint64) error {
+	if p.MaxRows > 0 && rows > p.MaxRows {
+		return ErrMaxRows
+	}
+	if p.MaxBytes > 0 && bytes > p.MaxBytes {
+		return ErrMaxBytes
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package model_test
+
+import (
+	"testing"
+
+	"github.com/xuanbo/ohmydata/pkg/model"
+)
+
+func TestNewPagination(t *testing.T) {
+	page := model.NewPagination(0, 0)
+	if page.Page != 1 || page.Size != 10 || page.Offset != 0 {
+		t.Errorf("默认分页错误: %+v", page)
+	}
+	page = model.NewPagination(3, 20)
+	if page.Offset != 40 {
+		t.Errorf("偏移量错误: %d", page.Offset)
+	}
+}
+
+func TestPaginationCheck(t *testing.T) {
+	page := model.NewPagination(1, 10)
+	if err := page.Check(1000000, 1000000); err != nil {
+		t.Errorf("未设置限制时不应报错: %v", err)
+	}
+
+	page.MaxRows = 10
+	page.MaxBytes = 100
+	cases := []struct {
+		rows, bytes uint64
+		err         error
+	}{
+		{10, 100, nil},
+		{11, 100, model.ErrMaxRows},
+		{10, 101, model.ErrMaxBytes},
+		{11, 101, model.ErrMaxRows},
+	}
+	for _, c := range cases {
+		if err := page.Check(c.rows, c.bytes); err != c.err {
+			t.Errorf("rows: %d, bytes: %d, expected: %v, actual: %v", c.rows, c.bytes, c.err, err)
+		}
+	}
+}
@@ -0,0 +1,338 @@
+package srv
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/xuanbo/ohmydata/pkg/cache"
+	"github.com/xuanbo/ohmydata/pkg/config"
+	"github.com/xuanbo/ohmydata/pkg/db"
+	orm "github.com/xuanbo/ohmydata/pkg/db/gorm"
+	"github.com/xuanbo/ohmydata/pkg/entity"
+	"github.com/xuanbo/ohmydata/pkg/model"
+	"github.com/xuanbo/ohmydata/pkg/model/condition"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// App 应用凭证服务
+type App struct {
+	db     *gorm.DB
+	engine *orm.Engine
+	// 密钥轮换后旧密钥的宽限期
+	rotateGrace time.Duration
+	// 签名请求允许的时间偏差，nonce在两倍偏差内不可重复
+	signSkew time.Duration
+}
+
+// AppCredential 应用凭证，密钥仅在新增、轮换时返回
+type AppCredential struct {
+	*entity.App
+	Secret string `json:"secret"`
+	// 长期token，格式为key.secret，请求头Authorization: App <token>
+	Token string `json:"token"`
+}
+
+// cachedApp 缓存的应用，实体不序列化密钥，单独缓存
+type cachedApp struct {
+	App            *entity.App `json:"app"`
+	Secret         string      `json:"secret"`
+	PreviousSecret string      `json:"previousSecret"`
+}
+
+// NewApp 创建实例
+func NewApp() *App {
+	return &App{
+		db:          db.DB(),
+		engine:      orm.New(db.DB()),
+		rotateGrace: time.Duration(config.GetInt("app.rotateGrace")) * time.Second,
+		signSkew:    time.Duration(config.GetInt("app.signSkew")) * time.Second,
+	}
+}
+
+// Create 新增，生成key、secret
+func (s *App) Create(ctx context.Context, app *entity.App) (*AppCredential, error) {
+	if app.Name == "" {
+		return nil, errors.New("应用名称不能为空")
+	}
+	key, err := randomHex(16)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := randomHex(32)
+	if err != nil {
+		return nil, err
+	}
+	app.ID = db.NewID()
+	app.Key = key
+	app.Secret = secret
+	app.PreviousSecret = ""
+	app.PreviousExpiresAt = nil
+	app.RotatedAt = nil
+	app.Revoked = false
+	app.RevokedAt = nil
+	if err := s.db.WithContext(ctx).Create(app).Error; err != nil {
+		return nil, err
+	}
+	return newAppCredential(app), nil
+}
+
+// Modify 修改名称、描述、是否必须签名以及过期时间，凭证通过轮换、吊销变更
+func (s *App) Modify(ctx context.Context, app *entity.App) error {
+	if app.ID == "" {
+		return errors.New("更新时主键不能为空")
+	}
+	if app.Name == "" {
+		return errors.New("应用名称不能为空")
+	}
+	old, err := s.find(ctx, app.ID)
+	if err != nil {
+		return err
+	}
+	old.Name = app.Name
+	old.Description = app.Description
+	old.SignatureRequired = app.SignatureRequired
+	old.ExpiresAt = app.ExpiresAt
+	if err := s.db.WithContext(ctx).Save(old).Error; err != nil {
+		return err
+	}
+	s.clearCache(ctx, old.Key)
+	*app = *old
+	return nil
+}
+
+// Remove 删除
+func (s *App) Remove(ctx context.Context, id string) error {
+	old, err := s.find(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.db.WithContext(ctx).Delete(&entity.App{}, "id = ?", id).Error; err != nil {
+		return err
+	}
+	s.clearCache(ctx, old.Key)
+	return nil
+}
+
+// ID 主键查询
+func (s *App) ID(ctx context.Context, id string) (*entity.App, error) {
+	var app entity.App
+	if err := s.db.WithContext(ctx).Where("id = ?", id).Find(&app).Error; err != nil {
+		return nil, err
+	}
+	if app.ID == "" {
+		return nil, nil
+	}
+	return &app, nil
+}
+
+// Page 分页查询
+func (s *App) Page(ctx context.Context, app *entity.App, page *model.Pagination) error {
+	var (
+		total uint64
+		list  []*entity.App
+		err   error
+	)
+	combineClause := condition.NewCombineClause(condition.CombineAnd)
+	if app.Name != "" {
+		combineClause.Add(condition.Like("name", app.Name))
+	}
+	if app.Key != "" {
+		combineClause.Add(condition.Eq("key", app.Key))
+	}
+	clause := condition.WrapCombineClause(combineClause)
+
+	if total, err = s.engine.Page(
+		app.TableName(),
+		&list,
+		selectOptionFunc.WithClause(clause),
+		selectOptionFunc.WithContext(ctx),
+		selectOptionFunc.WithPageSize(page.Page, page.Size),
+		selectOptionFunc.WithTablePrefix("`"),
+		selectOptionFunc.WithTableSuffix("`"),
+		selectOptionFunc.WithColumnPrefix("`"),
+		selectOptionFunc.WithColumnSuffix("`"),
+	); err != nil {
+		return err
+	}
+	if total < 1 {
+		return nil
+	}
+	page.Set(total, list)
+	return nil
+}
+
+// Rotate 轮换密钥，旧密钥在宽限期内仍可使用
+func (s *App) Rotate(ctx context.Context, id string) (*AppCredential, error) {
+	app, err := s.find(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if app.Revoked {
+		return nil, errors.New("应用已吊销，不能轮换密钥")
+	}
+	secret, err := randomHex(32)
+	if err != nil {
+		return nil, err
+	}
+	now := entity.Now()
+	app.PreviousSecret = ""
+	app.PreviousExpiresAt = nil
+	if s.rotateGrace > 0 {
+		app.PreviousSecret = app.Secret
+		app.PreviousExpiresAt = &entity.Time{Time: now.Add(s.rotateGrace)}
+	}
+	app.Secret = secret
+	app.RotatedAt = &now
+	if err := s.db.WithContext(ctx).Save(app).Error; err != nil {
+		return nil, err
+	}
+	s.clearCache(ctx, app.Key)
+	return newAppCredential(app), nil
+}
+
+// Revoke 吊销，立即失效且不可恢复
+func (s *App) Revoke(ctx context.Context, id string) (*entity.App, error) {
+	app, err := s.find(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if app.Revoked {
+		return app, nil
+	}
+	now := entity.Now()
+	app.Revoked = true
+	app.RevokedAt = &now
+	if err := s.db.WithContext(ctx).Save(app).Error; err != nil {
+		return nil, err
+	}
+	s.clearCache(ctx, app.Key)
+	return app, nil
+}
+
+// Authenticate 校验应用key、secret，未通过时返回401
+func (s *App) Authenticate(ctx context.Context, key, secret string) (*entity.App, error) {
+	if key == "" || secret == "" {
+		return nil, echo.NewHTTPError(http.StatusUnauthorized, "应用凭证不能为空")
+	}
+	cached, err := s.load(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if cached == nil {
+		return nil, echo.NewHTTPError(http.StatusUnauthorized, "应用凭证无效")
+	}
+	if err := checkApp(cached.App); err != nil {
+		return nil, err
+	}
+	if cached.App.SignatureRequired {
+		return nil, echo.NewHTTPError(http.StatusUnauthorized, "该应用必须使用签名请求")
+	}
+	if !cached.match(secret) {
+		return nil, echo.NewHTTPError(http.StatusUnauthorized, "应用凭证无效")
+	}
+	return cached.App, nil
+}
+
+// AuthenticateToken 校验长期token，格式为key.secret
+func (s *App) AuthenticateToken(ctx context.Context, token string) (*entity.App, error) {
+	i := strings.Index(token, ".")
+	if i < 0 {
+		return nil, echo.NewHTTPError(http.StatusUnauthorized, "应用token格式错误")
+	}
+	return s.Authenticate(ctx, token[:i], token[i+1:])
+}
+
+// find 主键查询，不存在时返回错误
+func (s *App) find(ctx context.Context, id string) (*entity.App, error) {
+	app, err := s.ID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if app == nil {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "应用不存在")
+	}
+	return app, nil
+}
+
+// load 按key查询应用，包含密钥
+func (s *App) load(ctx context.Context, key string) (*cachedApp, error) {
+	var (
+		cached cachedApp
+		k      = "ohmydata:app:key:" + key
+		err    error
+	)
+	if err = cache.Get(ctx, k, &cached); errors.Is(err, redis.Nil) {
+		// 查询db
+		var app entity.App
+		if err = s.db.WithContext(ctx).Where("`key` = ?", key).Find(&app).Error; err != nil {
+			return nil, err
+		}
+		if app.ID == "" {
+			return nil, nil
+		}
+		cached = cachedApp{App: &app, Secret: app.Secret, PreviousSecret: app.PreviousSecret}
+		// 写入缓存
+		cache.Set(ctx, k, &cached, cacheTTL)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &cached, nil
+}
+
+func (s *App) clearCache(ctx context.Context, key string) {
+	cache.Del(ctx, "ohmydata:app:key:"+key)
+}
+
+// secrets 当前有效的密钥，包含宽限期内的旧密钥
+func (c *cachedApp) secrets() []string {
+	secrets := []string{c.Secret}
+	expiresAt := c.App.PreviousExpiresAt
+	if c.PreviousSecret != "" && expiresAt != nil && time.Now().Before(expiresAt.Time) {
+		secrets = append(secrets, c.PreviousSecret)
+	}
+	return secrets
+}
+
+// match 常量时间比较密钥
+func (c *cachedApp) match(secret string) bool {
+	for _, s := range c.secrets() {
+		if subtle.ConstantTimeCompare([]byte(s), []byte(secret)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// checkApp 校验应用是否吊销、过期
+func checkApp(app *entity.App) error {
+	if app.Revoked {
+		return echo.NewHTTPError(http.StatusUnauthorized, "应用凭证已吊销")
+	}
+	if app.ExpiresAt != nil && !app.ExpiresAt.IsZero() && time.Now().After(app.ExpiresAt.Time) {
+		return echo.NewHTTPError(http.StatusUnauthorized, "应用凭证已过期")
+	}
+	return nil
+}
+
+func newAppCredential(app *entity.App) *AppCredential {
+	return &AppCredential{App: app, Secret: app.Secret, Token: app.Key + "." + app.Secret}
+}
+
+// randomHex 随机生成n字节的十六进制字符串
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -1,15 +1,45 @@
 package srv
 
 import (
+	"context"
+	"fmt"
 	"time"
 
+	"github.com/xuanbo/ohmydata/pkg/api/util"
 	"github.com/xuanbo/ohmydata/pkg/db/gorm"
 )
 
 const (
 	cacheTTL = 5 * time.Minute
+	// 写操作幂等记录保留时间
+	idempotencyTTL = 24 * time.Hour
 )
 
 var (
 	selectOptionFunc gorm.SelectOptionFunc
 )
+
+// 内部调用标记在context中的key
+type internalKey struct{}
+
+// WithInternal 标记为内部调用（如命令行执行数据集），跳过API授权校验
+func WithInternal(ctx context.Context) context.Context {
+	return context.WithValue(ctx, internalKey{}, true)
+}
+
+// internal 是否为内部调用
+func internal(ctx context.Context) bool {
+	ok, _ := ctx.Value(internalKey{}).(bool)
+	return ok
+}
+
+// caller 调用者，即当前登录用户ID，使用应用凭证调用时为app:应用ID
+func caller(ctx context.Context) string {
+	if v := ctx.Value(util.UserID); v != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	if v := ctx.Value(util.AppID); v != nil {
+		return fmt.Sprintf("app:%v", v)
+	}
+	return ""
+}
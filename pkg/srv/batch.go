@@ -0,0 +1,125 @@
+package srv
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/xuanbo/ohmydata/pkg/api/util"
+	"github.com/xuanbo/ohmydata/pkg/log"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+// 批量调用默认限制
+const (
+	defaultBatchMaxCalls    = 50
+	defaultBatchConcurrency = 8
+)
+
+// BatchCall 批量调用中的单个API
+type BatchCall struct {
+	// API路径，可带/api/前缀
+	Path string `json:"path"`
+	// 请求方法，默认POST
+	Method string                 `json:"method"`
+	Params map[string]interface{} `json:"params"`
+}
+
+// BatchResult 单个API的调用结果，与单独调用时的响应一致
+type BatchResult struct {
+	Path    string      `json:"path"`
+	Status  int         `json:"status"`
+	Success bool        `json:"success"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data"`
+}
+
+// ServeBatch 并发调用多个API，按请求顺序返回每个API的结果，单个API错误不影响其他API
+func (s *DataSet) ServeBatch(ctx context.Context, calls []*BatchCall) ([]*BatchResult, error) {
+	maxCalls := s.batchMaxCalls
+	if maxCalls <= 0 {
+		maxCalls = defaultBatchMaxCalls
+	}
+	concurrency := s.batchConcurrency
+	if concurrency <= 0 {
+		concurrency = defaultBatchConcurrency
+	}
+	if len(calls) == 0 {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "批量调用的API不能为空")
+	}
+	if len(calls) > maxCalls {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("批量调用的API不能超过%d个", maxCalls))
+	}
+
+	// 并发调用，不设置单个API的限流响应头
+	ctx = context.WithValue(ctx, util.ResponseHeader, nil)
+	var (
+		results = make([]*BatchResult, len(calls))
+		sem     = make(chan struct{}, concurrency)
+		wg      sync.WaitGroup
+	)
+	for i, call := range calls {
+		if call == nil {
+			call = &BatchCall{}
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, call *BatchCall) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			results[i] = s.serveBatchCall(ctx, call)
+		}(i, call)
+	}
+	wg.Wait()
+	return results, nil
+}
+
+// serveBatchCall 调用单个API，错误转为对应的响应状态
+func (s *DataSet) serveBatchCall(ctx context.Context, call *BatchCall) (result *BatchResult) {
+	result = &BatchResult{Path: call.Path, Status: http.StatusOK}
+	defer func() {
+		if r := recover(); r != nil {
+			log.Logger().Error("批量调用API错误", zap.String("path", call.Path), zap.Any("error", r))
+			result.Status = http.StatusInternalServerError
+			result.Success = false
+			result.Message = fmt.Sprintf("%v", r)
+			result.Data = nil
+		}
+	}()
+
+	path := strings.TrimPrefix(strings.TrimPrefix(call.Path, "/"), "api/")
+	if path == "" {
+		result.Status = http.StatusBadRequest
+		result.Message = "API路径不能为空"
+		return result
+	}
+	method := strings.ToUpper(call.Method)
+	if method == "" {
+		method = http.MethodPost
+	}
+	// 每个API使用独立的参数，避免分页等处理相互影响
+	params := make(map[string]interface{}, len(call.Params))
+	for k, v := range call.Params {
+		params[k] = v
+	}
+
+	data, err := s.ServeAPI(ctx, method, path, params)
+	if err != nil {
+		if he, ok := err.(*echo.HTTPError); ok {
+			result.Status = he.Code
+			result.Message = fmt.Sprintf("%s", he.Message)
+		} else {
+			result.Message = err.Error()
+		}
+		return result
+	}
+	result.Success = true
+	result.Data = data
+	return result
+}
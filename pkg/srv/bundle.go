@@ -0,0 +1,760 @@
+package srv
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/xuanbo/ohmydata/pkg/db"
+	"github.com/xuanbo/ohmydata/pkg/entity"
+	"github.com/xuanbo/ohmydata/pkg/log"
+	"github.com/xuanbo/ohmydata/pkg/strutil"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v2"
+	"gorm.io/gorm"
+)
+
+// 文档格式
+const (
+	BundleYAML = "yaml"
+	BundleJSON = "json"
+)
+
+// 数据源密码导出方式
+const (
+	// SecretRedact 置空，导入时保留目标环境已有的密码
+	SecretRedact = "redact"
+	// SecretReference 引用环境变量，导入时从环境变量读取
+	SecretReference = "reference"
+)
+
+// 名称、路径冲突处理方式
+const (
+	// ConflictFail 存在冲突时不导入
+	ConflictFail = "fail"
+	// ConflictSkip 跳过冲突项
+	ConflictSkip = "skip"
+	// ConflictOverwrite 覆盖已有项
+	ConflictOverwrite = "overwrite"
+)
+
+// 导入变更
+const (
+	ChangeCreate    = "create"
+	ChangeUpdate    = "update"
+	ChangeUnchanged = "unchanged"
+	ChangeSkip      = "skip"
+	ChangeConflict  = "conflict"
+	ChangeDelete    = "delete"
+)
+
+// bundleVersion 文档格式版本
+const bundleVersion = 1
+
+// secretRefRegexp 密码引用环境变量，如${OHMYDATA_DATASOURCE_TEST_PASSWORD}
+var secretRefRegexp = regexp.MustCompile(`^\$\{([A-Za-z_][A-Za-z0-9_]*)\}$`)
+
+// errDryRun 试运行时回滚事务
+var errDryRun = errors.New("dry run")
+
+// BundleDoc 可移植的数据源、数据集文档，不包含主键，数据集按名称引用数据源
+type BundleDoc struct {
+	Version     int                 `json:"version"`
+	DataSources []*BundleDataSource `json:"dataSources"`
+	DataSets    []*BundleDataSet    `json:"dataSets"`
+}
+
+// BundleDataSource 文档中的数据源
+type BundleDataSource struct {
+	Type        string `json:"type"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	URL         string `json:"url"`
+	Username    string `json:"username,omitempty"`
+	// 置空或引用环境变量
+	Password     string `json:"password,omitempty"`
+	MaxIdleConns int    `json:"maxIdleConns,omitempty"`
+	MaxOpenConns int    `json:"maxOpenConns,omitempty"`
+	AllowWrite   bool   `json:"allowWrite,omitempty"`
+	ReadOnly     bool   `json:"readOnly,omitempty"`
+}
+
+// BundleDataSet 文档中的数据集
+type BundleDataSet struct {
+	// 数据源名称
+	Source         string             `json:"source"`
+	Name           string             `json:"name"`
+	Description    string             `json:"description,omitempty"`
+	Path           string             `json:"path"`
+	Kind           entity.DataSetKind `json:"kind"`
+	Method         string             `json:"method,omitempty"`
+	Expression     string             `json:"expression"`
+	EnablePage     bool               `json:"enablePage,omitempty"`
+	BatchLimit     uint               `json:"batchLimit,omitempty"`
+	EnableCache    bool               `json:"enableCache,omitempty"`
+	ExpireSeconds  uint               `json:"expireSeconds,omitempty"`
+	TimeoutSeconds uint               `json:"timeoutSeconds,omitempty"`
+	MaxRows        uint               `json:"maxRows,omitempty"`
+	MaxBytes       uint               `json:"maxBytes,omitempty"`
+	// 发布状态，仅GitOps同步时生效，导入时忽略
+	Published bool `json:"published,omitempty"`
+
+	RequestParams  []*BundleRequestParam  `json:"requestParams,omitempty"`
+	ResponseParams []*BundleResponseParam `json:"responseParams,omitempty"`
+}
+
+// BundleRequestParam 文档中的请求参数
+type BundleRequestParam struct {
+	Name          string               `json:"name"`
+	Description   string               `json:"description,omitempty"`
+	ParamLocation entity.ParamLocation `json:"paramLocation"`
+	ParamType     entity.ParamType     `json:"paramType"`
+	Required      bool                 `json:"required,omitempty"`
+	DefaultValue  string               `json:"defaultValue,omitempty"`
+}
+
+// BundleResponseParam 文档中的响应参数
+type BundleResponseParam struct {
+	Name         string             `json:"name"`
+	Description  string             `json:"description,omitempty"`
+	ParamType    entity.ParamType   `json:"paramType"`
+	ConvertType  entity.ConvertType `json:"convertType,omitempty"`
+	ConvertValue string             `json:"convertValue,omitempty"`
+}
+
+// BundleExport 导出选项，未选择时导出所有
+type BundleExport struct {
+	DataSourceIDs []string `json:"dataSourceIds"`
+	// 数据集引用的数据源一并导出
+	DataSetIDs []string `json:"dataSetIds"`
+	// 密码导出方式：redact、reference，默认redact
+	Secrets string `json:"secrets"`
+}
+
+// BundleImport 导入选项
+type BundleImport struct {
+	// 试运行，仅返回变更不保存
+	DryRun bool `json:"dryRun"`
+	// 冲突处理：fail、skip、overwrite，默认fail
+	OnConflict string `json:"onConflict"`
+}
+
+// ImportResult 导入结果
+type ImportResult struct {
+	DryRun  bool            `json:"dryRun"`
+	Changes []*BundleChange `json:"changes"`
+}
+
+// BundleChange 导入的变更项
+type BundleChange struct {
+	// dataSource、dataSet
+	Type   string `json:"type"`
+	Name   string `json:"name"`
+	Path   string `json:"path,omitempty"`
+	Action string `json:"action"`
+	// 冲突、跳过的原因或提示
+	Reason string `json:"reason,omitempty"`
+	// 更新时的差异
+	Fields         []*FieldChange `json:"fields,omitempty"`
+	RequestParams  []*FieldChange `json:"requestParams,omitempty"`
+	ResponseParams []*FieldChange `json:"responseParams,omitempty"`
+
+	dataSource *entity.DataSource
+	dataSet    *entity.DataSet
+	// 匹配的已有项由GitOps目录管理
+	managed bool
+	// 文档定义的发布状态，仅GitOps同步时使用
+	publish bool
+}
+
+// Bundle 数据源、数据集导入导出服务
+type Bundle struct {
+	db         *gorm.DB
+	dataSource *DataSource
+	dataSet    *DataSet
+}
+
+// NewBundle 创建实例
+func NewBundle(dataSource *DataSource, dataSet *DataSet) *Bundle {
+	return &Bundle{
+		db:         db.DB(),
+		dataSource: dataSource,
+		dataSet:    dataSet,
+	}
+}
+
+// Export 导出选择的数据源、数据集（当前版本）以及参数
+func (s *Bundle) Export(ctx context.Context, export *BundleExport) (*BundleDoc, error) {
+	secrets := export.Secrets
+	if secrets == "" {
+		secrets = SecretRedact
+	}
+	if secrets != SecretRedact && secrets != SecretReference {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "不支持的密码导出方式: "+secrets)
+	}
+
+	var dataSources []*entity.DataSource
+	if err := s.db.WithContext(ctx).Order("id").Find(&dataSources).Error; err != nil {
+		return nil, err
+	}
+	dataSourceMap := make(map[string]*entity.DataSource, len(dataSources))
+	for _, e := range dataSources {
+		dataSourceMap[e.ID] = e
+	}
+
+	var (
+		dataSourceIDs = append([]string{}, export.DataSourceIDs...)
+		dataSetIDs    = export.DataSetIDs
+	)
+	if len(dataSourceIDs) == 0 && len(dataSetIDs) == 0 {
+		for _, e := range dataSources {
+			dataSourceIDs = append(dataSourceIDs, e.ID)
+		}
+		if err := s.db.WithContext(ctx).Model(&entity.DataSet{}).Order("id").Pluck("id", &dataSetIDs).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	doc := &BundleDoc{
+		Version:     bundleVersion,
+		DataSources: make([]*BundleDataSource, 0, len(dataSourceIDs)),
+		DataSets:    make([]*BundleDataSet, 0, len(dataSetIDs)),
+	}
+	dataSets := make([]*entity.DataSet, 0, len(dataSetIDs))
+	for _, id := range dataSetIDs {
+		dataSet, err := s.dataSet.Detail(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if dataSet == nil {
+			return nil, fmt.Errorf("数据集不存在: %s", id)
+		}
+		dataSets = append(dataSets, dataSet)
+		if !strutil.EqualAny(dataSet.SourceID, dataSourceIDs) {
+			dataSourceIDs = append(dataSourceIDs, dataSet.SourceID)
+		}
+	}
+	for _, id := range dataSourceIDs {
+		dataSource, ok := dataSourceMap[id]
+		if !ok {
+			return nil, fmt.Errorf("数据源不存在: %s", id)
+		}
+		doc.DataSources = append(doc.DataSources, exportDataSource(dataSource, secrets))
+	}
+	for _, dataSet := range dataSets {
+		doc.DataSets = append(doc.DataSets, exportDataSet(dataSet, dataSourceMap[dataSet.SourceID].Name))
+	}
+	return doc, nil
+}
+
+// Import 导入文档：数据源按名称匹配，数据集按名称、请求路径匹配，在同一事务中保存，试运行时回滚。
+// 导入或覆盖的数据集为草稿，需审核后发布
+func (s *Bundle) Import(ctx context.Context, doc *BundleDoc, opt *BundleImport) (*ImportResult, error) {
+	onConflict := opt.OnConflict
+	if onConflict == "" {
+		onConflict = ConflictFail
+	}
+	if !strutil.EqualAny(onConflict, []string{ConflictFail, ConflictSkip, ConflictOverwrite}) {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "不支持的冲突处理方式: "+onConflict)
+	}
+	if doc.Version > bundleVersion {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("不支持的文档版本: %d", doc.Version))
+	}
+
+	changes, err := s.planDataSources(ctx, doc, onConflict)
+	if err != nil {
+		return nil, err
+	}
+	dataSetChanges, err := s.planDataSets(ctx, doc, onConflict, changes)
+	if err != nil {
+		return nil, err
+	}
+	changes = append(changes, dataSetChanges...)
+	for _, change := range changes {
+		if change.managed && change.Action == ChangeUpdate {
+			change.Action = ChangeConflict
+			change.Reason = "由GitOps目录管理，不允许导入覆盖"
+		}
+	}
+
+	if !opt.DryRun {
+		conflicts := make([]string, 0)
+		for _, change := range changes {
+			if change.Action == ChangeConflict {
+				conflicts = append(conflicts, fmt.Sprintf("%s(%s)", change.Name, change.Reason))
+			}
+		}
+		if len(conflicts) > 0 {
+			return nil, echo.NewHTTPError(http.StatusConflict, "存在冲突: "+strings.Join(conflicts, ", "))
+		}
+	}
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := applyChanges(tx, changes); err != nil {
+			return err
+		}
+		if opt.DryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errDryRun) {
+		return nil, err
+	}
+
+	if !opt.DryRun {
+		s.dataSource.clearCache(ctx, "all")
+		s.dataSet.clearCache(ctx, "all")
+		for _, change := range changes {
+			if change.Action != ChangeCreate && change.Action != ChangeUpdate {
+				continue
+			}
+			if change.dataSource != nil {
+				s.dataSource.clearCache(ctx, change.dataSource.ID)
+				// 已保存，驱动加载失败仅记录日志
+				putAdapter(change.dataSource)
+			} else {
+				s.dataSet.clearCache(ctx, change.dataSet.ID)
+			}
+			log.Logger().Info("导入", zap.String("type", change.Type), zap.String("name", change.Name), zap.String("action", change.Action))
+		}
+	}
+	return &ImportResult{DryRun: opt.DryRun, Changes: changes}, nil
+}
+
+// planDataSources 按名称匹配已有数据源
+func (s *Bundle) planDataSources(ctx context.Context, doc *BundleDoc, onConflict string) ([]*BundleChange, error) {
+	var existing []*entity.DataSource
+	if err := s.db.WithContext(ctx).Find(&existing).Error; err != nil {
+		return nil, err
+	}
+	existingMap := make(map[string]*entity.DataSource, len(existing))
+	for _, e := range existing {
+		existingMap[e.Name] = e
+	}
+
+	changes := make([]*BundleChange, 0, len(doc.DataSources))
+	for _, e := range doc.DataSources {
+		for _, change := range changes {
+			if change.Name == e.Name {
+				return nil, echo.NewHTTPError(http.StatusBadRequest, "数据源名称重复: "+e.Name)
+			}
+		}
+		dataSource := e.entity()
+		if err := validDataSource(dataSource); err != nil {
+			return nil, fmt.Errorf("数据源%s: %w", e.Name, err)
+		}
+		change := &BundleChange{Type: "dataSource", Name: e.Name, dataSource: dataSource}
+		changes = append(changes, change)
+
+		password, env := resolveSecret(e.Password)
+		dataSource.Password = password
+		current, ok := existingMap[e.Name]
+		if !ok {
+			dataSource.ID = db.NewID()
+			change.Action = ChangeCreate
+			// 不以空密码创建
+			if env != "" && password == "" {
+				change.Action = ChangeConflict
+				if onConflict == ConflictSkip {
+					change.Action = ChangeSkip
+				}
+				change.Reason = fmt.Sprintf("密码引用的环境变量%s未设置", env)
+			}
+			continue
+		}
+
+		dataSource.ID = current.ID
+		change.managed = current.Managed
+		// 未提供密码时保留已有密码
+		if dataSource.Password == "" {
+			dataSource.Password = current.Password
+		}
+		fields, err := diffFields(current, dataSource)
+		if err != nil {
+			return nil, err
+		}
+		for _, field := range fields {
+			if field.Field == "password" {
+				field.From, field.To = "******", "******"
+			}
+		}
+		change.Fields = fields
+		if len(fields) == 0 {
+			change.Action = ChangeUnchanged
+			continue
+		}
+		resolveConflict(change, onConflict, "数据源名称已存在")
+	}
+	return changes, nil
+}
+
+// planDataSets 按名称匹配已有数据集，名称未匹配时按请求路径匹配
+func (s *Bundle) planDataSets(ctx context.Context, doc *BundleDoc, onConflict string, dataSourceChanges []*BundleChange) ([]*BundleChange, error) {
+	var existing []*entity.DataSet
+	if err := s.db.WithContext(ctx).Find(&existing).Error; err != nil {
+		return nil, err
+	}
+	var dataSources []*entity.DataSource
+	if err := s.db.WithContext(ctx).Find(&dataSources).Error; err != nil {
+		return nil, err
+	}
+	// 数据源名称对应的主键，文档中的数据源优先
+	sourceIDs := make(map[string]string, len(dataSources)+len(dataSourceChanges))
+	for _, e := range dataSources {
+		sourceIDs[e.Name] = e.ID
+	}
+	for _, change := range dataSourceChanges {
+		// 跳过的数据源使用已有记录
+		if change.Action == ChangeSkip {
+			continue
+		}
+		sourceIDs[change.Name] = change.dataSource.ID
+	}
+
+	changes := make([]*BundleChange, 0, len(doc.DataSets))
+	for _, e := range doc.DataSets {
+		sourceID, ok := sourceIDs[e.Source]
+		if !ok {
+			return nil, fmt.Errorf("数据集%s的数据源不存在: %s", e.Name, e.Source)
+		}
+		dataSet := e.entity(sourceID)
+		change := &BundleChange{Type: "dataSet", Name: e.Name, Path: dataSet.Path, dataSet: dataSet, publish: e.Published}
+		changes = append(changes, change)
+
+		var target, pathOwner *entity.DataSet
+		for _, current := range existing {
+			if current.Name == dataSet.Name {
+				target = current
+			}
+		}
+		for _, current := range existing {
+			if current != target && current.Path == dataSet.Path && current.Kind == dataSet.Kind &&
+				(dataSet.Kind != entity.KindWrite || current.Method == dataSet.Method) {
+				pathOwner = current
+			}
+		}
+
+		var reason string
+		switch {
+		case target == nil && pathOwner == nil:
+			dataSet.ID = db.NewID()
+			change.Action = ChangeCreate
+			continue
+		case target != nil && pathOwner != nil:
+			// 名称、路径分别属于不同数据集，无法覆盖
+			change.Action = ChangeConflict
+			if onConflict == ConflictSkip {
+				change.Action = ChangeSkip
+			}
+			change.Reason = fmt.Sprintf("数据集名称已存在，请求路径已被数据集%s使用", pathOwner.Name)
+			continue
+		case target == nil:
+			target = pathOwner
+			reason = fmt.Sprintf("请求路径已被数据集%s使用", pathOwner.Name)
+		default:
+			reason = "数据集名称已存在"
+		}
+
+		current, err := s.dataSet.Detail(ctx, target.ID)
+		if err != nil {
+			return nil, err
+		}
+		dataSet.ID = target.ID
+		change.managed = target.Managed
+		if err := diffDataSet(change, current, dataSet); err != nil {
+			return nil, err
+		}
+		if len(change.Fields) == 0 && len(change.RequestParams) == 0 && len(change.ResponseParams) == 0 {
+			change.Action = ChangeUnchanged
+			continue
+		}
+		resolveConflict(change, onConflict, reason)
+	}
+	return changes, nil
+}
+
+// applyChanges 事务中保存新增、更新项，数据集复用新增、修改时的校验
+func applyChanges(tx *gorm.DB, changes []*BundleChange) error {
+	for _, change := range changes {
+		if change.Action != ChangeCreate && change.Action != ChangeUpdate {
+			continue
+		}
+		if change.dataSource != nil {
+			var err error
+			if change.Action == ChangeCreate {
+				err = tx.Create(change.dataSource).Error
+			} else {
+				err = tx.Save(change.dataSource).Error
+			}
+			if err != nil {
+				return fmt.Errorf("数据源%s: %w", change.Name, err)
+			}
+			continue
+		}
+		if err := validDataSetTx(tx, change.dataSet); err != nil {
+			return fmt.Errorf("数据集%s: %w", change.Name, err)
+		}
+		var err error
+		if change.Action == ChangeCreate {
+			err = createDataSet(tx, change.dataSet)
+		} else {
+			err = modifyDataSet(tx, change.dataSet)
+		}
+		if err != nil {
+			return fmt.Errorf("数据集%s: %w", change.Name, err)
+		}
+	}
+	return nil
+}
+
+// resolveConflict 已存在且不同的项按冲突处理方式处理
+func resolveConflict(change *BundleChange, onConflict, reason string) {
+	switch onConflict {
+	case ConflictOverwrite:
+		change.Action = ChangeUpdate
+	case ConflictSkip:
+		change.Action = ChangeSkip
+	default:
+		change.Action = ChangeConflict
+	}
+	change.Reason = reason
+}
+
+func diffDataSet(change *BundleChange, from, to *entity.DataSet) error {
+	var err error
+	if change.Fields, err = diffFields(from, to); err != nil {
+		return err
+	}
+	var fromParams, toParams []interface{}
+	for _, e := range from.RequestParams {
+		fromParams = append(fromParams, e)
+	}
+	for _, e := range to.RequestParams {
+		toParams = append(toParams, e)
+	}
+	if change.RequestParams, err = diffParams(fromParams, toParams); err != nil {
+		return err
+	}
+	fromParams, toParams = nil, nil
+	for _, e := range from.ResponseParams {
+		fromParams = append(fromParams, e)
+	}
+	for _, e := range to.ResponseParams {
+		toParams = append(toParams, e)
+	}
+	change.ResponseParams, err = diffParams(fromParams, toParams)
+	return err
+}
+
+// resolveSecret 解析密码引用的环境变量，返回密码以及引用的环境变量名
+func resolveSecret(password string) (string, string) {
+	if m := secretRefRegexp.FindStringSubmatch(password); m != nil {
+		return os.Getenv(m[1]), m[1]
+	}
+	return password, ""
+}
+
+// secretRef 数据源密码引用的环境变量，如OHMYDATA_DATASOURCE_TEST_PASSWORD
+func secretRef(name string) string {
+	ref := strings.Map(func(r rune) rune {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, strings.ToUpper(name))
+	return "${OHMYDATA_DATASOURCE_" + ref + "_PASSWORD}"
+}
+
+func exportDataSource(dataSource *entity.DataSource, secrets string) *BundleDataSource {
+	e := &BundleDataSource{
+		Type:         dataSource.Type,
+		Name:         dataSource.Name,
+		Description:  dataSource.Description,
+		URL:          dataSource.URL,
+		Username:     dataSource.Username,
+		MaxIdleConns: dataSource.MaxIdleConns,
+		MaxOpenConns: dataSource.MaxOpenConns,
+		AllowWrite:   dataSource.AllowWrite,
+		ReadOnly:     dataSource.ReadOnly,
+	}
+	if secrets == SecretReference && dataSource.Password != "" {
+		e.Password = secretRef(dataSource.Name)
+	}
+	return e
+}
+
+func exportDataSet(dataSet *entity.DataSet, source string) *BundleDataSet {
+	e := &BundleDataSet{
+		Source:         source,
+		Name:           dataSet.Name,
+		Description:    dataSet.Description,
+		Path:           dataSet.Path,
+		Kind:           dataSet.Kind,
+		Method:         dataSet.Method,
+		Expression:     dataSet.Expression,
+		EnablePage:     dataSet.EnablePage,
+		BatchLimit:     dataSet.BatchLimit,
+		EnableCache:    dataSet.EnableCache,
+		ExpireSeconds:  dataSet.ExpireSeconds,
+		TimeoutSeconds: dataSet.TimeoutSeconds,
+		MaxRows:        dataSet.MaxRows,
+		MaxBytes:       dataSet.MaxBytes,
+		Published:      dataSet.PublishStatus,
+	}
+	for _, p := range dataSet.RequestParams {
+		e.RequestParams = append(e.RequestParams, &BundleRequestParam{
+			Name:          p.Name,
+			Description:   p.Description,
+			ParamLocation: p.ParamLocation,
+			ParamType:     p.ParamType,
+			Required:      p.Required,
+			DefaultValue:  p.DefaultValue,
+		})
+	}
+	for _, p := range dataSet.ResponseParams {
+		e.ResponseParams = append(e.ResponseParams, &BundleResponseParam{
+			Name:         p.Name,
+			Description:  p.Description,
+			ParamType:    p.ParamType,
+			ConvertType:  p.ConvertType,
+			ConvertValue: p.ConvertValue,
+		})
+	}
+	return e
+}
+
+// DataSource 文档中的数据源定义，密码引用环境变量时从环境变量读取，环境变量未设置时返回错误
+func (e *BundleDataSource) DataSource() (*entity.DataSource, error) {
+	dataSource := e.entity()
+	password, env := resolveSecret(e.Password)
+	if env != "" && password == "" {
+		return nil, fmt.Errorf("数据源%s的密码引用的环境变量%s未设置", e.Name, env)
+	}
+	dataSource.Password = password
+	return dataSource, nil
+}
+
+func (e *BundleDataSource) entity() *entity.DataSource {
+	return &entity.DataSource{
+		Type:         e.Type,
+		Name:         e.Name,
+		Description:  e.Description,
+		URL:          e.URL,
+		Username:     e.Username,
+		MaxIdleConns: e.MaxIdleConns,
+		MaxOpenConns: e.MaxOpenConns,
+		AllowWrite:   e.AllowWrite,
+		ReadOnly:     e.ReadOnly,
+	}
+}
+
+func (e *BundleDataSet) entity(sourceID string) *entity.DataSet {
+	dataSet := &entity.DataSet{
+		SourceID:       sourceID,
+		Name:           e.Name,
+		Description:    e.Description,
+		Path:           strings.TrimPrefix(e.Path, "/"),
+		Kind:           e.Kind,
+		Method:         strings.ToUpper(e.Method),
+		Expression:     e.Expression,
+		EnablePage:     e.EnablePage,
+		BatchLimit:     e.BatchLimit,
+		EnableCache:    e.EnableCache,
+		ExpireSeconds:  e.ExpireSeconds,
+		TimeoutSeconds: e.TimeoutSeconds,
+		MaxRows:        e.MaxRows,
+		MaxBytes:       e.MaxBytes,
+	}
+	if dataSet.Kind == entity.KindQuery {
+		dataSet.Method = ""
+	}
+	for _, p := range e.RequestParams {
+		dataSet.RequestParams = append(dataSet.RequestParams, &entity.RequestParam{
+			Name:          p.Name,
+			Description:   p.Description,
+			ParamLocation: p.ParamLocation,
+			ParamType:     p.ParamType,
+			Required:      p.Required,
+			DefaultValue:  p.DefaultValue,
+		})
+	}
+	for _, p := range e.ResponseParams {
+		dataSet.ResponseParams = append(dataSet.ResponseParams, &entity.ResponseParam{
+			Name:         p.Name,
+			Description:  p.Description,
+			ParamType:    p.ParamType,
+			ConvertType:  p.ConvertType,
+			ConvertValue: p.ConvertValue,
+		})
+	}
+	return dataSet
+}
+
+// EncodeBundle 编码文档，yaml经由json转换，字段名以及顺序与json一致
+func EncodeBundle(doc *BundleDoc, format string) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return nil, err
+	}
+	switch format {
+	case BundleJSON:
+		return buf.Bytes(), nil
+	case "", BundleYAML:
+		var v yaml.MapSlice
+		if err := yaml.Unmarshal(buf.Bytes(), &v); err != nil {
+			return nil, err
+		}
+		return yaml.Marshal(v)
+	}
+	return nil, echo.NewHTTPError(http.StatusBadRequest, "不支持的文档格式: "+format)
+}
+
+// DecodeBundle 解码yaml或json文档
+func DecodeBundle(b []byte) (*BundleDoc, error) {
+	b = bytes.TrimSpace(b)
+	if !bytes.HasPrefix(b, []byte("{")) {
+		var v interface{}
+		if err := yaml.Unmarshal(b, &v); err != nil {
+			return nil, echo.NewHTTPError(http.StatusBadRequest, "文档格式错误: "+err.Error())
+		}
+		var err error
+		if b, err = json.Marshal(jsonValue(v)); err != nil {
+			return nil, echo.NewHTTPError(http.StatusBadRequest, "文档格式错误: "+err.Error())
+		}
+	}
+	var doc BundleDoc
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "文档格式错误: "+err.Error())
+	}
+	return &doc, nil
+}
+
+// jsonValue yaml解析的map转为json可编码的map
+func jsonValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[fmt.Sprintf("%v", k)] = jsonValue(e)
+		}
+		return m
+	case []interface{}:
+		for i, e := range v {
+			v[i] = jsonValue(e)
+		}
+		return v
+	}
+	return v
+}
@@ -0,0 +1,644 @@
+package srv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/xuanbo/ohmydata/pkg/api/util"
+	"github.com/xuanbo/ohmydata/pkg/config"
+	"github.com/xuanbo/ohmydata/pkg/db"
+	orm "github.com/xuanbo/ohmydata/pkg/db/gorm"
+	"github.com/xuanbo/ohmydata/pkg/entity"
+	"github.com/xuanbo/ohmydata/pkg/log"
+	"github.com/xuanbo/ohmydata/pkg/metrics"
+	"github.com/xuanbo/ohmydata/pkg/model"
+	"github.com/xuanbo/ohmydata/pkg/model/condition"
+	"github.com/xuanbo/ohmydata/pkg/tracing"
+
+	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel/semconv"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// 调用记录默认配置
+const (
+	defaultCallLogBatchSize     = 200
+	defaultCallLogFlushInterval = 5 * time.Second
+	defaultCallLogRetentionDays = 30
+	callLogQueueSize            = 10000
+	// 时间段开始时间的格式
+	callBucketLayout = "2006-01-02 15:04:05"
+	// 统计的最大时间范围
+	maxCallStatsRange = 366 * 24 * time.Hour
+)
+
+// 耗时直方图区间的上界，单位毫秒，超过最大上界的耗时为最后一个区间
+var latencyBounds = [...]int64{5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000, 30000, 60000}
+
+// 调用记录在context中的key
+type apiCallKey struct{}
+
+// 统计的时间粒度
+const (
+	CallBucketHour = "hour"
+	CallBucketDay  = "day"
+)
+
+// CallSink 调用记录存储，默认写入元数据库，可通过RegisterCallSink注册其他实现
+type CallSink interface {
+	Write(ctx context.Context, calls []*entity.APICall) error
+}
+
+var (
+	callQueue      = make(chan *entity.APICall, callLogQueueSize)
+	callLogRunning int32
+	callSinks      = struct {
+		sync.RWMutex
+		store map[string]CallSink
+	}{store: map[string]CallSink{"log": logCallSink{}}}
+)
+
+// RegisterCallSink 注册调用记录存储，通过配置callLog.sink选择
+func RegisterCallSink(name string, sink CallSink) {
+	callSinks.Lock()
+	defer callSinks.Unlock()
+	callSinks.store[name] = sink
+}
+
+// dbCallSink 批量写入元数据库，统计查询基于该存储
+type dbCallSink struct {
+	db *gorm.DB
+}
+
+// Write 批量写入
+func (s dbCallSink) Write(ctx context.Context, calls []*entity.APICall) error {
+	return s.db.WithContext(ctx).CreateInBatches(calls, len(calls)).Error
+}
+
+// logCallSink 写入日志
+type logCallSink struct{}
+
+// Write 逐条输出日志
+func (logCallSink) Write(ctx context.Context, calls []*entity.APICall) error {
+	for _, call := range calls {
+		log.Logger().Info("API调用", zap.String("dataSetId", call.DataSetID), zap.String("method", call.Method),
+			zap.String("path", call.Path), zap.String("caller", call.Caller), zap.String("ip", call.IP),
+			zap.Int("status", call.Status), zap.Bool("success", call.Success), zap.Int64("latency", call.Latency),
+			zap.Int64("rowCount", call.RowCount), zap.Bool("cacheHit", call.CacheHit), zap.String("error", call.Error))
+	}
+	return nil
+}
+
+// CallLog API调用记录以及统计服务
+type CallLog struct {
+	db     *gorm.DB
+	engine *orm.Engine
+	// 开启后异步记录调用
+	enabled bool
+	// 存储名称，默认db
+	sink          string
+	batchSize     int
+	flushInterval time.Duration
+	// 保留天数，默认30天
+	retentionDays int
+}
+
+// CallStatsQuery 调用统计条件
+type CallStatsQuery struct {
+	DataSetID string `json:"dataSetId"`
+	Caller    string `json:"caller"`
+	// 时间范围，默认最近7天
+	Start *entity.Time `json:"start"`
+	End   *entity.Time `json:"end"`
+	// 时间粒度：hour、day，默认day
+	Bucket string `json:"bucket"`
+	// 调用者排行数量，默认10
+	Top int `json:"top"`
+}
+
+// CallMetric 调用指标，耗时单位毫秒
+type CallMetric struct {
+	Calls     int64   `json:"calls"`
+	Errors    int64   `json:"errors"`
+	ErrorRate float64 `json:"errorRate"`
+	CacheHits int64   `json:"cacheHits"`
+	P50       int64   `json:"p50"`
+	P95       int64   `json:"p95"`
+	P99       int64   `json:"p99"`
+}
+
+// CallBucket 时间段内的调用指标
+type CallBucket struct {
+	Time entity.Time `json:"time"`
+	CallMetric
+}
+
+// DataSetCallStat 数据集的调用指标
+type DataSetCallStat struct {
+	DataSetID string `json:"dataSetId"`
+	CallMetric
+}
+
+// CallerStat 调用者的调用指标
+type CallerStat struct {
+	Caller string `json:"caller"`
+	CallMetric
+}
+
+// CallStats 调用统计
+type CallStats struct {
+	Start  entity.Time `json:"start"`
+	End    entity.Time `json:"end"`
+	Bucket string      `json:"bucket"`
+	// 汇总
+	Summary CallMetric `json:"summary"`
+	// 按时间段，无调用的时间段指标为0
+	Buckets []*CallBucket `json:"buckets"`
+	// 按数据集，调用次数倒序
+	DataSets []*DataSetCallStat `json:"dataSets"`
+	// 调用次数最多的调用者
+	TopCallers []*CallerStat `json:"topCallers"`
+}
+
+// NewCallLog 创建实例
+func NewCallLog() *CallLog {
+	return &CallLog{
+		db:            db.DB(),
+		engine:        orm.New(db.DB()),
+		enabled:       config.GetBool("callLog.enabled"),
+		sink:          config.GetString("callLog.sink"),
+		batchSize:     config.GetInt("callLog.batchSize"),
+		flushInterval: time.Duration(config.GetInt("callLog.flushInterval")) * time.Second,
+		retentionDays: config.GetInt("callLog.retentionDays"),
+	}
+}
+
+// Page 分页查询调用记录
+func (s *CallLog) Page(ctx context.Context, call *entity.APICall, page *model.Pagination) error {
+	if err := s.queryable(); err != nil {
+		return err
+	}
+	var (
+		total uint64
+		list  []*entity.APICall
+		err   error
+	)
+	combineClause := condition.NewCombineClause(condition.CombineAnd)
+	if call.DataSetID != "" {
+		combineClause.Add(condition.Eq("data_set_id", call.DataSetID))
+	}
+	if call.Caller != "" {
+		combineClause.Add(condition.Eq("caller", call.Caller))
+	}
+	if call.Status != 0 {
+		combineClause.Add(condition.Eq("status", call.Status))
+	}
+	clause := condition.WrapCombineClause(combineClause)
+
+	if total, err = s.engine.Page(
+		call.TableName(),
+		&list,
+		selectOptionFunc.WithClause(clause),
+		selectOptionFunc.WithContext(ctx),
+		selectOptionFunc.WithPageSize(page.Page, page.Size),
+		selectOptionFunc.WithTablePrefix("`"),
+		selectOptionFunc.WithTableSuffix("`"),
+		selectOptionFunc.WithColumnPrefix("`"),
+		selectOptionFunc.WithColumnSuffix("`"),
+	); err != nil {
+		return err
+	}
+	if total < 1 {
+		return nil
+	}
+	page.Set(total, list)
+	return nil
+}
+
+// Stats 按数据集、时间段统计调用次数、错误率、耗时分位数以及调用者排行
+func (s *CallLog) Stats(ctx context.Context, query *CallStatsQuery) (*CallStats, error) {
+	if err := s.queryable(); err != nil {
+		return nil, err
+	}
+	end := time.Now()
+	if query.End != nil {
+		end = query.End.Time
+	}
+	start := end.Add(-7 * 24 * time.Hour)
+	if query.Start != nil {
+		start = query.Start.Time
+	}
+	if !start.Before(end) {
+		return nil, errors.New("统计开始时间必须早于结束时间")
+	}
+	if end.Sub(start) > maxCallStatsRange {
+		return nil, errors.New("统计时间范围不能超过366天")
+	}
+	bucket := query.Bucket
+	if bucket == "" {
+		bucket = CallBucketDay
+	}
+	if bucket != CallBucketHour && bucket != CallBucketDay {
+		return nil, errors.New("统计时间粒度仅支持hour、day")
+	}
+	top := query.Top
+	if top <= 0 {
+		top = 10
+	}
+
+	// 按时间段、数据集、调用者分组汇总，分位数由耗时直方图估算
+	var (
+		buckets, dataSets, callers map[string]*callAggregate
+		err                        error
+	)
+	if buckets, err = s.aggregate(ctx, query, start, end, bucketExpr(bucket)); err != nil {
+		return nil, err
+	}
+	if dataSets, err = s.aggregate(ctx, query, start, end, "data_set_id"); err != nil {
+		return nil, err
+	}
+	if callers, err = s.aggregate(ctx, query, start, end, "caller"); err != nil {
+		return nil, err
+	}
+	var summary callAggregate
+	for _, agg := range dataSets {
+		summary.merge(agg)
+	}
+
+	stats := &CallStats{
+		Start:   entity.Time{Time: start},
+		End:     entity.Time{Time: end},
+		Bucket:  bucket,
+		Summary: summary.metric(),
+	}
+	for t := truncateBucket(start, bucket); t.Before(end); t = nextBucket(t, bucket) {
+		var metric CallMetric
+		if agg, ok := buckets[t.Format(callBucketLayout)]; ok {
+			metric = agg.metric()
+		}
+		stats.Buckets = append(stats.Buckets, &CallBucket{Time: entity.Time{Time: t}, CallMetric: metric})
+	}
+	for id, agg := range dataSets {
+		stats.DataSets = append(stats.DataSets, &DataSetCallStat{DataSetID: id, CallMetric: agg.metric()})
+	}
+	sort.Slice(stats.DataSets, func(i, j int) bool {
+		return stats.DataSets[i].Calls > stats.DataSets[j].Calls
+	})
+	for caller, agg := range callers {
+		stats.TopCallers = append(stats.TopCallers, &CallerStat{Caller: caller, CallMetric: agg.metric()})
+	}
+	sort.Slice(stats.TopCallers, func(i, j int) bool {
+		return stats.TopCallers[i].Calls > stats.TopCallers[j].Calls
+	})
+	if len(stats.TopCallers) > top {
+		stats.TopCallers = stats.TopCallers[:top]
+	}
+	return stats, nil
+}
+
+// aggregate 按分组表达式以及耗时直方图区间汇总调用次数、错误数、缓存命中数以及区间内的最大耗时
+func (s *CallLog) aggregate(ctx context.Context, query *CallStatsQuery, start, end time.Time, group string) (map[string]*callAggregate, error) {
+	var rows []*callHistogramRow
+	tx := s.db.WithContext(ctx).Model(&entity.APICall{}).
+		Select(group+" AS group_key, "+latencyBinExpr()+" AS bin, COUNT(*) AS calls, "+
+			"SUM(CASE WHEN success THEN 0 ELSE 1 END) AS errors, SUM(CASE WHEN cache_hit THEN 1 ELSE 0 END) AS cache_hits, "+
+			"MAX(latency) AS max_latency").
+		Where("called_at >= ? AND called_at < ?", start, end)
+	if query.DataSetID != "" {
+		tx = tx.Where("data_set_id = ?", query.DataSetID)
+	}
+	if query.Caller != "" {
+		tx = tx.Where("caller = ?", query.Caller)
+	}
+	if err := tx.Group("group_key, bin").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	m := make(map[string]*callAggregate)
+	for _, row := range rows {
+		aggregateOf(m, row.GroupKey).add(row)
+	}
+	return m, nil
+}
+
+// Purge 删除超过保留天数的调用记录，返回删除的条数
+func (s *CallLog) Purge(ctx context.Context) (int64, error) {
+	retentionDays := s.retentionDays
+	if retentionDays <= 0 {
+		retentionDays = defaultCallLogRetentionDays
+	}
+	before := time.Now().AddDate(0, 0, -retentionDays)
+	tx := s.db.WithContext(ctx).Where("called_at < ?", before).Delete(&entity.APICall{})
+	return tx.RowsAffected, tx.Error
+}
+
+// queryable 仅元数据库存储支持查询以及统计
+func (s *CallLog) queryable() error {
+	if s.sink != "" && s.sink != "db" {
+		return fmt.Errorf("调用记录存储为%s，不支持查询以及统计", s.sink)
+	}
+	return nil
+}
+
+// callSink 按配置选择存储，未注册的名称使用元数据库
+func (s *CallLog) callSink() CallSink {
+	if s.sink == "" || s.sink == "db" {
+		return dbCallSink{db: s.db}
+	}
+	callSinks.RLock()
+	defer callSinks.RUnlock()
+	if sink, ok := callSinks.store[s.sink]; ok {
+		return sink
+	}
+	log.Logger().Warn("调用记录存储未注册，使用元数据库", zap.String("sink", s.sink))
+	return dbCallSink{db: s.db}
+}
+
+// SyncCallLog 开启后异步批量写入调用记录，并定时清理过期记录
+func SyncCallLog(callLog *CallLog) {
+	if !callLog.enabled {
+		return
+	}
+	if !atomic.CompareAndSwapInt32(&callLogRunning, 0, 1) {
+		return
+	}
+	var (
+		sink          = callLog.callSink()
+		batchSize     = callLog.batchSize
+		flushInterval = callLog.flushInterval
+	)
+	if batchSize <= 0 {
+		batchSize = defaultCallLogBatchSize
+	}
+	if flushInterval <= 0 {
+		flushInterval = defaultCallLogFlushInterval
+	}
+
+	// 批量写入
+	go func() {
+		var (
+			batch  = make([]*entity.APICall, 0, batchSize)
+			ticker = time.NewTicker(flushInterval)
+		)
+		defer ticker.Stop()
+		flush := func() {
+			if len(batch) == 0 {
+				return
+			}
+			if err := sink.Write(context.TODO(), batch); err != nil {
+				log.Logger().Warn("写入调用记录错误", zap.Int("size", len(batch)), zap.Error(err))
+			}
+			batch = make([]*entity.APICall, 0, batchSize)
+		}
+		for {
+			select {
+			case call := <-callQueue:
+				batch = append(batch, call)
+				if len(batch) >= batchSize {
+					flush()
+				}
+			case <-ticker.C:
+				flush()
+			}
+		}
+	}()
+
+	// 清理过期记录
+	go func() {
+		for {
+			log.Logger().Debug("清理过期的调用记录")
+			if n, err := callLog.Purge(context.TODO()); err != nil {
+				log.Logger().Warn("清理调用记录错误", zap.Error(err))
+			} else if n > 0 {
+				log.Logger().Info("清理调用记录", zap.Int64("count", n))
+			}
+
+			time.Sleep(time.Hour)
+		}
+	}()
+}
+
+// apiCall 调用记录以及匹配到的数据集路径
+type apiCall struct {
+	*entity.APICall
+	// 数据集路径，作为指标的标签
+	route string
+}
+
+// logCall 执行API调用，异步记录调用者、状态、耗时、返回行数以及是否命中缓存，并记录调用指标
+func logCall(ctx context.Context, method, path string, fn func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	now := entity.Now()
+	call := &apiCall{APICall: &entity.APICall{
+		Method:   method,
+		Path:     path,
+		Caller:   caller(ctx),
+		CalledAt: &now,
+	}}
+	call.IP, _ = ctx.Value(util.ClientIP).(string)
+
+	ctx, span := tracing.Start(ctx, "api.call", semconv.HTTPMethodKey.String(method), semconv.HTTPTargetKey.String(path))
+	v, err := fn(context.WithValue(ctx, apiCallKey{}, call))
+
+	cost := time.Since(now.Time)
+	call.Latency = cost.Milliseconds()
+	call.Status = http.StatusOK
+	if err != nil {
+		if he, ok := err.(*echo.HTTPError); ok {
+			call.Status = he.Code
+			call.Error = fmt.Sprintf("%v", he.Message)
+		} else {
+			call.Error = err.Error()
+		}
+		if r := []rune(call.Error); len(r) > 500 {
+			call.Error = string(r[:500])
+		}
+	} else {
+		call.Success = true
+		call.RowCount = resultRows(v)
+	}
+	metrics.ObserveAPI(method, call.route, call.Status, cost)
+	span.SetAttributes(tracing.DataSetIDKey.String(call.DataSetID), tracing.DataSetPathKey.String(call.route),
+		semconv.HTTPStatusCodeKey.Int(call.Status))
+	tracing.End(span, err)
+	recordCall(call.APICall)
+	return v, err
+}
+
+// currentCall 当前调用记录，未记录时为nil
+func currentCall(ctx context.Context) *apiCall {
+	call, _ := ctx.Value(apiCallKey{}).(*apiCall)
+	return call
+}
+
+// recordCall 放入写入队列，未开启或队列已满时丢弃，不影响API调用
+func recordCall(call *entity.APICall) {
+	if atomic.LoadInt32(&callLogRunning) == 0 {
+		return
+	}
+	call.ID = db.NewID()
+	select {
+	case callQueue <- call:
+	default:
+		log.Logger().Warn("调用记录队列已满，丢弃记录", zap.String("path", call.Path))
+	}
+}
+
+// resultRows 返回结果的行数，写操作为影响行数
+func resultRows(v interface{}) int64 {
+	switch r := v.(type) {
+	case nil:
+		return 0
+	case *db.ExecResult:
+		if r == nil {
+			return 0
+		}
+		return r.RowsAffected
+	case *model.Pagination:
+		return resultRows(r.Data)
+	case []map[string]interface{}:
+		return int64(len(r))
+	case []interface{}:
+		return int64(len(r))
+	case map[string]interface{}:
+		// 缓存中的分页结果
+		if data, ok := r["data"]; ok {
+			if _, ok := r["total"]; ok {
+				return resultRows(data)
+			}
+		}
+		return 1
+	default:
+		return 1
+	}
+}
+
+// callHistogramRow 分组以及耗时区间的汇总结果
+type callHistogramRow struct {
+	GroupKey   string
+	Bin        int
+	Calls      int64
+	Errors     int64
+	CacheHits  int64
+	MaxLatency int64
+}
+
+// callAggregate 统计累加，耗时按直方图区间记录调用次数以及区间内的最大耗时
+type callAggregate struct {
+	calls     int64
+	errors    int64
+	cacheHits int64
+	bins      [len(latencyBounds) + 1]latencyBin
+}
+
+// latencyBin 耗时区间
+type latencyBin struct {
+	calls int64
+	max   int64
+}
+
+// aggregateOf 按key取统计累加，不存在时创建
+func aggregateOf(m map[string]*callAggregate, key string) *callAggregate {
+	agg, ok := m[key]
+	if !ok {
+		agg = new(callAggregate)
+		m[key] = agg
+	}
+	return agg
+}
+
+func (a *callAggregate) add(row *callHistogramRow) {
+	if row.Bin < 0 || row.Bin >= len(a.bins) {
+		return
+	}
+	a.calls += row.Calls
+	a.errors += row.Errors
+	a.cacheHits += row.CacheHits
+	bin := &a.bins[row.Bin]
+	bin.calls += row.Calls
+	if row.MaxLatency > bin.max {
+		bin.max = row.MaxLatency
+	}
+}
+
+func (a *callAggregate) merge(other *callAggregate) {
+	a.calls += other.calls
+	a.errors += other.errors
+	a.cacheHits += other.cacheHits
+	for i := range a.bins {
+		a.bins[i].calls += other.bins[i].calls
+		if other.bins[i].max > a.bins[i].max {
+			a.bins[i].max = other.bins[i].max
+		}
+	}
+}
+
+func (a *callAggregate) metric() CallMetric {
+	if a.calls == 0 {
+		return CallMetric{}
+	}
+	return CallMetric{
+		Calls:     a.calls,
+		Errors:    a.errors,
+		ErrorRate: math.Round(float64(a.errors)/float64(a.calls)*10000) / 10000,
+		CacheHits: a.cacheHits,
+		P50:       a.percentile(0.50),
+		P95:       a.percentile(0.95),
+		P99:       a.percentile(0.99),
+	}
+}
+
+// percentile 最近秩法计算分位数，取所在耗时区间的最大耗时
+func (a *callAggregate) percentile(p float64) int64 {
+	rank := int64(math.Ceil(p * float64(a.calls)))
+	var n int64
+	for _, bin := range a.bins {
+		n += bin.calls
+		if n >= rank && bin.calls > 0 {
+			return bin.max
+		}
+	}
+	return 0
+}
+
+// latencyBinExpr 耗时所在直方图区间的SQL表达式
+func latencyBinExpr() string {
+	var b strings.Builder
+	b.WriteString("CASE")
+	for i, bound := range latencyBounds {
+		fmt.Fprintf(&b, " WHEN latency <= %d THEN %d", bound, i)
+	}
+	fmt.Fprintf(&b, " ELSE %d END", len(latencyBounds))
+	return b.String()
+}
+
+// bucketExpr 时间段开始时间的SQL表达式，格式与callBucketLayout一致
+func bucketExpr(bucket string) string {
+	if bucket == CallBucketHour {
+		return "DATE_FORMAT(called_at, '%Y-%m-%d %H:00:00')"
+	}
+	return "DATE_FORMAT(called_at, '%Y-%m-%d 00:00:00')"
+}
+
+// truncateBucket 截断到时间段的开始
+func truncateBucket(t time.Time, bucket string) time.Time {
+	if bucket == CallBucketHour {
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location())
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// nextBucket 下一个时间段的开始
+func nextBucket(t time.Time, bucket string) time.Time {
+	if bucket == CallBucketHour {
+		return t.Add(time.Hour)
+	}
+	return t.AddDate(0, 0, 1)
+}
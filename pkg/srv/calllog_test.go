@@ -0,0 +1,35 @@
+package srv
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCallAggregate(t *testing.T) {
+	var summary callAggregate
+	if metric := summary.metric(); metric.Calls != 0 || metric.P99 != 0 {
+		t.Errorf("无调用时指标应为0: %+v", metric)
+	}
+
+	first, second := new(callAggregate), new(callAggregate)
+	// 耗时10、20、100，区间分别为<=10、<=25、<=100
+	first.add(&callHistogramRow{Bin: 1, Calls: 1, MaxLatency: 10})
+	first.add(&callHistogramRow{Bin: 2, Calls: 1, CacheHits: 1, MaxLatency: 20})
+	second.add(&callHistogramRow{Bin: 4, Calls: 1, Errors: 1, MaxLatency: 100})
+	second.add(&callHistogramRow{Bin: len(latencyBounds) + 1, Calls: 1})
+	summary.merge(first)
+	summary.merge(second)
+
+	metric := summary.metric()
+	if metric.Calls != 3 || metric.Errors != 1 || metric.CacheHits != 1 || metric.ErrorRate != 0.3333 {
+		t.Errorf("统计错误: %+v", metric)
+	}
+	if metric.P50 != 20 || metric.P95 != 100 || metric.P99 != 100 {
+		t.Errorf("耗时分位数错误: %+v", metric)
+	}
+
+	expr := latencyBinExpr()
+	if !strings.HasPrefix(expr, "CASE WHEN latency <= 5 THEN 0") || !strings.HasSuffix(expr, "ELSE 13 END") {
+		t.Errorf("耗时区间表达式错误: %s", expr)
+	}
+}
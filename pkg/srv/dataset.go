@@ -14,26 +14,96 @@ import (
 	"text/template"
 	"time"
 
+	"github.com/xuanbo/ohmydata/pkg/api/util"
 	"github.com/xuanbo/ohmydata/pkg/cache"
+	"github.com/xuanbo/ohmydata/pkg/config"
 	"github.com/xuanbo/ohmydata/pkg/db"
 	orm "github.com/xuanbo/ohmydata/pkg/db/gorm"
+	"github.com/xuanbo/ohmydata/pkg/db/stmt"
 	"github.com/xuanbo/ohmydata/pkg/entity"
 	"github.com/xuanbo/ohmydata/pkg/log"
+	"github.com/xuanbo/ohmydata/pkg/metrics"
 	"github.com/xuanbo/ohmydata/pkg/model"
 	"github.com/xuanbo/ohmydata/pkg/model/condition"
+	"github.com/xuanbo/ohmydata/pkg/strutil"
+	"github.com/xuanbo/ohmydata/pkg/tracing"
 
 	"github.com/go-redis/redis/v8"
+	"github.com/graphql-go/graphql"
 	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel/semconv"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
 )
 
+// ErrExplainNotSupported 数据源不支持执行计划
+var ErrExplainNotSupported = errors.New("数据源不支持执行计划")
+
 // DataSet 数据集服务
 type DataSet struct {
 	db     *gorm.DB
 	engine *orm.Engine
 	tpl    *template.Template
+	// 保护router、schema，同步时整体替换
+	mu     sync.RWMutex
 	router *Node
+	// 已发布数据集的GraphQL schema，随路由一起刷新
+	schema *graphql.Schema
+	// API授权、限流
+	grant   *Grant
+	limiter *RateLimiter
+	// 发布校验，0表示不校验：全表扫描预估行数上限、预估扫描总行数上限
+	maxFullScanRows  int
+	maxEstimatedRows int
+	// 批量调用：单次最多调用数、并发数
+	batchMaxCalls    int
+	batchConcurrency int
+}
+
+// Rendering 数据集渲染结果，不执行
+type Rendering struct {
+	// 渲染后的SQL以及绑定参数
+	SQL  string        `json:"sql"`
+	Args []interface{} `json:"args"`
+	// 分页包装后实际发送的语句，写操作数据集为空
+	PageSQL  string `json:"pageSql"`
+	CountSQL string `json:"countSql"`
+	// 使用默认值、分页处理后的实际参数
+	Params map[string]interface{} `json:"params"`
+}
+
+// InferredParam 根据查询结果字段推断的响应参数
+type InferredParam struct {
+	*entity.ResponseParam
+	Column *db.Column `json:"column"`
+}
+
+// ResponseParamDiff 推断的响应参数与数据集响应参数的差异
+type ResponseParamDiff struct {
+	// 推断的响应参数
+	Params []*InferredParam `json:"params"`
+	// 新增、移除以及类型变更的字段
+	Added   []*InferredParam        `json:"added"`
+	Removed []*entity.ResponseParam `json:"removed"`
+	Changed []*ParamTypeChange      `json:"changed"`
+}
+
+// ParamTypeChange 字段类型变更
+type ParamTypeChange struct {
+	Name   string           `json:"name"`
+	From   entity.ParamType `json:"from"`
+	To     entity.ParamType `json:"to"`
+	Column *db.Column       `json:"column"`
+}
+
+// Explanation 数据集执行计划
+type Explanation struct {
+	// 渲染后的SQL以及绑定参数
+	SQL  string        `json:"sql"`
+	Args []interface{} `json:"args"`
+	Plan *db.Plan      `json:"plan"`
+	// 未通过的发布校验
+	Violations []string `json:"violations"`
 }
 
 // NewDataSet 创建实例
@@ -48,44 +118,29 @@ func NewDataSet() *DataSet {
 	if err != nil {
 		log.Logger().Info("初始化API文档模板错误", zap.Error(err))
 	}
-	return &DataSet{db: db.DB(), engine: orm.New(db.DB()), tpl: tpl, router: new(Node)}
+	return &DataSet{
+		db:               db.DB(),
+		engine:           orm.New(db.DB()),
+		tpl:              tpl,
+		router:           new(Node),
+		grant:            NewGrant(),
+		limiter:          NewRateLimiter(),
+		maxFullScanRows:  config.GetInt("publish.maxFullScanRows"),
+		maxEstimatedRows: config.GetInt("publish.maxEstimatedRows"),
+		batchMaxCalls:    config.GetInt("batch.maxCalls"),
+		batchConcurrency: config.GetInt("batch.concurrency"),
+	}
 }
 
 // Create 新增
 func (s *DataSet) Create(ctx context.Context, dataSet *entity.DataSet) error {
 	dataSet.ID = db.NewID()
+	dataSet.Managed = false
 	if err := s.validDataSet(ctx, dataSet); err != nil {
 		return err
 	}
 	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
-		if len(dataSet.RequestParams) > 0 {
-			for _, requestParam := range dataSet.RequestParams {
-				if err := validRequestParam(requestParam); err != nil {
-					return err
-				}
-				requestParam.ID = db.NewID()
-				requestParam.DataSetID = dataSet.ID
-			}
-			if err := tx.CreateInBatches(dataSet.RequestParams, len(dataSet.RequestParams)).Error; err != nil {
-				return err
-			}
-		}
-		if len(dataSet.ResponseParams) > 0 {
-			for _, responseParam := range dataSet.ResponseParams {
-				if err := validResponseParam(responseParam); err != nil {
-					return err
-				}
-				responseParam.ID = db.NewID()
-				responseParam.DataSetID = dataSet.ID
-			}
-			if err := tx.CreateInBatches(dataSet.ResponseParams, len(dataSet.ResponseParams)).Error; err != nil {
-				return err
-			}
-		}
-		if err := tx.Create(dataSet).Error; err != nil {
-			return err
-		}
-		return nil
+		return createDataSet(tx, dataSet)
 	})
 	// 清除缓存
 	s.clearCache(ctx, "all")
@@ -97,46 +152,15 @@ func (s *DataSet) Modify(ctx context.Context, dataSet *entity.DataSet) error {
 	if dataSet.ID == "" {
 		return errors.New("更新时主键不能为空")
 	}
+	if err := checkManaged(s.db.WithContext(ctx), &entity.DataSet{}, dataSet.ID); err != nil {
+		return err
+	}
+	dataSet.Managed = false
 	if err := s.validDataSet(ctx, dataSet); err != nil {
 		return err
 	}
 	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
-		// 删除参数
-		if err := tx.Delete(entity.RequestParam{}, "data_set_id = ?", dataSet.ID).Error; err != nil {
-			return err
-		}
-		if err := tx.Delete(entity.ResponseParam{}, "data_set_id = ?", dataSet.ID).Error; err != nil {
-			return err
-		}
-		// 新增参数
-		if len(dataSet.RequestParams) > 0 {
-			for _, requestParam := range dataSet.RequestParams {
-				if err := validRequestParam(requestParam); err != nil {
-					return err
-				}
-				requestParam.ID = db.NewID()
-				requestParam.DataSetID = dataSet.ID
-			}
-			if err := tx.CreateInBatches(dataSet.RequestParams, len(dataSet.RequestParams)).Error; err != nil {
-				return err
-			}
-		}
-		if len(dataSet.ResponseParams) > 0 {
-			for _, responseParam := range dataSet.ResponseParams {
-				if err := validResponseParam(responseParam); err != nil {
-					return err
-				}
-				responseParam.ID = db.NewID()
-				responseParam.DataSetID = dataSet.ID
-			}
-			if err := tx.CreateInBatches(dataSet.ResponseParams, len(dataSet.ResponseParams)).Error; err != nil {
-				return err
-			}
-		}
-		if err := tx.Save(dataSet).Error; err != nil {
-			return err
-		}
-		return nil
+		return modifyDataSet(tx, dataSet)
 	})
 	// 清除数据缓存
 	s.clearCache(ctx, "all")
@@ -146,14 +170,11 @@ func (s *DataSet) Modify(ctx context.Context, dataSet *entity.DataSet) error {
 
 // Remove 主键删除
 func (s *DataSet) Remove(ctx context.Context, id string) error {
+	if err := checkManaged(s.db.WithContext(ctx), &entity.DataSet{}, id); err != nil {
+		return err
+	}
 	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
-		if err := tx.Delete(entity.RequestParam{}, "data_set_id = ?", id).Error; err != nil {
-			return err
-		}
-		if err := tx.Delete(entity.ResponseParam{}, "data_set_id = ?", id).Error; err != nil {
-			return err
-		}
-		return tx.Delete(entity.DataSet{}, "id = ?", id).Error
+		return removeDataSet(tx, id)
 	})
 	// 清除数据缓存
 	s.clearCache(ctx, "all")
@@ -267,21 +288,19 @@ func (s *DataSet) Detail(ctx context.Context, id string) (*entity.DataSet, error
 	return &dataSet, err
 }
 
-// ChangePublishStatus 修改发布状态
+// ChangePublishStatus 修改发布状态：发布需审核通过，取消发布即下线
 func (s *DataSet) ChangePublishStatus(ctx context.Context, id string, status bool) error {
-	if err := s.db.WithContext(ctx).Model(&entity.DataSet{}).Where("id = ?", id).
-		Update("publish_status", status).Error; err != nil {
-		return err
+	action := entity.ActionRetire
+	if status {
+		action = entity.ActionPublish
 	}
-	// 清除缓存
-	s.clearCache(ctx, "all")
-	s.clearCache(ctx, id)
-	return nil
+	_, err := s.Transition(ctx, id, &Transition{Action: action})
+	return err
 }
 
-// RenderAPIDoc 渲染API文档
+// RenderAPIDoc 渲染发布版本的API文档
 func (s *DataSet) RenderAPIDoc(ctx context.Context, id string) (string, error) {
-	dataSet, err := s.Detail(ctx, id)
+	dataSet, err := s.Published(ctx, id)
 	if err != nil {
 		return "", err
 	}
@@ -304,7 +323,7 @@ func (s *DataSet) ParseExpression(expression string) ([]*entity.RequestParam, er
 		return nil, nil
 	}
 	// 解析模板
-	tpl, err := template.New(db.NewID()).Parse(expression)
+	tpl, err := newExpressionTemplate(db.NewID(), new([]interface{})).Parse(expression)
 	if err != nil {
 		return nil, err
 	}
@@ -338,6 +357,9 @@ func (s *DataSet) ParseExpression(expression string) ([]*entity.RequestParam, er
 
 // PreviewData 预览数据
 func (s *DataSet) PreviewData(ctx context.Context, dataSet *entity.DataSet, params map[string]interface{}) (interface{}, error) {
+	if dataSet.Kind == entity.KindWrite {
+		return nil, errors.New("写操作数据集不支持预览")
+	}
 	// 分页参数处理
 	var (
 		page, size uint64
@@ -350,54 +372,231 @@ func (s *DataSet) PreviewData(ctx context.Context, dataSet *entity.DataSet, para
 	return doSelect(ctx, dataSet, pagination, params)
 }
 
-// ServeAPI 提供API服务
-func (s *DataSet) ServeAPI(ctx context.Context, path string, params map[string]interface{}) (interface{}, error) {
-	// 路径匹配
-	node, nameParams, err := s.router.Match(path)
+// Render 渲染表达式，返回SQL、绑定参数、分页语句以及实际参数，不执行
+func (s *DataSet) Render(ctx context.Context, dataSet *entity.DataSet, params map[string]interface{}) (*Rendering, error) {
+	adapter, err := db.GetAdapter(dataSet.SourceID)
 	if err != nil {
 		return nil, err
 	}
-	if node == nil {
-		return nil, echo.NewHTTPError(http.StatusNotFound, "API不存在或未发布，请检查API")
+	rendering := new(Rendering)
+	if dataSet.Kind == entity.KindWrite {
+		rendering.Params = withDefaults(dataSet, params)
+		if rendering.SQL, rendering.Args, err = renderExpression(dataSet, rendering.Params); err != nil {
+			return nil, err
+		}
+		if err := stmt.CheckWrite(rendering.SQL); err != nil {
+			return nil, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		return rendering, nil
 	}
-	// 绑定的数据集ID
-	id := node.Handle.(string)
-	if id == "" {
-		return nil, echo.NewHTTPError(http.StatusNotFound, "API不存在或未发布，请检查API")
+
+	// 分页参数处理
+	page, size, err := parsePagination(params, dataSet)
+	if err != nil {
+		return nil, err
 	}
-	for k, v := range nameParams {
-		params[k] = v
+	pagination := model.NewPagination(page, size)
+	rendering.Params = withDefaults(dataSet, params)
+	if rendering.SQL, rendering.Args, err = renderExpression(dataSet, rendering.Params); err != nil {
+		return nil, err
 	}
+	if err := stmt.CheckRead(rendering.SQL); err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if paginator, ok := adapter.(db.Paginator); ok {
+		rendering.PageSQL, rendering.CountSQL = paginator.PageSQL(rendering.SQL, pagination)
+	}
+	return rendering, nil
+}
 
-	// 查询数据集
+// InferResponseParams 不读取数据执行查询，根据结果字段推断响应参数，未传的参数使用默认值
+func (s *DataSet) InferResponseParams(ctx context.Context, dataSet *entity.DataSet, params map[string]interface{}) ([]*InferredParam, error) {
+	if dataSet.Kind == entity.KindWrite {
+		return nil, errors.New("写操作数据集不支持推断响应参数")
+	}
+	adapter, err := db.GetAdapter(dataSet.SourceID)
+	if err != nil {
+		return nil, err
+	}
+	prober, ok := adapter.(db.Prober)
+	if !ok {
+		return nil, errors.New("数据源不支持推断响应参数")
+	}
+
+	// 渲染表达式
+	exp, args, err := renderExpression(dataSet, withDefaults(dataSet, params))
+	if err != nil {
+		return nil, err
+	}
+	if err := stmt.CheckRead(exp); err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	probeCtx, cancel := queryContext(ctx, dataSet)
+	defer cancel()
+	columns, err := prober.Columns(probeCtx, exp, args...)
+	if err != nil {
+		return nil, limitError(probeCtx, dataSet, err)
+	}
+	inferred := make([]*InferredParam, len(columns))
+	for i, column := range columns {
+		inferred[i] = &InferredParam{
+			ResponseParam: &entity.ResponseParam{
+				Name:      column.Name,
+				ParamType: column.ParamType(),
+			},
+			Column: column,
+		}
+	}
+	return inferred, nil
+}
+
+// DiffResponseParams 推断数据集的响应参数，并与已保存的响应参数比较
+func (s *DataSet) DiffResponseParams(ctx context.Context, id string, params map[string]interface{}) (*ResponseParamDiff, error) {
 	dataSet, err := s.Detail(ctx, id)
 	if err != nil {
 		return nil, err
 	}
 	if dataSet == nil {
-		return nil, echo.NewHTTPError(http.StatusNotFound, "API不存在或未发布，请检查API")
+		return nil, errors.New("数据集不存在")
 	}
+	inferred, err := s.InferResponseParams(ctx, dataSet, params)
+	if err != nil {
+		return nil, err
+	}
+	return diffResponseParams(dataSet.ResponseParams, inferred), nil
+}
 
-	// 分页参数处理
-	var page, size uint64
-	if page, size, err = parsePagination(params, dataSet); err != nil {
+// Explain 执行计划，params为样例参数，未传的参数使用默认值
+func (s *DataSet) Explain(ctx context.Context, dataSet *entity.DataSet, params map[string]interface{}) (*Explanation, error) {
+	if dataSet.Kind == entity.KindWrite {
+		return nil, errors.New("写操作数据集不支持执行计划")
+	}
+	adapter, err := db.GetAdapter(dataSet.SourceID)
+	if err != nil {
 		return nil, err
 	}
-	pagination := model.NewPagination(page, size)
+	explainer, ok := adapter.(db.Explainer)
+	if !ok {
+		return nil, ErrExplainNotSupported
+	}
 
-	// 缓存
-	if dataSet.EnableCache {
-		return doSelectFromCache(ctx, dataSet, pagination, params)
+	// 渲染表达式
+	exp, args, err := renderExpression(dataSet, withDefaults(dataSet, params))
+	if err != nil {
+		return nil, err
+	}
+	if err := stmt.CheckRead(exp); err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
-	return doSelect(ctx, dataSet, pagination, params)
+	explainCtx, cancel := queryContext(ctx, dataSet)
+	defer cancel()
+	plan, err := explainer.Explain(explainCtx, exp, args...)
+	if err != nil {
+		return nil, limitError(explainCtx, dataSet, err)
+	}
+	return &Explanation{SQL: exp, Args: args, Plan: plan, Violations: s.planViolations(plan)}, nil
+}
+
+// ServeAPI 提供API服务，开启调用记录时异步记录每次调用
+func (s *DataSet) ServeAPI(ctx context.Context, method, path string, params map[string]interface{}) (interface{}, error) {
+	return logCall(ctx, method, path, func(ctx context.Context) (interface{}, error) {
+		return s.serveAPI(ctx, method, path, params)
+	})
+}
+
+func (s *DataSet) serveAPI(ctx context.Context, method, path string, params map[string]interface{}) (interface{}, error) {
+	dataSet, err := s.Lookup(ctx, method, path, params)
+	if err != nil {
+		return nil, err
+	}
+	if call := currentCall(ctx); call != nil {
+		call.DataSetID = dataSet.ID
+		call.route = dataSet.Path
+	}
+	// 调用者授权
+	if err := s.grant.Authorize(ctx, method, path, dataSet); err != nil {
+		return nil, err
+	}
+	// 限流以及配额
+	if err := s.limiter.Check(ctx, dataSet); err != nil {
+		return nil, err
+	}
+
+	// 写操作
+	if dataSet.Kind == entity.KindWrite {
+		if method != dataSet.Method {
+			return nil, echo.NewHTTPError(http.StatusMethodNotAllowed, "API仅支持"+dataSet.Method+"请求")
+		}
+		key, _ := ctx.Value(util.IdempotencyKey).(string)
+		if key == "" {
+			return doExec(ctx, dataSet, params)
+		}
+		return doExecIdempotent(ctx, dataSet, key, params)
+	}
+	if method != http.MethodGet && method != http.MethodPost {
+		return nil, echo.NewHTTPError(http.StatusMethodNotAllowed, "API仅支持GET、POST请求")
+	}
+	return doQuery(ctx, dataSet, params)
+}
+
+// Lookup 按请求方法、路径匹配发布版本的数据集，路径参数合并到params
+func (s *DataSet) Lookup(ctx context.Context, method, path string, params map[string]interface{}) (*entity.DataSet, error) {
+	// 路径匹配
+	node, nameParams, err := s.APIRoutes().Match(path)
+	if err != nil {
+		return nil, err
+	}
+	if node == nil {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "API不存在或未发布，请检查API")
+	}
+	// 按请求方法绑定的数据集ID
+	handle, ok := node.Handle.(*Handle)
+	if !ok {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "API不存在或未发布，请检查API")
+	}
+	id := handle.Lookup(method)
+	if id == "" {
+		return nil, echo.NewHTTPError(http.StatusMethodNotAllowed, "API仅支持"+strings.Join(handle.Methods(), "、")+"请求")
+	}
+	for k, v := range nameParams {
+		params[k] = v
+	}
+
+	// 查询数据集发布版本
+	dataSet, err := s.Published(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if dataSet == nil {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "API不存在或未发布，请检查API")
+	}
+	return dataSet, nil
 }
 
 // APIRoutes 当前API路由
 func (s *DataSet) APIRoutes() *Node {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	return s.router
 }
 
+// graphQLSchema 当前GraphQL schema，尚未生成时为nil
+func (s *DataSet) graphQLSchema() *graphql.Schema {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.schema
+}
+
+// setRoutes 替换API路由以及GraphQL schema
+func (s *DataSet) setRoutes(router *Node, schema *graphql.Schema) {
+	s.mu.Lock()
+	s.router = router
+	s.schema = schema
+	s.mu.Unlock()
+}
+
 func (s *DataSet) clearCache(ctx context.Context, id string) {
 	log.Logger().Debug("清除数据集缓存", zap.String("id", id))
 	// 数据集缓存
@@ -406,7 +605,58 @@ func (s *DataSet) clearCache(ctx context.Context, id string) {
 	cache.DelMatch(ctx, "ohmydata:datasetcache:"+id+":*")
 }
 
+// checkPublish 发布校验，以请求参数默认值渲染后获取执行计划，超过配置的扫描行数时拒绝发布
+func (s *DataSet) checkPublish(ctx context.Context, id string) error {
+	if s.maxFullScanRows == 0 && s.maxEstimatedRows == 0 {
+		return nil
+	}
+	dataSet, err := s.Detail(ctx, id)
+	if err != nil {
+		return err
+	}
+	if dataSet == nil {
+		return errors.New("数据集不存在")
+	}
+	if dataSet.Kind != entity.KindQuery {
+		return nil
+	}
+	explanation, err := s.Explain(ctx, dataSet, make(map[string]interface{}))
+	if errors.Is(err, ErrExplainNotSupported) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("发布校验获取执行计划错误，请检查请求参数默认值: %w", err)
+	}
+	if len(explanation.Violations) > 0 {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, "发布校验未通过: "+strings.Join(explanation.Violations, "；"))
+	}
+	return nil
+}
+
+// planViolations 执行计划是否超过配置的扫描行数
+func (s *DataSet) planViolations(plan *db.Plan) []string {
+	violations := make([]string, 0)
+	if s.maxFullScanRows > 0 {
+		for _, scan := range plan.Scans {
+			if scan.Full && scan.Rows > float64(s.maxFullScanRows) {
+				violations = append(violations, fmt.Sprintf("表%s全表扫描预估%.0f行，超过%d行", scan.Table, scan.Rows, s.maxFullScanRows))
+			}
+		}
+	}
+	if s.maxEstimatedRows > 0 {
+		if rows := plan.EstimatedRows(); rows > float64(s.maxEstimatedRows) {
+			violations = append(violations, fmt.Sprintf("预估扫描%.0f行，超过%d行", rows, s.maxEstimatedRows))
+		}
+	}
+	return violations
+}
+
 func (s *DataSet) validDataSet(ctx context.Context, dataSet *entity.DataSet) error {
+	return validDataSetTx(s.db.WithContext(ctx), dataSet)
+}
+
+// validDataSetTx 在指定会话中校验数据集，导入时在事务中校验尚未提交的数据源以及数据集
+func validDataSetTx(tx *gorm.DB, dataSet *entity.DataSet) error {
 	if dataSet.Name == "" {
 		return errors.New("数据集名称不能为空")
 	}
@@ -417,17 +667,49 @@ func (s *DataSet) validDataSet(ctx context.Context, dataSet *entity.DataSet) err
 	if dataSet.SourceID == "" {
 		return errors.New("数据集数据源不能为空")
 	}
-	if len(dataSet.ResponseParams) == 0 {
-		return errors.New("响应参数不能为空")
+	switch dataSet.Kind {
+	case entity.KindQuery:
+		dataSet.Method = ""
+		if len(dataSet.ResponseParams) == 0 {
+			return errors.New("响应参数不能为空")
+		}
+		if err := stmt.CheckRead(stmt.StripActions(dataSet.Expression)); err != nil {
+			return err
+		}
+		if !dataSet.EnablePage && dataSet.MaxRows > 0 && dataSet.BatchLimit > dataSet.MaxRows {
+			return errors.New("未分页时单次查询条数不能超过最大行数")
+		}
+	case entity.KindWrite:
+		dataSet.Method = strings.ToUpper(dataSet.Method)
+		if !strutil.EqualAny(dataSet.Method, writeMethods) {
+			return errors.New("写操作数据集请求方法必须为POST、PUT、DELETE")
+		}
+		if err := stmt.CheckWrite(stmt.StripActions(dataSet.Expression)); err != nil {
+			return err
+		}
+		var dataSource entity.DataSource
+		if err := tx.Where("id = ?", dataSet.SourceID).Find(&dataSource).Error; err != nil {
+			return err
+		}
+		if !dataSource.AllowWrite {
+			return db.ErrWriteNotAllowed
+		}
+	default:
+		return errors.New("数据集类型不支持")
 	}
 	var total int64
-	if err := s.db.WithContext(ctx).Model(dataSet).Where("name = ? AND id <> ?", dataSet.Name, dataSet.ID).Count(&total).Error; err != nil {
+	if err := tx.Model(dataSet).Where("name = ? AND id <> ?", dataSet.Name, dataSet.ID).Count(&total).Error; err != nil {
 		return err
 	}
 	if total > 0 {
 		return errors.New("数据集名称已存在")
 	}
-	if err := s.db.WithContext(ctx).Model(dataSet).Where("path = ? AND id <> ?", dataSet.Path, dataSet.ID).Count(&total).Error; err != nil {
+	// 同一路径下查询数据集唯一，写操作数据集按请求方法唯一
+	pathDB := tx.Model(dataSet).Where("path = ? AND id <> ? AND kind = ?", dataSet.Path, dataSet.ID, dataSet.Kind)
+	if dataSet.Kind == entity.KindWrite {
+		pathDB = pathDB.Where("method = ?", dataSet.Method)
+	}
+	if err := pathDB.Count(&total).Error; err != nil {
 		return err
 	}
 	if total > 0 {
@@ -436,6 +718,107 @@ func (s *DataSet) validDataSet(ctx context.Context, dataSet *entity.DataSet) err
 	return nil
 }
 
+// createDataSet 事务中新增数据集以及参数
+func createDataSet(tx *gorm.DB, dataSet *entity.DataSet) error {
+	if len(dataSet.RequestParams) > 0 {
+		for _, requestParam := range dataSet.RequestParams {
+			if err := validRequestParam(requestParam); err != nil {
+				return err
+			}
+			requestParam.ID = db.NewID()
+			requestParam.DataSetID = dataSet.ID
+		}
+		if err := tx.CreateInBatches(dataSet.RequestParams, len(dataSet.RequestParams)).Error; err != nil {
+			return err
+		}
+	}
+	if len(dataSet.ResponseParams) > 0 {
+		for _, responseParam := range dataSet.ResponseParams {
+			if err := validResponseParam(responseParam); err != nil {
+				return err
+			}
+			responseParam.ID = db.NewID()
+			responseParam.DataSetID = dataSet.ID
+		}
+		if err := tx.CreateInBatches(dataSet.ResponseParams, len(dataSet.ResponseParams)).Error; err != nil {
+			return err
+		}
+	}
+	// 新建为草稿，审核通过后才能发布
+	dataSet.State = entity.StateDraft
+	dataSet.Reviewer = ""
+	dataSet.PublishStatus = false
+	dataSet.PublishedVersion = 0
+	if err := createVersion(tx, dataSet); err != nil {
+		return err
+	}
+	if err := tx.Create(dataSet).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// removeDataSet 事务中删除数据集、参数以及版本
+func removeDataSet(tx *gorm.DB, id string) error {
+	if err := tx.Delete(entity.RequestParam{}, "data_set_id = ?", id).Error; err != nil {
+		return err
+	}
+	if err := tx.Delete(entity.ResponseParam{}, "data_set_id = ?", id).Error; err != nil {
+		return err
+	}
+	if err := tx.Delete(entity.DataSetVersion{}, "data_set_id = ?", id).Error; err != nil {
+		return err
+	}
+	return tx.Delete(entity.DataSet{}, "id = ?", id).Error
+}
+
+// modifyDataSet 事务中修改数据集，重建参数并生成新版本
+func modifyDataSet(tx *gorm.DB, dataSet *entity.DataSet) error {
+	// 删除参数
+	if err := tx.Delete(entity.RequestParam{}, "data_set_id = ?", dataSet.ID).Error; err != nil {
+		return err
+	}
+	if err := tx.Delete(entity.ResponseParam{}, "data_set_id = ?", dataSet.ID).Error; err != nil {
+		return err
+	}
+	// 新增参数
+	if len(dataSet.RequestParams) > 0 {
+		for _, requestParam := range dataSet.RequestParams {
+			if err := validRequestParam(requestParam); err != nil {
+				return err
+			}
+			requestParam.ID = db.NewID()
+			requestParam.DataSetID = dataSet.ID
+		}
+		if err := tx.CreateInBatches(dataSet.RequestParams, len(dataSet.RequestParams)).Error; err != nil {
+			return err
+		}
+	}
+	if len(dataSet.ResponseParams) > 0 {
+		for _, responseParam := range dataSet.ResponseParams {
+			if err := validResponseParam(responseParam); err != nil {
+				return err
+			}
+			responseParam.ID = db.NewID()
+			responseParam.DataSetID = dataSet.ID
+		}
+		if err := tx.CreateInBatches(dataSet.ResponseParams, len(dataSet.ResponseParams)).Error; err != nil {
+			return err
+		}
+	}
+	if err := createVersion(tx, dataSet); err != nil {
+		return err
+	}
+	if err := recordEdit(tx, dataSet); err != nil {
+		return err
+	}
+	// 发布状态、发布版本仅通过审核流转修改
+	if err := tx.Omit("publish_status", "published_version").Save(dataSet).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
 func validRequestParam(requestParam *entity.RequestParam) error {
 	if requestParam.Name == "" {
 		return errors.New("请求参数名称不能为空")
@@ -490,7 +873,7 @@ func removeDuplicate(list []string) []string {
 	tempMap := map[string]byte{}
 	for _, e := range list {
 		// 排除空字符串以及，内置方法名
-		if e == "" || equalAny(e, funcNames) {
+		if e == "" || strutil.EqualAny(e, funcNames) {
 			continue
 		}
 		size := len(tempMap)
@@ -503,15 +886,6 @@ func removeDuplicate(list []string) []string {
 	return result
 }
 
-func equalAny(v string, list []string) bool {
-	for _, e := range list {
-		if v == e {
-			return true
-		}
-	}
-	return false
-}
-
 func parsePagination(param map[string]interface{}, dataSet *entity.DataSet) (uint64, uint64, error) {
 	if !dataSet.EnablePage {
 		param["page"] = 0
@@ -534,9 +908,31 @@ func parsePagination(param map[string]interface{}, dataSet *entity.DataSet) (uin
 			return 0, 0, fmt.Errorf("分页参数size必须是一个正整数: %v", v)
 		}
 	}
+	if dataSet.MaxRows > 0 && size > uint64(dataSet.MaxRows) {
+		return 0, 0, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("分页参数size不能超过最大行数%d", dataSet.MaxRows))
+	}
 	return page, size, nil
 }
 
+// doQuery 查询数据集，处理分页参数以及缓存
+func doQuery(ctx context.Context, dataSet *entity.DataSet, params map[string]interface{}) (interface{}, error) {
+	// 分页参数解析
+	_, span := tracing.Start(ctx, "pagination.parse", tracing.DataSetIDKey.String(dataSet.ID))
+	page, size, err := parsePagination(params, dataSet)
+	tracing.End(span, err)
+	if err != nil {
+		return nil, err
+	}
+	pagination := model.NewPagination(page, size)
+
+	// 缓存
+	if dataSet.EnableCache {
+		return doSelectFromCache(ctx, dataSet, pagination, params)
+	}
+
+	return doSelect(ctx, dataSet, pagination, params)
+}
+
 func doSelectFromCache(ctx context.Context, dataSet *entity.DataSet, pagination *model.Pagination, params map[string]interface{}) (interface{}, error) {
 	// 从缓存中查询
 	var (
@@ -549,17 +945,37 @@ func doSelectFromCache(ctx context.Context, dataSet *entity.DataSet, pagination
 		return nil, err
 	}
 	key = "ohmydata:datasetcache:" + dataSet.ID + ":" + fmt.Sprintf("%x", md5.Sum(b))
+	// 仅返回部分字段时，结果与完整结果分开缓存
+	if fields := responseFields(ctx); fields != nil {
+		key += ":" + fmt.Sprintf("%x", md5.Sum([]byte(strings.Join(fields, ","))))
+	}
 	log.Logger().Debug("从缓存中查询结果", zap.String("id", dataSet.ID), zap.String("key", key))
-	if err = cache.Get(ctx, key, &v); errors.Is(err, redis.Nil) {
+	getCtx, span := tracing.Start(ctx, "cache.get", tracing.CacheKeyKey.String(key))
+	err = cache.Get(getCtx, key, &v)
+	span.SetAttributes(tracing.CacheHitKey.Bool(err == nil))
+	// 未命中不作为错误记录
+	if errors.Is(err, redis.Nil) {
+		tracing.End(span, nil)
+	} else {
+		tracing.End(span, err)
+	}
+	if errors.Is(err, redis.Nil) {
 		// 缓存未命中，查询db
+		metrics.ObserveCache(dataSet.Path, false)
 		log.Logger().Debug("缓存未命中", zap.String("id", dataSet.ID), zap.String("key", key))
 		v, err = doSelect(ctx, dataSet, pagination, params)
 		if err != nil {
 			return nil, err
 		}
 		// 写入缓存
-		cache.Set(ctx, key, &v, time.Duration(dataSet.ExpireSeconds)*time.Second)
+		setCtx, span := tracing.Start(ctx, "cache.set", tracing.CacheKeyKey.String(key))
+		tracing.End(span, cache.Set(setCtx, key, &v, time.Duration(dataSet.ExpireSeconds)*time.Second))
 		log.Logger().Debug("数据写入缓存", zap.String("id", dataSet.ID), zap.String("key", key))
+	} else if err == nil {
+		metrics.ObserveCache(dataSet.Path, true)
+		if call := currentCall(ctx); call != nil {
+			call.CacheHit = true
+		}
 	}
 	return v, err
 }
@@ -572,39 +988,309 @@ func doSelect(ctx context.Context, dataSet *entity.DataSet, pagination *model.Pa
 
 	// TODO 请求参数校验
 
-	// 渲染表达式
+	// 渲染表达式，未传的参数使用默认值，与预览、GraphQL以及OpenAPI文档一致
 	log.Logger().Info("表达式模板", zap.String("expression", dataSet.Expression))
-	var buff bytes.Buffer
-	tpl, err := template.New(dataSet.ID).Parse(dataSet.Expression)
+	_, span := tracing.Start(ctx, "template.render", tracing.DataSetIDKey.String(dataSet.ID))
+	exp, args, err := renderExpression(dataSet, withDefaults(dataSet, params))
+	tracing.End(span, err)
 	if err != nil {
 		return nil, err
 	}
-	if err := tpl.Execute(&buff, params); err != nil {
+	log.Logger().Info("表达式", zap.String("expression", exp), zap.Any("args", args))
+	// 只读校验，防止参数拼接出非查询语句
+	if err := stmt.CheckRead(exp); err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	// 查询，受数据集超时、行数、字节数限制
+	pagination.MaxRows = uint64(dataSet.MaxRows)
+	pagination.MaxBytes = uint64(dataSet.MaxBytes)
+	queryCtx, cancel := queryContext(ctx, dataSet)
+	defer cancel()
+	queryCtx, finish := db.StartRunning(queryCtx, &db.Running{
+		SourceID:  dataSet.SourceID,
+		DataSetID: dataSet.ID,
+		Caller:    caller(ctx),
+		SQL:       exp,
+		Args:      args,
+	})
+	defer finish()
+	err = observeAdapter(queryCtx, dataSet.SourceID, "query", exp, func(ctx context.Context) error {
+		return adapter.Query(ctx, exp, pagination, args...)
+	})
+	if err != nil {
+		return nil, limitError(queryCtx, dataSet, err)
+	}
+
+	// 结果处理
+	return convertResponseParams(pagination, dataSet, responseFields(ctx)...)
+}
+
+func doExec(ctx context.Context, dataSet *entity.DataSet, params map[string]interface{}) (*db.ExecResult, error) {
+	adapter, err := db.GetAdapter(dataSet.SourceID)
+	if err != nil {
 		return nil, err
 	}
-	exp := buff.String()
-	log.Logger().Info("表达式", zap.String("expression", exp))
 
-	// 查询
-	if err := adapter.Query(ctx, exp, pagination); err != nil {
+	// 渲染表达式，未传的参数使用默认值
+	_, span := tracing.Start(ctx, "template.render", tracing.DataSetIDKey.String(dataSet.ID))
+	exp, args, err := renderExpression(dataSet, withDefaults(dataSet, params))
+	tracing.End(span, err)
+	if err != nil {
 		return nil, err
 	}
+	log.Logger().Info("写操作表达式", zap.String("expression", exp), zap.Any("args", args))
+	if err := stmt.CheckWrite(exp); err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
 
-	// 结果处理
-	return convertResponseParams(pagination, dataSet)
+	// 事务中执行
+	execCtx, cancel := queryContext(ctx, dataSet)
+	defer cancel()
+	execCtx, finish := db.StartRunning(execCtx, &db.Running{
+		SourceID:  dataSet.SourceID,
+		DataSetID: dataSet.ID,
+		Caller:    caller(ctx),
+		SQL:       exp,
+		Args:      args,
+	})
+	defer finish()
+	var result *db.ExecResult
+	err = observeAdapter(execCtx, dataSet.SourceID, "exec", exp, func(ctx context.Context) error {
+		result, err = adapter.Exec(ctx, exp, args...)
+		return err
+	})
+	if err != nil {
+		return nil, limitError(execCtx, dataSet, err)
+	}
+	return result, nil
+}
+
+// observeAdapter 调用数据源，记录耗时、错误指标以及包含语句的span
+func observeAdapter(ctx context.Context, sourceID, operation, statement string, fn func(ctx context.Context) error) error {
+	ctx, span := tracing.Start(ctx, "adapter."+operation,
+		tracing.SourceIDKey.String(sourceID), semconv.DBStatementKey.String(statement))
+	start := time.Now()
+	err := fn(ctx)
+	metrics.ObserveQuery(sourceID, operation, time.Since(start), err)
+	tracing.End(span, err)
+	return err
+}
+
+// queryContext 数据集配置了超时时间时，脱离全局请求超时单独计时
+func queryContext(ctx context.Context, dataSet *entity.DataSet) (context.Context, context.CancelFunc) {
+	if dataSet.TimeoutSeconds == 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(detachedContext{ctx}, time.Duration(dataSet.TimeoutSeconds)*time.Second)
+}
+
+// limitError 查询超时、超过限制时转换为HTTP错误
+func limitError(ctx context.Context, dataSet *entity.DataSet, err error) error {
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		if dataSet.TimeoutSeconds > 0 {
+			return echo.NewHTTPError(http.StatusGatewayTimeout, fmt.Sprintf("查询超时，超过%d秒", dataSet.TimeoutSeconds))
+		}
+		return echo.NewHTTPError(http.StatusGatewayTimeout, "查询超时")
+	case errors.Is(ctx.Err(), context.Canceled):
+		return echo.NewHTTPError(http.StatusServiceUnavailable, "查询已被终止")
+	case errors.Is(err, model.ErrMaxRows):
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, fmt.Sprintf("查询结果超过最大行数%d，请缩小查询范围", dataSet.MaxRows))
+	case errors.Is(err, model.ErrMaxBytes):
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, fmt.Sprintf("查询结果超过最大字节数%d，请缩小查询范围", dataSet.MaxBytes))
+	}
+	return err
+}
+
+// detachedContext 保留上下文中的值，不继承截止时间与取消
+type detachedContext struct {
+	context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (detachedContext) Err() error {
+	return nil
 }
 
-func convertResponseParams(pagination *model.Pagination, dataSet *entity.DataSet) (interface{}, error) {
+// idempotency 幂等记录
+type idempotency struct {
+	// 请求参数摘要
+	Digest string `json:"digest"`
+	// 是否执行完成
+	Done   bool           `json:"done"`
+	Result *db.ExecResult `json:"result"`
+}
+
+// doExecIdempotent 根据Idempotency-Key幂等执行，同一调用者相同key重试时返回首次执行结果
+func doExecIdempotent(ctx context.Context, dataSet *entity.DataSet, key string, params map[string]interface{}) (*db.ExecResult, error) {
+	b, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		// 按调用者隔离，避免其他调用者使用相同key时获取首次执行结果
+		cacheKey = "ohmydata:idempotency:" + dataSet.ID + ":" + caller(ctx) + ":" + key
+		record   = &idempotency{Digest: fmt.Sprintf("%x", md5.Sum(b))}
+	)
+	ok, err := cache.SetNX(ctx, cacheKey, record, idempotencyTTL)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		var prev idempotency
+		if err := cache.Get(ctx, cacheKey, &prev); err != nil {
+			return nil, err
+		}
+		if prev.Digest != record.Digest {
+			return nil, echo.NewHTTPError(http.StatusUnprocessableEntity, "Idempotency-Key已用于不同的请求参数")
+		}
+		if !prev.Done {
+			return nil, echo.NewHTTPError(http.StatusConflict, "相同Idempotency-Key的请求正在处理中")
+		}
+		log.Logger().Debug("幂等请求，返回首次执行结果", zap.String("id", dataSet.ID), zap.String("key", key))
+		return prev.Result, nil
+	}
+
+	result, err := doExec(ctx, dataSet, params)
+	if err != nil {
+		// 执行失败允许重试
+		if err := cache.Del(ctx, cacheKey); err != nil {
+			log.Logger().Error("删除幂等记录错误", zap.String("id", dataSet.ID), zap.String("key", key), zap.Error(err))
+		}
+		return nil, err
+	}
+	record.Done = true
+	record.Result = result
+	if err := cache.Set(ctx, cacheKey, record, idempotencyTTL); err != nil {
+		// 保存结果失败时删除处理中标记，避免重试在有效期内一直冲突
+		log.Logger().Error("保存幂等结果错误", zap.String("id", dataSet.ID), zap.String("key", key), zap.Error(err))
+		if err := cache.Del(ctx, cacheKey); err != nil {
+			log.Logger().Error("删除幂等记录错误", zap.String("id", dataSet.ID), zap.String("key", key), zap.Error(err))
+		}
+	}
+	return result, nil
+}
+
+// diffResponseParams 按字段名比较响应参数
+func diffResponseParams(responseParams []*entity.ResponseParam, inferred []*InferredParam) *ResponseParamDiff {
+	diff := &ResponseParamDiff{
+		Params:  inferred,
+		Added:   make([]*InferredParam, 0),
+		Removed: make([]*entity.ResponseParam, 0),
+		Changed: make([]*ParamTypeChange, 0),
+	}
+	existing := make(map[string]*entity.ResponseParam, len(responseParams))
+	for _, responseParam := range responseParams {
+		existing[responseParam.Name] = responseParam
+	}
+	columns := make(map[string]bool, len(inferred))
+	for _, e := range inferred {
+		columns[e.Name] = true
+		responseParam, ok := existing[e.Name]
+		if !ok {
+			diff.Added = append(diff.Added, e)
+			continue
+		}
+		if responseParam.ParamType != e.ParamType {
+			diff.Changed = append(diff.Changed, &ParamTypeChange{
+				Name:   e.Name,
+				From:   responseParam.ParamType,
+				To:     e.ParamType,
+				Column: e.Column,
+			})
+		}
+	}
+	for _, responseParam := range responseParams {
+		if !columns[responseParam.Name] {
+			diff.Removed = append(diff.Removed, responseParam)
+		}
+	}
+	return diff
+}
+
+// withDefaults 未传的请求参数使用按参数类型转换后的默认值，不修改原参数，调用、预览渲染、推断以及执行计划均使用
+func withDefaults(dataSet *entity.DataSet, params map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(params)+len(dataSet.RequestParams))
+	for _, requestParam := range dataSet.RequestParams {
+		if requestParam.DefaultValue != "" {
+			result[requestParam.Name] = defaultValue(requestParam.ParamType, requestParam.DefaultValue)
+		}
+	}
+	for k, v := range params {
+		result[k] = v
+	}
+	return result
+}
+
+// newExpressionTemplate 创建表达式模板，bind方法将参数以?占位并加入绑定参数
+func newExpressionTemplate(name string, args *[]interface{}) *template.Template {
+	return template.New(name).Funcs(template.FuncMap{
+		"bind": func(v interface{}) string {
+			*args = append(*args, v)
+			return "?"
+		},
+	})
+}
+
+// defaultValue 按参数类型转换默认值，无法转换时为字符串
+func defaultValue(paramType entity.ParamType, value string) interface{} {
+	if value == "" {
+		return nil
+	}
+	switch paramType {
+	case entity.Boolean:
+		if v, err := strconv.ParseBool(value); err == nil {
+			return v
+		}
+	case entity.Int, entity.Long:
+		if v, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return v
+		}
+	case entity.Float, entity.Double:
+		if v, err := strconv.ParseFloat(value, 64); err == nil {
+			return v
+		}
+	}
+	return value
+}
+
+// renderExpression 渲染表达式，返回SQL以及绑定参数
+func renderExpression(dataSet *entity.DataSet, params map[string]interface{}) (string, []interface{}, error) {
+	var (
+		buff bytes.Buffer
+		args []interface{}
+	)
+	tpl, err := newExpressionTemplate(dataSet.ID, &args).Parse(dataSet.Expression)
+	if err != nil {
+		return "", nil, err
+	}
+	if err := tpl.Execute(&buff, params); err != nil {
+		return "", nil, err
+	}
+	return buff.String(), args, nil
+}
+
+// convertResponseParams 按响应参数过滤、转换字段，指定fields时仅保留对应的响应字段（转换后的名称）
+func convertResponseParams(pagination *model.Pagination, dataSet *entity.DataSet, fields ...string) (interface{}, error) {
 	if pagination.Data == nil {
 		return nil, nil
 	}
+	responseParams := selectResponseParams(dataSet.ResponseParams, fields)
 	if list, ok := pagination.Data.([]map[string]interface{}); ok {
 		for _, row := range list {
 			// 字段过滤
 			if len(dataSet.ResponseParams) > 0 {
 				for k := range row {
 					var ok bool
-					for _, p := range dataSet.ResponseParams {
+					for _, p := range responseParams {
 						if k == p.Name {
 							ok = true
 							break
@@ -616,7 +1302,7 @@ func convertResponseParams(pagination *model.Pagination, dataSet *entity.DataSet
 				}
 			}
 			// 处理字段转换
-			for _, p := range dataSet.ResponseParams {
+			for _, p := range responseParams {
 				// 重命名
 				switch p.ConvertType {
 				case entity.ConvertNone:
@@ -668,6 +1354,14 @@ var funcNames = []string{
 	// 自定义
 	"pl",
 	"pt",
+	"bind",
+}
+
+// 写操作数据集支持的请求方法
+var writeMethods = []string{
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodDelete,
 }
 
 // pl 自定义template转换方法，将参数类型转中文
@@ -708,6 +1402,52 @@ func pt(paramType entity.ParamType) string {
 	return ""
 }
 
+// Handle 路径绑定的数据集：查询数据集响应GET以及未被写操作占用的POST，写操作数据集按请求方法绑定
+type Handle struct {
+	Query string            `json:"query"`
+	Write map[string]string `json:"write"`
+}
+
+// Lookup 请求方法对应的数据集ID，不存在时为空
+func (h *Handle) Lookup(method string) string {
+	if id, ok := h.Write[method]; ok {
+		return id
+	}
+	if method == http.MethodGet || method == http.MethodPost {
+		return h.Query
+	}
+	return ""
+}
+
+// Methods 支持的请求方法
+func (h *Handle) Methods() []string {
+	methods := make([]string, 0, 4)
+	if h.Query != "" {
+		methods = append(methods, http.MethodGet)
+	}
+	for _, method := range writeMethods {
+		if h.Lookup(method) != "" && !strutil.EqualAny(method, methods) {
+			methods = append(methods, method)
+		}
+	}
+	return methods
+}
+
+func (h *Handle) bind(dataSet *entity.DataSet) error {
+	if dataSet.Kind == entity.KindWrite {
+		if _, ok := h.Write[dataSet.Method]; ok {
+			return errors.New("路径重复注册")
+		}
+		h.Write[dataSet.Method] = dataSet.ID
+		return nil
+	}
+	if h.Query != "" {
+		return errors.New("路径重复注册")
+	}
+	h.Query = dataSet.ID
+	return nil
+}
+
 // Node 节点
 type Node struct {
 	sync.RWMutex
@@ -840,30 +1580,77 @@ func (n *Node) findChild(name string) (*Node, error) {
 	return node, nil
 }
 
+// Size 已绑定处理的路由数
+func (n *Node) Size() int {
+	n.RLock()
+	defer n.RUnlock()
+	var size int
+	if n.Handle != nil {
+		size++
+	}
+	for _, child := range n.Children {
+		size += child.Size()
+	}
+	return size
+}
+
 // SyncDataSet 同步数据集
 func SyncDataSet(dataSet *DataSet) {
 	go func() {
 		for {
 			log.Logger().Debug("同步数据集")
 
-			router := new(Node)
-
-			list, err := dataSet.All(context.TODO())
+			start := time.Now()
+			err := dataSet.LoadRoutes(context.TODO())
 			if err != nil {
 				log.Logger().Warn("查询数据集错误", zap.Error(err))
-			} else {
-				for _, e := range list {
-					if !e.PublishStatus {
-						continue
-					}
-					if err := router.Add(e.Path, e.ID); err != nil {
-						log.Logger().Warn("加载数据集错误", zap.String("path", e.Path), zap.Error(err))
-					}
-				}
+				dataSet.setRoutes(new(Node), nil)
 			}
-			dataSet.router = router
+			metrics.ObserveRouterSync(dataSet.APIRoutes().Size(), time.Since(start), err)
 
 			time.Sleep(30 * time.Second)
 		}
 	}()
 }
+
+// LoadRoutes 加载已发布数据集的API路由
+func (s *DataSet) LoadRoutes(ctx context.Context) error {
+	router := new(Node)
+
+	list, err := s.PublishedAll(ctx)
+	if err != nil {
+		return err
+	}
+	// 同一路径按请求方法绑定多个数据集
+	var (
+		paths   = make([]string, 0, len(list))
+		handles = make(map[string]*Handle, len(list))
+	)
+	for _, e := range list {
+		if !e.PublishStatus {
+			continue
+		}
+		path := strings.TrimPrefix(e.Path, "/")
+		handle, ok := handles[path]
+		if !ok {
+			handle = &Handle{Write: make(map[string]string)}
+			handles[path] = handle
+			paths = append(paths, path)
+		}
+		if err := handle.bind(e); err != nil {
+			log.Logger().Warn("加载数据集错误", zap.String("path", e.Path), zap.Error(err))
+		}
+	}
+	for _, path := range paths {
+		if err := router.Add(path, handles[path]); err != nil {
+			log.Logger().Warn("加载数据集错误", zap.String("path", path), zap.Error(err))
+		}
+	}
+	// 重建GraphQL schema，错误时不再使用旧的schema
+	schema, err := s.buildSchema(list)
+	if err != nil {
+		log.Logger().Warn("生成GraphQL schema错误", zap.Error(err))
+	}
+	s.setRoutes(router, schema)
+	return nil
+}
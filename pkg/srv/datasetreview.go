@@ -0,0 +1,233 @@
+package srv
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/xuanbo/ohmydata/pkg/db"
+	"github.com/xuanbo/ohmydata/pkg/entity"
+	"github.com/xuanbo/ohmydata/pkg/log"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Transition 审核状态流转
+type Transition struct {
+	Action entity.ReviewAction `json:"action"`
+	// 提交审核时指定审核人
+	Reviewer string `json:"reviewer"`
+	Comment  string `json:"comment"`
+}
+
+// Reviews 审核记录，按时间排序
+func (s *DataSet) Reviews(ctx context.Context, id string) ([]*entity.DataSetReview, error) {
+	var list []*entity.DataSetReview
+	if err := s.db.WithContext(ctx).Where("data_set_id = ?", id).Order("id").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// Transition 审核状态流转并记录：草稿提交审核，审核人（非提交人、非版本作者）通过或驳回，审核通过后才能发布
+func (s *DataSet) Transition(ctx context.Context, id string, transition *Transition) (*entity.DataSetReview, error) {
+	actor := caller(ctx)
+	if actor == "" {
+		return nil, echo.NewHTTPError(http.StatusUnauthorized, "未登录")
+	}
+	// 发布前校验执行计划
+	if transition.Action == entity.ActionPublish {
+		if err := s.checkPublish(ctx, id); err != nil {
+			return nil, err
+		}
+	}
+
+	var review *entity.DataSetReview
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var dataSet entity.DataSet
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).Find(&dataSet).Error; err != nil {
+			return err
+		}
+		if dataSet.ID == "" {
+			return echo.NewHTTPError(http.StatusNotFound, "数据集不存在")
+		}
+		// 托管的数据集按目录定义发布，仅允许评论
+		if dataSet.Managed && transition.Action != entity.ActionComment {
+			return echo.NewHTTPError(http.StatusForbidden, "由GitOps目录管理，发布状态以目录定义为准")
+		}
+		review = &entity.DataSetReview{
+			DataSetID: id,
+			Version:   dataSet.Version,
+			Action:    transition.Action,
+			FromState: dataSet.State,
+			ToState:   dataSet.State,
+			Comment:   transition.Comment,
+		}
+		values := make(map[string]interface{})
+
+		switch transition.Action {
+		case entity.ActionSubmit:
+			if err := requireState(&dataSet, entity.StateDraft); err != nil {
+				return err
+			}
+			if transition.Reviewer == "" {
+				return echo.NewHTTPError(http.StatusBadRequest, "提交审核需指定审核人")
+			}
+			if transition.Reviewer == actor {
+				return echo.NewHTTPError(http.StatusBadRequest, "审核人不能为提交人")
+			}
+			author, err := versionAuthor(tx, id, dataSet.Version)
+			if err != nil {
+				return err
+			}
+			if transition.Reviewer == author || transition.Reviewer == dataSet.UpdatedBy {
+				return echo.NewHTTPError(http.StatusBadRequest, "审核人不能为待审核版本的作者")
+			}
+			var total int64
+			if err := tx.Model(&entity.User{}).Where("id = ?", transition.Reviewer).Count(&total).Error; err != nil {
+				return err
+			}
+			if total == 0 {
+				return echo.NewHTTPError(http.StatusBadRequest, "审核人不存在")
+			}
+			review.Reviewer = transition.Reviewer
+			review.ToState = entity.StatePending
+			values["reviewer"] = transition.Reviewer
+		case entity.ActionWithdraw:
+			if err := requireState(&dataSet, entity.StatePending); err != nil {
+				return err
+			}
+			submitter, err := lastSubmitter(tx, id)
+			if err != nil {
+				return err
+			}
+			if actor != submitter {
+				return echo.NewHTTPError(http.StatusForbidden, "仅提交人可以撤回审核")
+			}
+			review.ToState = entity.StateDraft
+		case entity.ActionApprove, entity.ActionReject:
+			if err := requireState(&dataSet, entity.StatePending); err != nil {
+				return err
+			}
+			if actor != dataSet.Reviewer {
+				return echo.NewHTTPError(http.StatusForbidden, "仅指定的审核人可以审核")
+			}
+			submitter, err := lastSubmitter(tx, id)
+			if err != nil {
+				return err
+			}
+			if actor == submitter {
+				return echo.NewHTTPError(http.StatusForbidden, "审核人不能为提交人")
+			}
+			author, err := versionAuthor(tx, id, dataSet.Version)
+			if err != nil {
+				return err
+			}
+			if actor == author || actor == dataSet.UpdatedBy {
+				return echo.NewHTTPError(http.StatusForbidden, "审核人不能为待审核版本的作者")
+			}
+			if transition.Action == entity.ActionApprove {
+				review.ToState = entity.StateApproved
+			} else {
+				if transition.Comment == "" {
+					return echo.NewHTTPError(http.StatusBadRequest, "驳回时需填写意见")
+				}
+				review.ToState = entity.StateDraft
+			}
+		case entity.ActionPublish:
+			if err := requireState(&dataSet, entity.StateApproved); err != nil {
+				return err
+			}
+			// 发布审核通过的版本
+			review.ToState = entity.StatePublished
+			values["publish_status"] = true
+			values["published_version"] = dataSet.Version
+		case entity.ActionDeprecate:
+			if !dataSet.PublishStatus || dataSet.State == entity.StateDeprecated {
+				return echo.NewHTTPError(http.StatusConflict, "仅已发布的数据集可以弃用")
+			}
+			review.ToState = entity.StateDeprecated
+		case entity.ActionRetire:
+			if !dataSet.PublishStatus {
+				return echo.NewHTTPError(http.StatusConflict, "仅已发布的数据集可以下线")
+			}
+			review.ToState = entity.StateRetired
+			values["publish_status"] = false
+		case entity.ActionComment:
+			if transition.Comment == "" {
+				return echo.NewHTTPError(http.StatusBadRequest, "评论不能为空")
+			}
+		default:
+			return echo.NewHTTPError(http.StatusBadRequest, "不支持的审核动作: "+string(transition.Action))
+		}
+
+		if review.ToState != review.FromState {
+			values["state"] = review.ToState
+		}
+		if len(values) > 0 {
+			if err := tx.Model(&entity.DataSet{}).Where("id = ?", id).Updates(values).Error; err != nil {
+				return err
+			}
+		}
+		review.ID = db.NewID()
+		return tx.Create(review).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	// 清除缓存
+	s.clearCache(ctx, "all")
+	s.clearCache(ctx, id)
+	log.Logger().Info("数据集审核", zap.String("id", id), zap.String("action", string(review.Action)),
+		zap.Uint8("from", uint8(review.FromState)), zap.Uint8("to", uint8(review.ToState)), zap.String("actor", actor))
+	return review, nil
+}
+
+// recordEdit 修改后回到草稿，非草稿时记录状态变更
+func recordEdit(tx *gorm.DB, dataSet *entity.DataSet) error {
+	var state entity.DataSetState
+	if err := tx.Model(&entity.DataSet{}).Select("state").Where("id = ?", dataSet.ID).Scan(&state).Error; err != nil {
+		return err
+	}
+	dataSet.State = entity.StateDraft
+	dataSet.Reviewer = ""
+	if state == entity.StateDraft {
+		return nil
+	}
+	review := &entity.DataSetReview{
+		DataSetID: dataSet.ID,
+		Version:   dataSet.Version,
+		Action:    entity.ActionEdit,
+		FromState: state,
+		ToState:   entity.StateDraft,
+	}
+	review.ID = db.NewID()
+	return tx.Create(review).Error
+}
+
+func requireState(dataSet *entity.DataSet, state entity.DataSetState) error {
+	if dataSet.State != state {
+		return echo.NewHTTPError(http.StatusConflict, "当前状态不允许该操作")
+	}
+	return nil
+}
+
+// lastSubmitter 最近一次提交审核的操作人
+func lastSubmitter(tx *gorm.DB, id string) (string, error) {
+	var review entity.DataSetReview
+	if err := tx.Where("data_set_id = ? AND action = ?", id, entity.ActionSubmit).Order("id DESC").Limit(1).Find(&review).Error; err != nil {
+		return "", err
+	}
+	return review.CreatedBy, nil
+}
+
+// versionAuthor 版本的作者，即保存该版本的操作人
+func versionAuthor(tx *gorm.DB, id string, version uint) (string, error) {
+	var dataSetVersion entity.DataSetVersion
+	if err := tx.Where("data_set_id = ? AND version = ?", id, version).Limit(1).Find(&dataSetVersion).Error; err != nil {
+		return "", err
+	}
+	return dataSetVersion.CreatedBy, nil
+}
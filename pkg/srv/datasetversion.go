@@ -0,0 +1,298 @@
+package srv
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/xuanbo/ohmydata/pkg/cache"
+	"github.com/xuanbo/ohmydata/pkg/db"
+	"github.com/xuanbo/ohmydata/pkg/entity"
+	"github.com/xuanbo/ohmydata/pkg/log"
+	"github.com/xuanbo/ohmydata/pkg/strutil"
+
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// VersionDiff 两个版本的差异
+type VersionDiff struct {
+	From uint `json:"from"`
+	To   uint `json:"to"`
+	// 配置字段的差异
+	Fields []*FieldChange `json:"fields"`
+	// 参数按名称比较，新增时From为空，移除时To为空
+	RequestParams  []*FieldChange `json:"requestParams"`
+	ResponseParams []*FieldChange `json:"responseParams"`
+}
+
+// FieldChange 字段变更
+type FieldChange struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from"`
+	To    interface{} `json:"to"`
+}
+
+// 不参与版本比较的字段
+var versionIgnoredFields = []string{
+	"id", "dataSetId", "createdAt", "updatedAt", "createdBy", "updatedBy",
+	"publishStatus", "version", "publishedVersion", "state", "reviewer", "managed", "requestParams", "responseParams",
+}
+
+// Versions 版本列表，按版本倒序
+func (s *DataSet) Versions(ctx context.Context, id string) ([]*entity.DataSetVersion, error) {
+	var list []*entity.DataSetVersion
+	if err := s.db.WithContext(ctx).Omit("snapshot").Where("data_set_id = ?", id).Order("version DESC").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// Version 版本快照
+func (s *DataSet) Version(ctx context.Context, id string, version uint) (*entity.DataSet, error) {
+	var dataSetVersion entity.DataSetVersion
+	if err := s.db.WithContext(ctx).Where("data_set_id = ? AND version = ?", id, version).Find(&dataSetVersion).Error; err != nil {
+		return nil, err
+	}
+	if dataSetVersion.ID == "" {
+		return nil, fmt.Errorf("数据集版本不存在: %d", version)
+	}
+	var dataSet entity.DataSet
+	if err := json.Unmarshal([]byte(dataSetVersion.Snapshot), &dataSet); err != nil {
+		return nil, err
+	}
+	return &dataSet, nil
+}
+
+// DiffVersions 比较两个版本
+func (s *DataSet) DiffVersions(ctx context.Context, id string, from, to uint) (*VersionDiff, error) {
+	fromDataSet, err := s.Version(ctx, id, from)
+	if err != nil {
+		return nil, err
+	}
+	toDataSet, err := s.Version(ctx, id, to)
+	if err != nil {
+		return nil, err
+	}
+	diff := &VersionDiff{From: from, To: to}
+	if diff.Fields, err = diffFields(fromDataSet, toDataSet); err != nil {
+		return nil, err
+	}
+	var fromParams, toParams []interface{}
+	for _, e := range fromDataSet.RequestParams {
+		fromParams = append(fromParams, e)
+	}
+	for _, e := range toDataSet.RequestParams {
+		toParams = append(toParams, e)
+	}
+	if diff.RequestParams, err = diffParams(fromParams, toParams); err != nil {
+		return nil, err
+	}
+	fromParams, toParams = nil, nil
+	for _, e := range fromDataSet.ResponseParams {
+		fromParams = append(fromParams, e)
+	}
+	for _, e := range toDataSet.ResponseParams {
+		toParams = append(toParams, e)
+	}
+	if diff.ResponseParams, err = diffParams(fromParams, toParams); err != nil {
+		return nil, err
+	}
+	return diff, nil
+}
+
+// Rollback 以历史版本覆盖当前数据集并生成新版本，回到草稿，需重新审核后发布
+func (s *DataSet) Rollback(ctx context.Context, id string, version uint) (*entity.DataSet, error) {
+	current, err := s.ID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if current == nil {
+		return nil, errors.New("数据集不存在")
+	}
+	dataSet, err := s.Version(ctx, id, version)
+	if err != nil {
+		return nil, err
+	}
+	dataSet.ID = id
+	if err := s.Modify(ctx, dataSet); err != nil {
+		return nil, err
+	}
+	dataSet.PublishStatus = current.PublishStatus
+	dataSet.PublishedVersion = current.PublishedVersion
+	log.Logger().Info("数据集回滚", zap.String("id", id), zap.Uint("from", version), zap.Uint("version", dataSet.Version))
+	return dataSet, nil
+}
+
+// Published 发布版本的数据集详情，未记录发布版本时为当前详情
+func (s *DataSet) Published(ctx context.Context, id string) (*entity.DataSet, error) {
+	var (
+		dataSet *entity.DataSet
+		key     = "ohmydata:dataset:" + id + ":published"
+		err     error
+	)
+	if err = cache.Get(ctx, key, &dataSet); errors.Is(err, redis.Nil) {
+		current, err := s.ID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if current == nil {
+			return nil, nil
+		}
+		if current.PublishedVersion == 0 {
+			return s.Detail(ctx, id)
+		}
+		if dataSet, err = s.Version(ctx, id, current.PublishedVersion); err != nil {
+			return nil, err
+		}
+		dataSet.PublishStatus = current.PublishStatus
+		dataSet.PublishedVersion = current.PublishedVersion
+		dataSet.State = current.State
+		// 写入缓存
+		cache.Set(ctx, key, dataSet, cacheTTL)
+		return dataSet, nil
+	}
+	return dataSet, err
+}
+
+// PublishedAll 所有已发布数据集的发布版本
+func (s *DataSet) PublishedAll(ctx context.Context) ([]*entity.DataSet, error) {
+	list, err := s.All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	published := make([]*entity.DataSet, 0, len(list))
+	for _, e := range list {
+		if !e.PublishStatus {
+			continue
+		}
+		if e.PublishedVersion == 0 {
+			published = append(published, e)
+			continue
+		}
+		dataSet, err := s.Published(ctx, e.ID)
+		if err != nil {
+			log.Logger().Warn("查询数据集发布版本错误", zap.String("id", e.ID), zap.Error(err))
+			continue
+		}
+		if dataSet != nil {
+			published = append(published, dataSet)
+		}
+	}
+	return published, nil
+}
+
+// createVersion 事务中生成版本快照，并设置数据集当前版本
+func createVersion(tx *gorm.DB, dataSet *entity.DataSet) error {
+	var version uint
+	if err := tx.Model(&entity.DataSetVersion{}).Select("COALESCE(MAX(version), 0)").
+		Where("data_set_id = ?", dataSet.ID).Scan(&version).Error; err != nil {
+		return err
+	}
+	dataSet.Version = version + 1
+	b, err := json.Marshal(dataSet)
+	if err != nil {
+		return err
+	}
+	dataSetVersion := &entity.DataSetVersion{
+		DataSetID: dataSet.ID,
+		Version:   dataSet.Version,
+		Snapshot:  string(b),
+	}
+	dataSetVersion.ID = db.NewID()
+	return tx.Create(dataSetVersion).Error
+}
+
+// diffFields 按JSON字段比较配置
+func diffFields(from, to interface{}) ([]*FieldChange, error) {
+	fromFields, err := toMap(from)
+	if err != nil {
+		return nil, err
+	}
+	toFields, err := toMap(to)
+	if err != nil {
+		return nil, err
+	}
+	changes := make([]*FieldChange, 0)
+	for _, field := range sortedKeys(fromFields, toFields) {
+		if strutil.EqualAny(field, versionIgnoredFields) {
+			continue
+		}
+		if !reflect.DeepEqual(fromFields[field], toFields[field]) {
+			changes = append(changes, &FieldChange{Field: field, From: fromFields[field], To: toFields[field]})
+		}
+	}
+	return changes, nil
+}
+
+// diffParams 按参数名称比较参数
+func diffParams(from, to []interface{}) ([]*FieldChange, error) {
+	fromParams, err := paramMaps(from)
+	if err != nil {
+		return nil, err
+	}
+	toParams, err := paramMaps(to)
+	if err != nil {
+		return nil, err
+	}
+	changes := make([]*FieldChange, 0)
+	for _, name := range sortedKeys(fromParams, toParams) {
+		fromParam, fromOk := fromParams[name]
+		toParam, toOk := toParams[name]
+		switch {
+		case !fromOk:
+			changes = append(changes, &FieldChange{Field: name, To: toParam})
+		case !toOk:
+			changes = append(changes, &FieldChange{Field: name, From: fromParam})
+		case !reflect.DeepEqual(fromParam, toParam):
+			changes = append(changes, &FieldChange{Field: name, From: fromParam, To: toParam})
+		}
+	}
+	return changes, nil
+}
+
+// paramMaps 参数按名称转为map，去除不参与比较的字段
+func paramMaps(params []interface{}) (map[string]interface{}, error) {
+	result := make(map[string]interface{}, len(params))
+	for _, param := range params {
+		m, err := toMap(param)
+		if err != nil {
+			return nil, err
+		}
+		for _, field := range versionIgnoredFields {
+			delete(m, field)
+		}
+		result[fmt.Sprintf("%v", m["name"])] = m
+	}
+	return result, nil
+}
+
+// sortedKeys 合并后排序的key
+func sortedKeys(maps ...map[string]interface{}) []string {
+	keys := make([]string, 0, 16)
+	for _, m := range maps {
+		for k := range m {
+			if !strutil.EqualAny(k, keys) {
+				keys = append(keys, k)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func toMap(v interface{}) (map[string]interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
@@ -3,15 +3,18 @@ package srv
 import (
 	"context"
 	"errors"
+	"net/http"
 	"time"
 
 	"github.com/xuanbo/ohmydata/pkg/cache"
 	"github.com/xuanbo/ohmydata/pkg/db"
+	"github.com/xuanbo/ohmydata/pkg/db/stmt"
 	"github.com/xuanbo/ohmydata/pkg/entity"
 	"github.com/xuanbo/ohmydata/pkg/log"
 	"github.com/xuanbo/ohmydata/pkg/model"
 
 	"github.com/go-redis/redis/v8"
+	"github.com/labstack/echo/v4"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
 )
@@ -28,16 +31,11 @@ func NewDataSource() *DataSource {
 
 // Create 新增
 func (s *DataSource) Create(ctx context.Context, dataSource *entity.DataSource) error {
-	if dataSource.Type == "" || dataSource.Name == "" || dataSource.URL == "" {
-		return errors.New("字段[type、name、url]不能为空")
+	if err := validDataSource(dataSource); err != nil {
+		return err
 	}
 	dataSource.ID = db.NewID()
-	if dataSource.MaxIdleConns < 1 {
-		dataSource.MaxIdleConns = 1
-	}
-	if dataSource.MaxOpenConns < 1 {
-		dataSource.MaxOpenConns = 8
-	}
+	dataSource.Managed = false
 
 	if err := s.db.WithContext(ctx).Create(dataSource).Error; err != nil {
 		return err
@@ -55,15 +53,13 @@ func (s *DataSource) Modify(ctx context.Context, dataSource *entity.DataSource)
 	if dataSource.ID == "" {
 		return errors.New("更新时主键不能为空")
 	}
-	if dataSource.Type == "" || dataSource.Name == "" || dataSource.URL == "" {
-		return errors.New("字段[type、name、url]不能为空")
-	}
-	if dataSource.MaxIdleConns < 1 {
-		dataSource.MaxIdleConns = 1
+	if err := validDataSource(dataSource); err != nil {
+		return err
 	}
-	if dataSource.MaxOpenConns < 1 {
-		dataSource.MaxOpenConns = 8
+	if err := checkManaged(s.db.WithContext(ctx), &entity.DataSource{}, dataSource.ID); err != nil {
+		return err
 	}
+	dataSource.Managed = false
 
 	if err := s.db.WithContext(ctx).Save(dataSource).Error; err != nil {
 		return err
@@ -97,6 +93,9 @@ func (s *DataSource) All(ctx context.Context) ([]*entity.DataSource, error) {
 
 // Remove 删除
 func (s *DataSource) Remove(ctx context.Context, id string) error {
+	if err := checkManaged(s.db.WithContext(ctx), &entity.DataSource{}, id); err != nil {
+		return err
+	}
 	if err := s.db.WithContext(ctx).Delete(&entity.DataSource{}, id).Error; err != nil {
 		return err
 	}
@@ -142,6 +141,15 @@ func (s *DataSource) TableNames(ctx context.Context, id string) ([]string, error
 	return adapter.TableNames(ctx)
 }
 
+// TableMetas 查询表及类型
+func (s *DataSource) TableMetas(ctx context.Context, id string) ([]*db.TableMeta, error) {
+	adapter, err := db.GetAdapter(id)
+	if err != nil {
+		return nil, err
+	}
+	return adapter.TableMetas(ctx)
+}
+
 // Table 查询表结构
 func (s *DataSource) Table(ctx context.Context, id, name string) (*db.Table, error) {
 	adapter, err := db.GetAdapter(id)
@@ -162,11 +170,40 @@ func (s *DataSource) QueryTable(ctx context.Context, id, tableName string, page
 
 // Query 查询数据
 func (s *DataSource) Query(ctx context.Context, id, exp string, page *model.Pagination) error {
+	if err := stmt.CheckRead(exp); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
 	adapter, err := db.GetAdapter(id)
 	if err != nil {
 		return err
 	}
-	return adapter.Query(ctx, exp, page)
+	ctx, finish := db.StartRunning(ctx, &db.Running{SourceID: id, Caller: caller(ctx), SQL: exp})
+	defer finish()
+	return observeAdapter(ctx, id, "query", exp, func(ctx context.Context) error {
+		return adapter.Query(ctx, exp, page)
+	})
+}
+
+// Load 同步加载数据源驱动
+func (s *DataSource) Load(ctx context.Context, id string) error {
+	var dataSource entity.DataSource
+	if err := s.db.WithContext(ctx).Where("id = ?", id).Find(&dataSource).Error; err != nil {
+		return err
+	}
+	if dataSource.ID == "" {
+		return errors.New("数据源不存在")
+	}
+	return putAdapter(&dataSource)
+}
+
+// Running 运行中的查询
+func (s *DataSource) Running(ctx context.Context, id string) []*db.Running {
+	return db.ListRunning(id)
+}
+
+// Kill 终止运行中的查询
+func (s *DataSource) Kill(ctx context.Context, id, runningID string) error {
+	return db.KillRunning(ctx, id, runningID)
 }
 
 func (s *DataSource) clearCache(ctx context.Context, id string) {
@@ -175,6 +212,20 @@ func (s *DataSource) clearCache(ctx context.Context, id string) {
 	cache.DelMatch(ctx, "ohmydata:datasource:"+id+"*")
 }
 
+// validDataSource 校验必填字段并设置连接池默认值
+func validDataSource(dataSource *entity.DataSource) error {
+	if dataSource.Type == "" || dataSource.Name == "" || dataSource.URL == "" {
+		return errors.New("字段[type、name、url]不能为空")
+	}
+	if dataSource.MaxIdleConns < 1 {
+		dataSource.MaxIdleConns = 1
+	}
+	if dataSource.MaxOpenConns < 1 {
+		dataSource.MaxOpenConns = 8
+	}
+	return nil
+}
+
 func putAdapter(dataSource *entity.DataSource) error {
 	// 适配层新增
 	factory, err := db.GetAdapterFactory(dataSource.Type)
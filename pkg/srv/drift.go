@@ -0,0 +1,220 @@
+package srv
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/xuanbo/ohmydata/pkg/config"
+	"github.com/xuanbo/ohmydata/pkg/db"
+	orm "github.com/xuanbo/ohmydata/pkg/db/gorm"
+	"github.com/xuanbo/ohmydata/pkg/entity"
+	"github.com/xuanbo/ohmydata/pkg/log"
+	"github.com/xuanbo/ohmydata/pkg/model"
+	"github.com/xuanbo/ohmydata/pkg/model/condition"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// Drift 结构漂移检测服务
+type Drift struct {
+	db      *gorm.DB
+	engine  *orm.Engine
+	dataSet *DataSet
+	// 检测间隔，0表示不检测
+	interval time.Duration
+	// 回调地址
+	webhook string
+	client  *http.Client
+}
+
+// NewDrift 创建实例
+func NewDrift(dataSet *DataSet) *Drift {
+	return &Drift{
+		db:       db.DB(),
+		engine:   orm.New(db.DB()),
+		dataSet:  dataSet,
+		interval: time.Duration(config.GetInt("drift.interval")) * time.Second,
+		webhook:  config.GetString("drift.webhook"),
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Page 分页查询漂移事件
+func (s *Drift) Page(ctx context.Context, dataSetID string, page *model.Pagination) error {
+	var (
+		total uint64
+		list  []*entity.DriftEvent
+		err   error
+	)
+	combineClause := condition.NewCombineClause(condition.CombineAnd)
+	if dataSetID != "" {
+		combineClause.Add(condition.Eq("data_set_id", dataSetID))
+	}
+	clause := condition.WrapCombineClause(combineClause)
+
+	if total, err = s.engine.Page(
+		entity.DriftEvent{}.TableName(),
+		&list,
+		selectOptionFunc.WithClause(clause),
+		selectOptionFunc.WithContext(ctx),
+		selectOptionFunc.WithPageSize(page.Page, page.Size),
+		selectOptionFunc.WithTablePrefix("`"),
+		selectOptionFunc.WithTableSuffix("`"),
+		selectOptionFunc.WithColumnPrefix("`"),
+		selectOptionFunc.WithColumnSuffix("`"),
+	); err != nil {
+		return err
+	}
+	if total < 1 {
+		return nil
+	}
+	page.Set(total, list)
+	return nil
+}
+
+// Check 检测所有已发布的查询数据集，返回本次新记录的漂移以及恢复事件
+func (s *Drift) Check(ctx context.Context) ([]*entity.DriftEvent, error) {
+	list, err := s.dataSet.All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	events := make([]*entity.DriftEvent, 0)
+	for _, e := range list {
+		if !e.PublishStatus || e.Kind != entity.KindQuery {
+			continue
+		}
+		event, err := s.checkDataSet(ctx, e.ID)
+		if err != nil {
+			log.Logger().Warn("数据集结构漂移检测错误", zap.String("id", e.ID), zap.Error(err))
+			continue
+		}
+		if event != nil {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+// checkDataSet 以请求参数默认值推断发布版本的响应参数并比较，与上次记录的状态不同时记录漂移或恢复事件
+func (s *Drift) checkDataSet(ctx context.Context, id string) (*entity.DriftEvent, error) {
+	dataSet, err := s.dataSet.Published(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if dataSet == nil {
+		return nil, nil
+	}
+	inferred, err := s.dataSet.InferResponseParams(ctx, dataSet, make(map[string]interface{}))
+	if err != nil {
+		return nil, err
+	}
+	diff := diffResponseParams(dataSet.ResponseParams, inferred)
+
+	var last entity.DriftEvent
+	if err := s.db.WithContext(ctx).Where("data_set_id = ?", id).Order("id DESC").Limit(1).Find(&last).Error; err != nil {
+		return nil, err
+	}
+	var event *entity.DriftEvent
+	if len(diff.Added) == 0 && len(diff.Removed) == 0 && len(diff.Changed) == 0 {
+		// 未记录过漂移或已恢复
+		if last.ID == "" || last.Resolved {
+			return nil, nil
+		}
+		event = &entity.DriftEvent{DataSetID: dataSet.ID, DataSetName: dataSet.Name, Resolved: true}
+	} else {
+		event = newDriftEvent(dataSet, diff)
+		// 与上次记录的漂移相同且未恢复则不重复记录
+		if last.ID != "" && !last.Resolved && last.Digest == event.Digest {
+			return nil, nil
+		}
+	}
+	event.ID = db.NewID()
+	if err := s.db.WithContext(ctx).Create(event).Error; err != nil {
+		return nil, err
+	}
+	if event.Resolved {
+		log.Logger().Info("数据集结构漂移已恢复", zap.String("id", id))
+	} else {
+		log.Logger().Warn("数据集结构漂移", zap.String("id", id), zap.String("added", event.Added),
+			zap.String("removed", event.Removed), zap.String("changed", event.Changed))
+	}
+	s.notify(ctx, event)
+	return event, nil
+}
+
+// notify 回调webhook，失败仅记录日志
+func (s *Drift) notify(ctx context.Context, event *entity.DriftEvent) {
+	if s.webhook == "" {
+		return
+	}
+	b, err := json.Marshal(event)
+	if err != nil {
+		log.Logger().Warn("结构漂移回调错误", zap.Error(err))
+		return
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.webhook, bytes.NewReader(b))
+	if err != nil {
+		log.Logger().Warn("结构漂移回调错误", zap.Error(err))
+		return
+	}
+	req.Header.Set("Content-Type", "application/json;charset=utf8")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		log.Logger().Warn("结构漂移回调错误", zap.String("webhook", s.webhook), zap.Error(err))
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		log.Logger().Warn("结构漂移回调错误", zap.String("webhook", s.webhook), zap.String("status", resp.Status))
+	}
+}
+
+func newDriftEvent(dataSet *entity.DataSet, diff *ResponseParamDiff) *entity.DriftEvent {
+	var (
+		added   = make([]string, len(diff.Added))
+		removed = make([]string, len(diff.Removed))
+		changed = make([]string, len(diff.Changed))
+	)
+	for i, e := range diff.Added {
+		added[i] = e.Name
+	}
+	for i, e := range diff.Removed {
+		removed[i] = e.Name
+	}
+	for i, e := range diff.Changed {
+		changed[i] = fmt.Sprintf("%s(%s->%s)", e.Name, pt(e.From), pt(e.To))
+	}
+	event := &entity.DriftEvent{
+		DataSetID:   dataSet.ID,
+		DataSetName: dataSet.Name,
+		Added:       strings.Join(added, ","),
+		Removed:     strings.Join(removed, ","),
+		Changed:     strings.Join(changed, ","),
+	}
+	event.Digest = fmt.Sprintf("%x", md5.Sum([]byte(event.Added+"|"+event.Removed+"|"+event.Changed)))
+	return event
+}
+
+// SyncDrift 定时检测结构漂移
+func SyncDrift(drift *Drift) {
+	if drift.interval <= 0 {
+		return
+	}
+	go func() {
+		for {
+			time.Sleep(drift.interval)
+
+			log.Logger().Debug("检测数据集结构漂移")
+			if _, err := drift.Check(context.TODO()); err != nil {
+				log.Logger().Warn("检测数据集结构漂移错误", zap.Error(err))
+			}
+		}
+	}()
+}
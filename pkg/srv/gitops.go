@@ -0,0 +1,468 @@
+package srv
+
+import (
+	"context"
+	"crypto/md5"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xuanbo/ohmydata/pkg/config"
+	"github.com/xuanbo/ohmydata/pkg/db"
+	"github.com/xuanbo/ohmydata/pkg/entity"
+	"github.com/xuanbo/ohmydata/pkg/log"
+	"github.com/xuanbo/ohmydata/pkg/strutil"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GitOpsStatus 最近一次同步结果
+type GitOpsStatus struct {
+	Enabled bool   `json:"enabled"`
+	Dir     string `json:"dir"`
+	// 目录内容摘要，内容不变时不重复同步
+	Digest       string            `json:"digest"`
+	ReconciledAt *entity.Time      `json:"reconciledAt"`
+	Changes      []*BundleChange   `json:"changes"`
+	Errors       []*ReconcileError `json:"errors"`
+}
+
+// ReconcileError 同步错误
+type ReconcileError struct {
+	File  string `json:"file,omitempty"`
+	Type  string `json:"type,omitempty"`
+	Name  string `json:"name,omitempty"`
+	Error string `json:"error"`
+}
+
+// GitOps 从目录的yaml文件同步数据源、数据集，同步的记录为托管，不允许在界面修改。
+// 数据集的发布状态以定义的published为准，托管的数据集不经过审核流转
+type GitOps struct {
+	db     *gorm.DB
+	bundle *Bundle
+	// 定义目录，为空不开启
+	dir string
+	// 接管已存在的同名非托管记录
+	adopt bool
+
+	mu     sync.Mutex
+	status *GitOpsStatus
+}
+
+// NewGitOps 创建实例
+func NewGitOps(bundle *Bundle) *GitOps {
+	dir := config.GetString("gitops.dir")
+	return &GitOps{
+		db:     db.DB(),
+		bundle: bundle,
+		dir:    dir,
+		adopt:  config.GetBool("gitops.adopt"),
+		status: &GitOpsStatus{Enabled: dir != "", Dir: dir},
+	}
+}
+
+// Status 最近一次同步结果
+func (s *GitOps) Status() *GitOpsStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status
+}
+
+// Reconcile 同步目录：新增、更新定义的记录并标记为托管，删除不再定义的托管记录。
+// 每项单独保存，失败记录错误后继续；存在无法解析的文件时不删除
+func (s *GitOps) Reconcile(ctx context.Context) (*GitOpsStatus, error) {
+	if s.dir == "" {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "未开启GitOps")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := entity.Now()
+	status := &GitOpsStatus{
+		Enabled:      true,
+		Dir:          s.dir,
+		ReconciledAt: &now,
+		Changes:      make([]*BundleChange, 0),
+		Errors:       make([]*ReconcileError, 0),
+	}
+	defer func() {
+		s.status = status
+	}()
+
+	doc, files, digest, complete := s.load(status)
+	status.Digest = digest
+
+	changes, err := s.bundle.planDataSources(ctx, doc, ConflictOverwrite)
+	if err != nil {
+		status.Errors = append(status.Errors, &ReconcileError{Error: errorMessage(err)})
+		return status, nil
+	}
+	dataSetChanges, err := s.bundle.planDataSets(ctx, doc, ConflictOverwrite, changes)
+	if err != nil {
+		status.Errors = append(status.Errors, &ReconcileError{Error: errorMessage(err)})
+		return status, nil
+	}
+	changes = append(changes, dataSetChanges...)
+
+	for _, change := range changes {
+		status.Changes = append(status.Changes, change)
+		reconcileErr := &ReconcileError{File: files[change.Type+":"+change.Name], Type: change.Type, Name: change.Name}
+		switch {
+		case change.Action == ChangeConflict:
+			reconcileErr.Error = change.Reason
+		case change.Action != ChangeCreate && !change.managed && !s.adopt:
+			change.Action = ChangeConflict
+			change.Reason = "已存在非托管的同名记录"
+			reconcileErr.Error = change.Reason
+		case change.Action == ChangeUnchanged && change.managed:
+		default:
+			if err := s.apply(ctx, change); err != nil {
+				reconcileErr.Error = errorMessage(err)
+			}
+		}
+		// 数据集按定义的发布状态发布或下线
+		if reconcileErr.Error == "" && change.dataSet != nil {
+			if err := s.publish(ctx, change); err != nil {
+				reconcileErr.Error = errorMessage(err)
+			}
+		}
+		if reconcileErr.Error != "" {
+			status.Errors = append(status.Errors, reconcileErr)
+		}
+	}
+
+	if complete {
+		removed, errs := s.prune(ctx, doc)
+		status.Changes = append(status.Changes, removed...)
+		status.Errors = append(status.Errors, errs...)
+	}
+	s.bundle.dataSource.clearCache(ctx, "all")
+	s.bundle.dataSet.clearCache(ctx, "all")
+	log.Logger().Info("GitOps同步", zap.String("dir", s.dir), zap.Int("changes", len(status.Changes)), zap.Int("errors", len(status.Errors)))
+	return status, nil
+}
+
+// apply 单项在事务中保存并标记为托管
+func (s *GitOps) apply(ctx context.Context, change *BundleChange) error {
+	var (
+		model interface{} = &entity.DataSet{}
+		id    string
+	)
+	if change.dataSource != nil {
+		model, id = &entity.DataSource{}, change.dataSource.ID
+	} else {
+		id = change.dataSet.ID
+	}
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 数据源同步失败时，引用的数据集不保存
+		if change.dataSet != nil {
+			var total int64
+			if err := tx.Model(&entity.DataSource{}).Where("id = ?", change.dataSet.SourceID).Count(&total).Error; err != nil {
+				return err
+			}
+			if total == 0 {
+				return fmt.Errorf("数据集%s的数据源不存在", change.Name)
+			}
+		}
+		if err := applyChanges(tx, []*BundleChange{change}); err != nil {
+			return err
+		}
+		return tx.Model(model).Where("id = ?", id).Update("managed", true).Error
+	})
+	if err != nil {
+		return err
+	}
+	if change.dataSource != nil {
+		s.bundle.dataSource.clearCache(ctx, id)
+		if change.Action != ChangeUnchanged {
+			putAdapter(change.dataSource)
+		}
+	} else {
+		s.bundle.dataSet.clearCache(ctx, id)
+	}
+	return nil
+}
+
+// publish 按定义的发布状态发布当前版本或下线，目录的变更即视为已审核，记录审核流转
+func (s *GitOps) publish(ctx context.Context, change *BundleChange) error {
+	id := change.dataSet.ID
+	if change.publish {
+		var dataSet entity.DataSet
+		if err := s.db.WithContext(ctx).Select("version", "state", "publish_status", "published_version").Where("id = ?", id).Find(&dataSet).Error; err != nil {
+			return err
+		}
+		if publishedCurrent(&dataSet) {
+			return nil
+		}
+		// 发布前校验执行计划
+		if err := s.bundle.dataSet.checkPublish(ctx, id); err != nil {
+			return err
+		}
+	}
+
+	var review *entity.DataSetReview
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var dataSet entity.DataSet
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).Find(&dataSet).Error; err != nil {
+			return err
+		}
+		review = &entity.DataSetReview{
+			DataSetID: id,
+			Version:   dataSet.Version,
+			FromState: dataSet.State,
+			Comment:   "GitOps同步",
+		}
+		values := make(map[string]interface{})
+		switch {
+		case change.publish && !publishedCurrent(&dataSet):
+			review.Action = entity.ActionPublish
+			review.ToState = entity.StatePublished
+			values["publish_status"] = true
+			values["published_version"] = dataSet.Version
+		case !change.publish && dataSet.PublishStatus:
+			review.Action = entity.ActionRetire
+			review.ToState = entity.StateRetired
+			values["publish_status"] = false
+		default:
+			review = nil
+			return nil
+		}
+		values["state"] = review.ToState
+		if err := tx.Model(&entity.DataSet{}).Where("id = ?", id).Updates(values).Error; err != nil {
+			return err
+		}
+		review.ID = db.NewID()
+		return tx.Create(review).Error
+	})
+	if err != nil || review == nil {
+		return err
+	}
+	if change.Action == ChangeUnchanged {
+		change.Action = ChangeUpdate
+	}
+	if review.Action == entity.ActionPublish {
+		change.Reason = fmt.Sprintf("发布版本%d", review.Version)
+	} else {
+		change.Reason = "下线"
+	}
+	s.bundle.dataSet.clearCache(ctx, id)
+	return nil
+}
+
+// publishedCurrent 已发布当前版本
+func publishedCurrent(dataSet *entity.DataSet) bool {
+	return dataSet.PublishStatus && dataSet.State == entity.StatePublished && dataSet.PublishedVersion == dataSet.Version
+}
+
+// prune 删除不再定义的托管记录，仍被数据集引用的数据源不删除
+func (s *GitOps) prune(ctx context.Context, doc *BundleDoc) ([]*BundleChange, []*ReconcileError) {
+	var (
+		changes = make([]*BundleChange, 0)
+		errs    = make([]*ReconcileError, 0)
+	)
+	var dataSets []*entity.DataSet
+	if err := s.db.WithContext(ctx).Where("managed = ?", true).Find(&dataSets).Error; err != nil {
+		return changes, append(errs, &ReconcileError{Error: err.Error()})
+	}
+	for _, dataSet := range dataSets {
+		if declared(doc, "dataSet", dataSet.Name) {
+			continue
+		}
+		change := &BundleChange{Type: "dataSet", Name: dataSet.Name, Path: dataSet.Path, Action: ChangeDelete}
+		err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			return removeDataSet(tx, dataSet.ID)
+		})
+		if err != nil {
+			errs = append(errs, &ReconcileError{Type: change.Type, Name: change.Name, Error: err.Error()})
+			continue
+		}
+		s.bundle.dataSet.clearCache(ctx, dataSet.ID)
+		changes = append(changes, change)
+	}
+
+	var dataSources []*entity.DataSource
+	if err := s.db.WithContext(ctx).Where("managed = ?", true).Find(&dataSources).Error; err != nil {
+		return changes, append(errs, &ReconcileError{Error: err.Error()})
+	}
+	for _, dataSource := range dataSources {
+		if declared(doc, "dataSource", dataSource.Name) {
+			continue
+		}
+		var total int64
+		if err := s.db.WithContext(ctx).Model(&entity.DataSet{}).Where("source_id = ?", dataSource.ID).Count(&total).Error; err != nil {
+			errs = append(errs, &ReconcileError{Type: "dataSource", Name: dataSource.Name, Error: err.Error()})
+			continue
+		}
+		if total > 0 {
+			errs = append(errs, &ReconcileError{Type: "dataSource", Name: dataSource.Name, Error: "数据源仍被数据集引用，无法删除"})
+			continue
+		}
+		if err := s.db.WithContext(ctx).Delete(&entity.DataSource{}, "id = ?", dataSource.ID).Error; err != nil {
+			errs = append(errs, &ReconcileError{Type: "dataSource", Name: dataSource.Name, Error: err.Error()})
+			continue
+		}
+		s.bundle.dataSource.clearCache(ctx, dataSource.ID)
+		db.DelAdapter(dataSource.ID)
+		changes = append(changes, &BundleChange{Type: "dataSource", Name: dataSource.Name, Action: ChangeDelete})
+	}
+	return changes, errs
+}
+
+// load 读取目录下的yaml、json文件合并为一个文档，返回定义所在文件、内容摘要以及是否全部解析成功
+func (s *GitOps) load(status *GitOpsStatus) (*BundleDoc, map[string]string, string, bool) {
+	var (
+		doc      = &BundleDoc{Version: bundleVersion}
+		files    = make(map[string]string)
+		complete = true
+	)
+	names, err := s.files()
+	if err != nil {
+		status.Errors = append(status.Errors, &ReconcileError{Error: err.Error()})
+		return doc, files, "", false
+	}
+	hash := md5.New()
+	for _, name := range names {
+		b, err := ioutil.ReadFile(filepath.Join(s.dir, name))
+		if err != nil {
+			status.Errors = append(status.Errors, &ReconcileError{File: name, Error: err.Error()})
+			complete = false
+			continue
+		}
+		hash.Write([]byte(name))
+		hash.Write(b)
+		fileDoc, err := DecodeBundle(b)
+		if err != nil {
+			status.Errors = append(status.Errors, &ReconcileError{File: name, Error: err.Error()})
+			complete = false
+			continue
+		}
+		for _, e := range fileDoc.DataSources {
+			files["dataSource:"+e.Name] = name
+		}
+		for _, e := range fileDoc.DataSets {
+			files["dataSet:"+e.Name] = name
+		}
+		doc.DataSources = append(doc.DataSources, fileDoc.DataSources...)
+		doc.DataSets = append(doc.DataSets, fileDoc.DataSets...)
+	}
+	return doc, files, fmt.Sprintf("%x", hash.Sum(nil)), complete
+}
+
+// files 目录下的yaml、json文件，按名称排序
+func (s *GitOps) files() ([]string, error) {
+	infos, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(infos))
+	for _, info := range infos {
+		ext := strings.ToLower(filepath.Ext(info.Name()))
+		if info.IsDir() || !strutil.EqualAny(ext, []string{".yaml", ".yml", ".json"}) {
+			continue
+		}
+		names = append(names, info.Name())
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// changed 目录内容与最近一次同步不同
+func (s *GitOps) changed() bool {
+	_, _, digest, _ := s.load(&GitOpsStatus{})
+	return digest != s.Status().Digest
+}
+
+// checkManaged 托管的记录不允许在界面修改、删除
+func checkManaged(tx *gorm.DB, model interface{}, id string) error {
+	var managed bool
+	if err := tx.Model(model).Select("managed").Where("id = ?", id).Scan(&managed).Error; err != nil {
+		return err
+	}
+	if managed {
+		return echo.NewHTTPError(http.StatusForbidden, "由GitOps目录管理，不允许修改")
+	}
+	return nil
+}
+
+// errorMessage 错误信息，HTTP错误仅取提示信息
+func errorMessage(err error) string {
+	if he, ok := err.(*echo.HTTPError); ok {
+		return fmt.Sprintf("%v", he.Message)
+	}
+	return err.Error()
+}
+
+func declared(doc *BundleDoc, kind, name string) bool {
+	if kind == "dataSource" {
+		for _, e := range doc.DataSources {
+			if e.Name == name {
+				return true
+			}
+		}
+		return false
+	}
+	for _, e := range doc.DataSets {
+		if e.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// SyncGitOps 启动时同步目录，并在目录文件变更时重新同步
+func SyncGitOps(gitOps *GitOps) error {
+	if gitOps.dir == "" {
+		return nil
+	}
+	log.Logger().Info("GitOps同步目录", zap.String("dir", gitOps.dir))
+	if _, err := gitOps.Reconcile(context.TODO()); err != nil {
+		return err
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	if err := watcher.Add(gitOps.dir); err != nil {
+		watcher.Close()
+		return err
+	}
+	go func() {
+		defer watcher.Close()
+		// 合并短时间内的多次变更
+		var timer <-chan time.Time
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				log.Logger().Debug("GitOps目录变更", zap.String("name", event.Name), zap.String("op", event.Op.String()))
+				timer = time.After(time.Second)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Logger().Warn("GitOps目录监听错误", zap.Error(err))
+			case <-timer:
+				timer = nil
+				if !gitOps.changed() {
+					continue
+				}
+				if _, err := gitOps.Reconcile(context.TODO()); err != nil {
+					log.Logger().Warn("GitOps同步错误", zap.Error(err))
+				}
+			}
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,373 @@
+package srv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/xuanbo/ohmydata/pkg/api/util"
+	"github.com/xuanbo/ohmydata/pkg/cache"
+	"github.com/xuanbo/ohmydata/pkg/config"
+	"github.com/xuanbo/ohmydata/pkg/db"
+	orm "github.com/xuanbo/ohmydata/pkg/db/gorm"
+	"github.com/xuanbo/ohmydata/pkg/entity"
+	"github.com/xuanbo/ohmydata/pkg/log"
+	"github.com/xuanbo/ohmydata/pkg/model"
+	"github.com/xuanbo/ohmydata/pkg/model/condition"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// AuditDeny 审计动作：拒绝访问
+const AuditDeny = "deny"
+
+// Grant API授权服务，包含用户组、授权以及审计事件
+type Grant struct {
+	db     *gorm.DB
+	engine *orm.Engine
+	// 是否开启授权校验，未开启时所有已认证的调用者可调用所有API
+	enabled bool
+}
+
+// AvailableAPI 调用者可调用的API
+type AvailableAPI struct {
+	ID          string             `json:"id"`
+	Name        string             `json:"name"`
+	Description string             `json:"description"`
+	Path        string             `json:"path"`
+	Kind        entity.DataSetKind `json:"kind"`
+	Methods     []string           `json:"methods"`
+}
+
+// NewGrant 创建实例
+func NewGrant() *Grant {
+	return &Grant{
+		db:      db.DB(),
+		engine:  orm.New(db.DB()),
+		enabled: config.GetBool("grant.enabled"),
+	}
+}
+
+// CreateGroup 新增用户组
+func (s *Grant) CreateGroup(ctx context.Context, group *entity.UserGroup) error {
+	if group.Name == "" {
+		return errors.New("用户组名称不能为空")
+	}
+	group.ID = db.NewID()
+	return s.db.WithContext(ctx).Create(group).Error
+}
+
+// ModifyGroup 修改用户组
+func (s *Grant) ModifyGroup(ctx context.Context, group *entity.UserGroup) error {
+	if group.ID == "" {
+		return errors.New("更新时主键不能为空")
+	}
+	if group.Name == "" {
+		return errors.New("用户组名称不能为空")
+	}
+	return s.db.WithContext(ctx).Save(group).Error
+}
+
+// RemoveGroup 删除用户组，同时删除成员以及授权
+func (s *Grant) RemoveGroup(ctx context.Context, id string) error {
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&entity.UserGroupMember{}, "group_id = ?", id).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&entity.Grant{}, "subject_type = ? AND subject_id = ?", entity.SubjectGroup, id).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&entity.UserGroup{}, "id = ?", id).Error
+	})
+	s.clearCache(ctx)
+	return err
+}
+
+// Groups 查询所有用户组
+func (s *Grant) Groups(ctx context.Context) ([]*entity.UserGroup, error) {
+	var list []*entity.UserGroup
+	if err := s.db.WithContext(ctx).Order("created_at").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// Members 用户组成员
+func (s *Grant) Members(ctx context.Context, groupID string) ([]*entity.User, error) {
+	var list []*entity.User
+	err := s.db.WithContext(ctx).
+		Where("id IN (?)", s.db.Model(&entity.UserGroupMember{}).Select("user_id").Where("group_id = ?", groupID)).
+		Find(&list).Error
+	if err != nil {
+		return nil, err
+	}
+	// 不返回密码
+	for _, user := range list {
+		user.Password = ""
+	}
+	return list, nil
+}
+
+// SetMembers 设置用户组成员
+func (s *Grant) SetMembers(ctx context.Context, groupID string, userIDs []string) error {
+	var total int64
+	if err := s.db.WithContext(ctx).Model(&entity.UserGroup{}).Where("id = ?", groupID).Count(&total).Error; err != nil {
+		return err
+	}
+	if total == 0 {
+		return echo.NewHTTPError(http.StatusNotFound, "用户组不存在")
+	}
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&entity.UserGroupMember{}, "group_id = ?", groupID).Error; err != nil {
+			return err
+		}
+		seen := make(map[string]bool, len(userIDs))
+		for _, userID := range userIDs {
+			if userID == "" || seen[userID] {
+				continue
+			}
+			seen[userID] = true
+			member := &entity.UserGroupMember{GroupID: groupID, UserID: userID}
+			member.ID = db.NewID()
+			if err := tx.Create(member).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	s.clearCache(ctx)
+	return err
+}
+
+// Create 新增授权，数据集ID、路径前缀二选一，路径前缀为/表示所有API
+func (s *Grant) Create(ctx context.Context, grant *entity.Grant) error {
+	switch grant.SubjectType {
+	case entity.SubjectUser, entity.SubjectGroup, entity.SubjectApp:
+	default:
+		return fmt.Errorf("不支持的授权对象类型: %s", grant.SubjectType)
+	}
+	if grant.SubjectID == "" {
+		return errors.New("授权对象不能为空")
+	}
+	if (grant.DataSetID == "") == (grant.PathPrefix == "") {
+		return errors.New("数据集、路径前缀必须且只能指定一个")
+	}
+	if grant.PathPrefix != "" {
+		grant.PathPrefix = "/" + strings.Trim(grant.PathPrefix, "/")
+	}
+	grant.ID = db.NewID()
+	if err := s.db.WithContext(ctx).Create(grant).Error; err != nil {
+		return err
+	}
+	s.clearCache(ctx)
+	return nil
+}
+
+// Remove 删除授权
+func (s *Grant) Remove(ctx context.Context, id string) error {
+	if err := s.db.WithContext(ctx).Delete(&entity.Grant{}, "id = ?", id).Error; err != nil {
+		return err
+	}
+	s.clearCache(ctx)
+	return nil
+}
+
+// Page 分页查询授权
+func (s *Grant) Page(ctx context.Context, grant *entity.Grant, page *model.Pagination) error {
+	var (
+		total uint64
+		list  []*entity.Grant
+		err   error
+	)
+	combineClause := condition.NewCombineClause(condition.CombineAnd)
+	if grant.SubjectType != "" {
+		combineClause.Add(condition.Eq("subject_type", grant.SubjectType))
+	}
+	if grant.SubjectID != "" {
+		combineClause.Add(condition.Eq("subject_id", grant.SubjectID))
+	}
+	if grant.DataSetID != "" {
+		combineClause.Add(condition.Eq("data_set_id", grant.DataSetID))
+	}
+	clause := condition.WrapCombineClause(combineClause)
+
+	if total, err = s.engine.Page(
+		grant.TableName(),
+		&list,
+		selectOptionFunc.WithClause(clause),
+		selectOptionFunc.WithContext(ctx),
+		selectOptionFunc.WithPageSize(page.Page, page.Size),
+		selectOptionFunc.WithTablePrefix("`"),
+		selectOptionFunc.WithTableSuffix("`"),
+		selectOptionFunc.WithColumnPrefix("`"),
+		selectOptionFunc.WithColumnSuffix("`"),
+	); err != nil {
+		return err
+	}
+	if total < 1 {
+		return nil
+	}
+	page.Set(total, list)
+	return nil
+}
+
+// AuditPage 分页查询审计事件
+func (s *Grant) AuditPage(ctx context.Context, event *entity.AuditEvent, page *model.Pagination) error {
+	var (
+		total uint64
+		list  []*entity.AuditEvent
+		err   error
+	)
+	combineClause := condition.NewCombineClause(condition.CombineAnd)
+	if event.Action != "" {
+		combineClause.Add(condition.Eq("action", event.Action))
+	}
+	if event.Caller != "" {
+		combineClause.Add(condition.Eq("caller", event.Caller))
+	}
+	if event.DataSetID != "" {
+		combineClause.Add(condition.Eq("data_set_id", event.DataSetID))
+	}
+	clause := condition.WrapCombineClause(combineClause)
+
+	if total, err = s.engine.Page(
+		event.TableName(),
+		&list,
+		selectOptionFunc.WithClause(clause),
+		selectOptionFunc.WithContext(ctx),
+		selectOptionFunc.WithPageSize(page.Page, page.Size),
+		selectOptionFunc.WithTablePrefix("`"),
+		selectOptionFunc.WithTableSuffix("`"),
+		selectOptionFunc.WithColumnPrefix("`"),
+		selectOptionFunc.WithColumnSuffix("`"),
+	); err != nil {
+		return err
+	}
+	if total < 1 {
+		return nil
+	}
+	page.Set(total, list)
+	return nil
+}
+
+// Authorize 校验调用者是否可调用数据集，拒绝时记录审计事件并返回403
+func (s *Grant) Authorize(ctx context.Context, method, path string, dataSet *entity.DataSet) error {
+	ok, err := s.Allowed(ctx, dataSet)
+	if err != nil {
+		return err
+	}
+	if ok {
+		return nil
+	}
+	event := &entity.AuditEvent{
+		Action:    AuditDeny,
+		Caller:    caller(ctx),
+		Method:    method,
+		Path:      path,
+		DataSetID: dataSet.ID,
+		Reason:    "未授权调用该API",
+	}
+	event.ID = db.NewID()
+	if err := s.db.WithContext(ctx).Create(event).Error; err != nil {
+		log.Logger().Warn("记录审计事件错误", zap.String("caller", event.Caller), zap.String("path", path), zap.Error(err))
+	}
+	return echo.NewHTTPError(http.StatusForbidden, "无权调用该API")
+}
+
+// Allowed 调用者是否可调用数据集，未开启授权校验或内部调用（无调用者）时允许
+func (s *Grant) Allowed(ctx context.Context, dataSet *entity.DataSet) (bool, error) {
+	if !s.enabled || internal(ctx) {
+		return true, nil
+	}
+	// 无法识别调用者时拒绝
+	if caller(ctx) == "" {
+		return false, nil
+	}
+	grants, err := s.callerGrants(ctx)
+	if err != nil {
+		return false, err
+	}
+	for _, grant := range grants {
+		if grantMatch(grant, dataSet) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// callerGrants 调用者的授权，用户包含所在用户组的授权
+func (s *Grant) callerGrants(ctx context.Context) ([]*entity.Grant, error) {
+	var (
+		list []*entity.Grant
+		key  = "ohmydata:grant:caller:" + caller(ctx)
+		err  error
+	)
+	if err = cache.Get(ctx, key, &list); errors.Is(err, redis.Nil) {
+		// 查询db
+		tx := s.db.WithContext(ctx)
+		if v := ctx.Value(util.UserID); v != nil {
+			userID := fmt.Sprintf("%v", v)
+			groups := s.db.Model(&entity.UserGroupMember{}).Select("group_id").Where("user_id = ?", userID)
+			tx = tx.Where("subject_type = ? AND subject_id = ?", entity.SubjectUser, userID).
+				Or("subject_type = ? AND subject_id IN (?)", entity.SubjectGroup, groups)
+		} else {
+			tx = tx.Where("subject_type = ? AND subject_id = ?", entity.SubjectApp, fmt.Sprintf("%v", ctx.Value(util.AppID)))
+		}
+		if err = tx.Find(&list).Error; err != nil {
+			return nil, err
+		}
+		// 写入缓存
+		cache.Set(ctx, key, list, cacheTTL)
+	}
+	return list, err
+}
+
+func (s *Grant) clearCache(ctx context.Context) {
+	cache.DelMatch(ctx, "ohmydata:grant:*")
+}
+
+// grantMatch 授权是否匹配数据集，路径前缀按路径段匹配
+func grantMatch(grant *entity.Grant, dataSet *entity.DataSet) bool {
+	if grant.DataSetID != "" {
+		return grant.DataSetID == dataSet.ID
+	}
+	prefix := strings.Trim(grant.PathPrefix, "/")
+	path := strings.Trim(dataSet.Path, "/")
+	return prefix == "" || path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+// Available 调用者可调用的已发布API
+func (s *DataSet) Available(ctx context.Context) ([]*AvailableAPI, error) {
+	list, err := s.PublishedAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	apis := make([]*AvailableAPI, 0, len(list))
+	for _, dataSet := range list {
+		ok, err := s.grant.Allowed(ctx, dataSet)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		methods := []string{http.MethodGet, http.MethodPost}
+		if dataSet.Kind == entity.KindWrite {
+			methods = []string{dataSet.Method}
+		}
+		apis = append(apis, &AvailableAPI{
+			ID:          dataSet.ID,
+			Name:        dataSet.Name,
+			Description: dataSet.Description,
+			Path:        "/api/" + strings.TrimPrefix(dataSet.Path, "/"),
+			Kind:        dataSet.Kind,
+			Methods:     methods,
+		})
+	}
+	return apis, nil
+}
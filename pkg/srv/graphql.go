@@ -0,0 +1,477 @@
+package srv
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xuanbo/ohmydata/pkg/entity"
+	"github.com/xuanbo/ohmydata/pkg/log"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+// 仅返回部分响应字段时，字段名称在上下文中的key
+type responseFieldsKey struct{}
+
+// GraphQL内置以及自定义的类型名称，数据集不能使用
+var graphQLReservedTypes = []string{"Query", "String", "Int", "Float", "Boolean", "ID", "Long", "JSON"}
+
+// GraphQL名称规则
+var graphQLNameRegexp = regexp.MustCompile(`^[_A-Za-z][_0-9A-Za-z]*$`)
+
+// GraphQLRequest GraphQL请求
+type GraphQLRequest struct {
+	Query         string                 `json:"query" query:"query"`
+	OperationName string                 `json:"operationName" query:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// longScalar 长整形，GraphQL内置Int仅支持32位
+var longScalar = graphql.NewScalar(graphql.ScalarConfig{
+	Name:        "Long",
+	Description: "64位整数",
+	Serialize:   coerceLong,
+	ParseValue:  coerceLong,
+	ParseLiteral: func(value ast.Value) interface{} {
+		switch v := value.(type) {
+		case *ast.IntValue:
+			return coerceLong(v.Value)
+		case *ast.StringValue:
+			return coerceLong(v.Value)
+		}
+		return nil
+	},
+})
+
+// jsonScalar 任意JSON值，用于对象、数组以及未声明响应参数的数据集
+var jsonScalar = graphql.NewScalar(graphql.ScalarConfig{
+	Name:        "JSON",
+	Description: "任意JSON值",
+	Serialize: func(value interface{}) interface{} {
+		return value
+	},
+	ParseValue: func(value interface{}) interface{} {
+		return value
+	},
+	ParseLiteral: literalValue,
+})
+
+// GraphQL 执行GraphQL查询，每个已发布的查询数据集对应一个查询字段
+func (s *DataSet) GraphQL(ctx context.Context, req *GraphQLRequest) (*graphql.Result, error) {
+	if strings.TrimSpace(req.Query) == "" {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "query不能为空")
+	}
+	schema := s.graphQLSchema()
+	if schema == nil {
+		return nil, echo.NewHTTPError(http.StatusServiceUnavailable, "GraphQL schema尚未生成，请稍后重试")
+	}
+	return graphql.Do(graphql.Params{
+		Schema:         *schema,
+		RequestString:  req.Query,
+		VariableValues: req.Variables,
+		OperationName:  req.OperationName,
+		Context:        ctx,
+	}), nil
+}
+
+// buildSchema 根据已发布的查询数据集生成schema：请求参数为字段参数，响应参数为对象字段
+func (s *DataSet) buildSchema(list []*entity.DataSet) (*graphql.Schema, error) {
+	var (
+		fields = make(graphql.Fields, len(list))
+		// 已使用的类型名称，包含内置以及自定义标量
+		types = make(map[string]bool, len(list)*2+len(graphQLReservedTypes))
+	)
+	for _, name := range graphQLReservedTypes {
+		types[name] = true
+	}
+	for _, dataSet := range list {
+		if !dataSet.PublishStatus || dataSet.Kind != entity.KindQuery {
+			continue
+		}
+		name := graphQLName(dataSet.Path, false)
+		if _, ok := fields[name]; ok {
+			name += "_" + dataSet.ID
+		}
+		typeName := graphQLName(dataSet.Path, true)
+		if types[typeName] || types[typeName+"Page"] {
+			typeName += "_" + dataSet.ID
+		}
+		types[typeName] = true
+		types[typeName+"Page"] = true
+		fields[name] = s.graphQLField(typeName, dataSet)
+	}
+	// 逐个校验字段，忽略无法生成的字段，避免影响其他数据集
+	for name, field := range fields {
+		if _, err := graphql.NewSchema(graphql.SchemaConfig{
+			Query: graphql.NewObject(graphql.ObjectConfig{Name: "Query", Fields: graphql.Fields{name: field}}),
+		}); err != nil {
+			log.Logger().Warn("生成GraphQL字段错误，已忽略", zap.String("name", name), zap.Error(err))
+			delete(fields, name)
+		}
+	}
+	// 至少包含一个查询字段
+	if len(fields) == 0 {
+		fields["_empty"] = &graphql.Field{Type: graphql.Boolean, Description: "暂无已发布的查询数据集"}
+	}
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{Name: "Query", Fields: fields}),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &schema, nil
+}
+
+// graphQLField 数据集查询字段，分页数据集返回分页对象，单条数据返回对象，否则返回列表
+func (s *DataSet) graphQLField(typeName string, dataSet *entity.DataSet) *graphql.Field {
+	// 行对象，未声明响应参数时为JSON
+	var (
+		row  graphql.Output = jsonScalar
+		keys map[string]string
+	)
+	if len(dataSet.ResponseParams) > 0 {
+		var rowFields graphql.Fields
+		rowFields, keys = graphQLRowFields(dataSet)
+		row = graphql.NewObject(graphql.ObjectConfig{Name: typeName, Description: dataSet.Name, Fields: rowFields})
+	}
+
+	args := graphQLArgs(dataSet)
+	var output graphql.Output
+	switch {
+	case dataSet.EnablePage:
+		output = graphql.NewObject(graphql.ObjectConfig{
+			Name:        typeName + "Page",
+			Description: dataSet.Name + "分页结果",
+			Fields: graphql.Fields{
+				"page":  &graphql.Field{Type: longScalar, Description: "页码"},
+				"size":  &graphql.Field{Type: longScalar, Description: "每页条数"},
+				"total": &graphql.Field{Type: longScalar, Description: "总条数"},
+				"data":  &graphql.Field{Type: graphql.NewList(row), Description: "当前页数据"},
+			},
+		})
+		if _, ok := args["page"]; !ok {
+			args["page"] = &graphql.ArgumentConfig{Type: graphql.Int, Description: "页码，从1开始"}
+		}
+		if _, ok := args["size"]; !ok {
+			args["size"] = &graphql.ArgumentConfig{Type: graphql.Int, Description: "每页条数"}
+		}
+	case dataSet.BatchLimit == 1:
+		output = row
+	default:
+		output = graphql.NewList(row)
+	}
+
+	description := dataSet.Name
+	if dataSet.Description != "" {
+		description += "：" + dataSet.Description
+	}
+	id, path, paged := dataSet.ID, dataSet.Path, dataSet.EnablePage
+	return &graphql.Field{
+		Type:        output,
+		Args:        args,
+		Description: description,
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			ctx := p.Context
+			if keys != nil {
+				ctx = context.WithValue(ctx, responseFieldsKey{}, selectedFields(p.Info, paged, keys))
+			}
+			return logCall(ctx, "GRAPHQL", path, func(ctx context.Context) (interface{}, error) {
+				return s.resolveGraphQL(ctx, id, p.Args)
+			})
+		},
+	}
+}
+
+// resolveGraphQL 按发布版本查询数据集
+func (s *DataSet) resolveGraphQL(ctx context.Context, id string, args map[string]interface{}) (interface{}, error) {
+	dataSet, err := s.Published(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if dataSet == nil {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "API不存在或未发布，请检查API")
+	}
+	if call := currentCall(ctx); call != nil {
+		call.DataSetID = dataSet.ID
+		call.route = dataSet.Path
+	}
+	if err := s.grant.Authorize(ctx, "GRAPHQL", dataSet.Path, dataSet); err != nil {
+		return nil, err
+	}
+	if err := s.limiter.Check(ctx, dataSet); err != nil {
+		return nil, err
+	}
+	params := make(map[string]interface{}, len(args))
+	for k, v := range args {
+		params[k] = v
+	}
+	return doQuery(ctx, dataSet, params)
+}
+
+// graphQLArgs 请求参数转为字段参数，必填且无默认值的参数不能为空
+func graphQLArgs(dataSet *entity.DataSet) graphql.FieldConfigArgument {
+	args := make(graphql.FieldConfigArgument, len(dataSet.RequestParams))
+	for _, p := range dataSet.RequestParams {
+		if !graphQLNameRegexp.MatchString(p.Name) || strings.HasPrefix(p.Name, "__") {
+			log.Logger().Warn("请求参数名称不符合GraphQL规则，已忽略", zap.String("path", dataSet.Path), zap.String("name", p.Name))
+			continue
+		}
+		var input graphql.Input = graphQLType(p.ParamType)
+		if p.Required && p.DefaultValue == "" {
+			input = graphql.NewNonNull(input)
+		}
+		args[p.Name] = &graphql.ArgumentConfig{
+			Type:         input,
+			Description:  p.Description,
+			DefaultValue: defaultValue(p.ParamType, p.DefaultValue),
+		}
+	}
+	// 未声明的路径参数按字符串处理
+	for _, name := range strings.Split(dataSet.Path, "/") {
+		if !strings.HasPrefix(name, ":") {
+			continue
+		}
+		name = strings.TrimPrefix(name, ":")
+		if _, ok := args[name]; ok || !graphQLNameRegexp.MatchString(name) || strings.HasPrefix(name, "__") {
+			continue
+		}
+		args[name] = &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String), Description: "路径参数"}
+	}
+	return args
+}
+
+// graphQLRowFields 响应参数转为对象字段，返回字段以及字段名对应的响应字段名（重命名后的名称）
+func graphQLRowFields(dataSet *entity.DataSet) (graphql.Fields, map[string]string) {
+	var (
+		fields = make(graphql.Fields, len(dataSet.ResponseParams))
+		keys   = make(map[string]string, len(dataSet.ResponseParams))
+	)
+	for _, p := range dataSet.ResponseParams {
+		key := responseName(p)
+		name := graphQLName(key, false)
+		if _, ok := fields[name]; ok {
+			log.Logger().Warn("响应参数名称重复，已忽略", zap.String("path", dataSet.Path), zap.String("name", key))
+			continue
+		}
+		keys[name] = key
+		fields[name] = &graphql.Field{
+			Type:        graphQLType(p.ParamType),
+			Description: p.Description,
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				row, ok := p.Source.(map[string]interface{})
+				if !ok {
+					return nil, nil
+				}
+				// 时间与JSON响应格式保持一致
+				if t, ok := row[key].(time.Time); ok {
+					return t.Format(time.RFC3339Nano), nil
+				}
+				return row[key], nil
+			},
+		}
+	}
+	return fields, keys
+}
+
+// graphQLType 参数类型对应的GraphQL标量
+func graphQLType(paramType entity.ParamType) *graphql.Scalar {
+	switch paramType {
+	case entity.Boolean:
+		return graphql.Boolean
+	case entity.Int:
+		return graphql.Int
+	case entity.Long:
+		return longScalar
+	case entity.Float, entity.Double:
+		return graphql.Float
+	case entity.Object, entity.Array:
+		return jsonScalar
+	}
+	return graphql.String
+}
+
+// graphQLName 路径转为GraphQL名称，如user/:id转为userId，exported为true时首字母大写
+func graphQLName(path string, exported bool) string {
+	words := strings.FieldsFunc(path, func(r rune) bool {
+		return !(r == '_' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z')
+	})
+	var b strings.Builder
+	for i, word := range words {
+		if i > 0 || exported {
+			word = strings.ToUpper(word[:1]) + word[1:]
+		}
+		b.WriteString(word)
+	}
+	name := b.String()
+	// 双下划线开头的名称为内省保留
+	if strings.HasPrefix(name, "__") {
+		name = "_" + strings.TrimLeft(name, "_")
+	}
+	if name == "" || name[0] >= '0' && name[0] <= '9' {
+		name = "_" + name
+	}
+	return name
+}
+
+// selectedFields 查询选择的行对象字段，分页数据集为data下的字段，返回排序后的响应字段名
+func selectedFields(info graphql.ResolveInfo, paged bool, keys map[string]string) []string {
+	var sets []*ast.SelectionSet
+	for _, field := range info.FieldASTs {
+		sets = append(sets, field.SelectionSet)
+	}
+	if paged {
+		var data []*ast.SelectionSet
+		for _, set := range sets {
+			visitFields(info, set, func(field *ast.Field) {
+				if field.Name.Value == "data" {
+					data = append(data, field.SelectionSet)
+				}
+			})
+		}
+		sets = data
+	}
+	selected := make(map[string]bool)
+	for _, set := range sets {
+		visitFields(info, set, func(field *ast.Field) {
+			if key, ok := keys[field.Name.Value]; ok {
+				selected[key] = true
+			}
+		})
+	}
+	fields := make([]string, 0, len(selected))
+	for key := range selected {
+		fields = append(fields, key)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// visitFields 遍历选择集中的字段，展开片段
+func visitFields(info graphql.ResolveInfo, set *ast.SelectionSet, visit func(field *ast.Field)) {
+	if set == nil {
+		return
+	}
+	for _, selection := range set.Selections {
+		switch v := selection.(type) {
+		case *ast.Field:
+			visit(v)
+		case *ast.InlineFragment:
+			visitFields(info, v.SelectionSet, visit)
+		case *ast.FragmentSpread:
+			if fragment, ok := info.Fragments[v.Name.Value].(*ast.FragmentDefinition); ok {
+				visitFields(info, fragment.SelectionSet, visit)
+			}
+		}
+	}
+}
+
+// responseFields 上下文中指定的响应字段，nil表示返回全部字段
+func responseFields(ctx context.Context) []string {
+	fields, _ := ctx.Value(responseFieldsKey{}).([]string)
+	return fields
+}
+
+// selectResponseParams 按响应字段名（重命名后的名称）筛选响应参数，fields为nil时不筛选
+func selectResponseParams(params []*entity.ResponseParam, fields []string) []*entity.ResponseParam {
+	if fields == nil {
+		return params
+	}
+	selected := make([]*entity.ResponseParam, 0, len(fields))
+	for _, p := range params {
+		name := responseName(p)
+		for _, field := range fields {
+			if field == name {
+				selected = append(selected, p)
+				break
+			}
+		}
+	}
+	return selected
+}
+
+// responseName 响应字段名，重命名时为转换值
+func responseName(p *entity.ResponseParam) string {
+	if p.ConvertType == entity.ConvertRename && p.ConvertValue != "" {
+		return p.ConvertValue
+	}
+	return p.Name
+}
+
+// coerceLong 转为64位整数，无法转换时为nil
+func coerceLong(value interface{}) interface{} {
+	switch v := value.(type) {
+	case int:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case int64:
+		return v
+	case uint:
+		return int64(v)
+	case uint32:
+		return int64(v)
+	case uint64:
+		return int64(v)
+	case float32:
+		return int64(v)
+	case float64:
+		return int64(v)
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+	case string:
+		if i, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return i
+		}
+	case []byte:
+		if i, err := strconv.ParseInt(string(v), 10, 64); err == nil {
+			return i
+		}
+	}
+	return nil
+}
+
+// literalValue GraphQL字面量转为JSON值
+func literalValue(value ast.Value) interface{} {
+	switch v := value.(type) {
+	case *ast.IntValue:
+		if i, err := strconv.ParseInt(v.Value, 10, 64); err == nil {
+			return i
+		}
+		return v.Value
+	case *ast.FloatValue:
+		if f, err := strconv.ParseFloat(v.Value, 64); err == nil {
+			return f
+		}
+		return v.Value
+	case *ast.ObjectValue:
+		m := make(map[string]interface{}, len(v.Fields))
+		for _, field := range v.Fields {
+			m[field.Name.Value] = literalValue(field.Value)
+		}
+		return m
+	case *ast.ListValue:
+		list := make([]interface{}, 0, len(v.Values))
+		for _, item := range v.Values {
+			list = append(list, literalValue(item))
+		}
+		return list
+	case *ast.BooleanValue:
+		return v.Value
+	case *ast.StringValue:
+		return v.Value
+	case *ast.EnumValue:
+		return v.Value
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package srv
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/xuanbo/ohmydata/pkg/entity"
+	"github.com/xuanbo/ohmydata/pkg/log"
+
+	"github.com/graphql-go/graphql"
+)
+
+func init() {
+	// 日志
+	if err := log.Init(); err != nil {
+		panic(err)
+	}
+}
+
+func TestBuildSchemaReservedNames(t *testing.T) {
+	newDataSet := func(id, path string) *entity.DataSet {
+		dataSet := &entity.DataSet{Path: path, Kind: entity.KindQuery, PublishStatus: true}
+		dataSet.ID = id
+		dataSet.RequestParams = []*entity.RequestParam{
+			{Name: "__type", ParamType: entity.String},
+			{Name: "name", ParamType: entity.String},
+		}
+		dataSet.ResponseParams = []*entity.ResponseParam{
+			{Name: "__typename", ParamType: entity.String},
+		}
+		return dataSet
+	}
+	list := []*entity.DataSet{
+		newDataSet("1", "string"),
+		newDataSet("2", "ID"),
+		newDataSet("3", "__schema"),
+		newDataSet("4", "query"),
+		newDataSet("5", "boolean"),
+	}
+
+	schema, err := new(DataSet).buildSchema(list)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	for _, name := range []string{"String", "ID", "Boolean"} {
+		if _, ok := schema.TypeMap()[name].(*graphql.Object); ok {
+			t.Errorf("内置类型%s被数据集覆盖", name)
+		}
+	}
+	fields := schema.QueryType().Fields()
+	for _, name := range []string{"string", "ID", "_schema", "query", "boolean"} {
+		field, ok := fields[name]
+		if !ok {
+			t.Errorf("缺少字段%s", name)
+			continue
+		}
+		for _, arg := range field.Args {
+			if strings.HasPrefix(arg.Name(), "__") {
+				t.Errorf("字段%s的参数%s使用了保留名称", name, arg.Name())
+			}
+		}
+	}
+
+	result := graphql.Do(graphql.Params{
+		Schema:        *schema,
+		RequestString: `{ __schema { queryType { name } } __type(name: "String") { kind } }`,
+		Context:       context.TODO(),
+	})
+	if len(result.Errors) > 0 {
+		t.Error(result.Errors)
+		return
+	}
+	b, _ := json.Marshal(result.Data)
+	if !strings.Contains(string(b), `"kind":"SCALAR"`) {
+		t.Errorf("内省结果错误: %s", string(b))
+	}
+}
+
+func TestRoutesConcurrent(t *testing.T) {
+	s := &DataSet{router: new(Node)}
+	schema, err := s.buildSchema(nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			s.setRoutes(new(Node), schema)
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		if s.APIRoutes() == nil {
+			t.Error("路由不能为空")
+		}
+		s.graphQLSchema()
+	}
+	<-done
+}
@@ -0,0 +1,48 @@
+package srv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/xuanbo/ohmydata/pkg/entity"
+	"github.com/xuanbo/ohmydata/pkg/model"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestLimitError(t *testing.T) {
+	dataSet := &entity.DataSet{TimeoutSeconds: 5, MaxRows: 10, MaxBytes: 100}
+
+	timeout, cancel := context.WithDeadline(context.TODO(), time.Now().Add(-time.Second))
+	defer cancel()
+	canceled, cancel := context.WithCancel(context.TODO())
+	cancel()
+
+	cases := []struct {
+		ctx  context.Context
+		err  error
+		code int
+	}{
+		{timeout, context.DeadlineExceeded, http.StatusGatewayTimeout},
+		{canceled, context.Canceled, http.StatusServiceUnavailable},
+		{context.TODO(), fmt.Errorf("查询错误: %w", model.ErrMaxRows), http.StatusUnprocessableEntity},
+		{context.TODO(), fmt.Errorf("查询错误: %w", model.ErrMaxBytes), http.StatusUnprocessableEntity},
+	}
+	for _, c := range cases {
+		err := limitError(c.ctx, dataSet, c.err)
+		var he *echo.HTTPError
+		if !errors.As(err, &he) || he.Code != c.code {
+			t.Errorf("%v: expected %d, actual: %v", c.err, c.code, err)
+		}
+	}
+
+	// 其他错误原样返回
+	other := errors.New("语法错误")
+	if err := limitError(context.TODO(), dataSet, other); err != other {
+		t.Errorf("expected: %v, actual: %v", other, err)
+	}
+}
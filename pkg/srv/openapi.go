@@ -0,0 +1,460 @@
+package srv
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/xuanbo/ohmydata/pkg/entity"
+	"github.com/xuanbo/ohmydata/pkg/log"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+// OpenAPI OpenAPI 3文档
+type OpenAPI struct {
+	OpenAPI    string                                  `json:"openapi"`
+	Info       *OpenAPIInfo                            `json:"info"`
+	Paths      map[string]map[string]*OpenAPIOperation `json:"paths"`
+	Components *OpenAPIComponents                      `json:"components"`
+	Security   []map[string][]string                   `json:"security"`
+}
+
+// OpenAPIInfo 文档信息
+type OpenAPIInfo struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+// OpenAPIOperation 接口
+type OpenAPIOperation struct {
+	OperationID string                      `json:"operationId"`
+	Summary     string                      `json:"summary,omitempty"`
+	Description string                      `json:"description,omitempty"`
+	Tags        []string                    `json:"tags,omitempty"`
+	Parameters  []*OpenAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *OpenAPIRequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*OpenAPIResponse `json:"responses"`
+	Deprecated  bool                        `json:"deprecated,omitempty"`
+}
+
+// OpenAPIParameter 路径、query、header参数
+type OpenAPIParameter struct {
+	Name        string         `json:"name"`
+	In          string         `json:"in"`
+	Description string         `json:"description,omitempty"`
+	Required    bool           `json:"required,omitempty"`
+	Schema      *OpenAPISchema `json:"schema"`
+}
+
+// OpenAPIRequestBody 请求体
+type OpenAPIRequestBody struct {
+	Required bool                     `json:"required,omitempty"`
+	Content  map[string]*OpenAPIMedia `json:"content"`
+}
+
+// OpenAPIResponse 响应，Ref引用components中的响应
+type OpenAPIResponse struct {
+	Ref         string                   `json:"$ref,omitempty"`
+	Description string                   `json:"description,omitempty"`
+	Content     map[string]*OpenAPIMedia `json:"content,omitempty"`
+}
+
+// OpenAPIMedia 内容类型
+type OpenAPIMedia struct {
+	Schema *OpenAPISchema `json:"schema"`
+}
+
+// OpenAPISchema 数据结构
+type OpenAPISchema struct {
+	Ref         string                    `json:"$ref,omitempty"`
+	Type        string                    `json:"type,omitempty"`
+	Format      string                    `json:"format,omitempty"`
+	Description string                    `json:"description,omitempty"`
+	Default     interface{}               `json:"default,omitempty"`
+	Minimum     *uint                     `json:"minimum,omitempty"`
+	Maximum     *uint                     `json:"maximum,omitempty"`
+	Nullable    bool                      `json:"nullable,omitempty"`
+	Properties  map[string]*OpenAPISchema `json:"properties,omitempty"`
+	Required    []string                  `json:"required,omitempty"`
+	Items       *OpenAPISchema            `json:"items,omitempty"`
+	AllOf       []*OpenAPISchema          `json:"allOf,omitempty"`
+}
+
+// OpenAPIComponents 公共组件
+type OpenAPIComponents struct {
+	Schemas         map[string]*OpenAPISchema         `json:"schemas"`
+	Responses       map[string]*OpenAPIResponse       `json:"responses"`
+	SecuritySchemes map[string]*OpenAPISecurityScheme `json:"securitySchemes"`
+}
+
+// OpenAPISecurityScheme 认证方式
+type OpenAPISecurityScheme struct {
+	Type         string `json:"type"`
+	Description  string `json:"description,omitempty"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+	Name         string `json:"name,omitempty"`
+	In           string `json:"in,omitempty"`
+}
+
+const (
+	openAPIVersion = "3.0.3"
+	mimeJSON       = "application/json"
+	// 统一响应格式
+	schemaAPI = "API"
+	// 分页响应
+	schemaPagination = "Pagination"
+	// 写操作响应
+	schemaExecResult = "ExecResult"
+)
+
+// 错误响应，与统一异常处理一致
+var openAPIErrors = []struct {
+	code        int
+	name        string
+	description string
+}{
+	{http.StatusBadRequest, "BadRequest", "请求参数不正确"},
+	{http.StatusUnauthorized, "Unauthorized", "未认证"},
+	{http.StatusForbidden, "Forbidden", "权限不足"},
+	{http.StatusNotFound, "NotFound", "API不存在或未发布"},
+	{http.StatusMethodNotAllowed, "MethodNotAllowed", "请求方式不正确"},
+	{http.StatusConflict, "Conflict", "相同Idempotency-Key的请求正在处理中"},
+	{http.StatusUnprocessableEntity, "UnprocessableEntity", "查询结果超过限制或Idempotency-Key已用于不同的请求参数"},
+	{http.StatusInternalServerError, "InternalServerError", "服务器错误"},
+	{http.StatusServiceUnavailable, "ServiceUnavailable", "查询已被终止"},
+	{http.StatusGatewayTimeout, "GatewayTimeout", "查询超时"},
+}
+
+// OpenAPI 根据API路由生成所有已发布数据集的文档
+func (s *DataSet) OpenAPI(ctx context.Context) (*OpenAPI, error) {
+	doc := newOpenAPI()
+	var walk func(node *Node)
+	walk = func(node *Node) {
+		if handle, ok := node.Handle.(*Handle); ok {
+			ids := make([]string, 0, len(handle.Write)+1)
+			if handle.Query != "" {
+				ids = append(ids, handle.Query)
+			}
+			for _, method := range handle.Methods() {
+				if id, ok := handle.Write[method]; ok {
+					ids = append(ids, id)
+				}
+			}
+			for _, id := range ids {
+				dataSet, err := s.Published(ctx, id)
+				if err != nil {
+					log.Logger().Warn("生成OpenAPI文档错误", zap.String("id", id), zap.Error(err))
+					continue
+				}
+				if dataSet != nil {
+					doc.addDataSet(dataSet)
+				}
+			}
+		}
+		for _, child := range node.Children {
+			walk(child)
+		}
+	}
+	walk(s.APIRoutes())
+	return doc, nil
+}
+
+// DataSetOpenAPI 单个已发布数据集的文档
+func (s *DataSet) DataSetOpenAPI(ctx context.Context, id string) (*OpenAPI, error) {
+	dataSet, err := s.Published(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if dataSet == nil {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "数据集不存在")
+	}
+	if !dataSet.PublishStatus {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "数据集未发布")
+	}
+	doc := newOpenAPI()
+	doc.addDataSet(dataSet)
+	return doc, nil
+}
+
+func newOpenAPI() *OpenAPI {
+	doc := &OpenAPI{
+		OpenAPI: openAPIVersion,
+		Info: &OpenAPIInfo{
+			Title:       "ohmydata",
+			Description: "已发布数据集的API，响应均为统一格式，错误时success为false",
+			Version:     "1.0.0",
+		},
+		Paths: make(map[string]map[string]*OpenAPIOperation),
+		Components: &OpenAPIComponents{
+			Schemas: map[string]*OpenAPISchema{
+				schemaAPI: {
+					Type:        "object",
+					Description: "统一响应格式",
+					Properties: map[string]*OpenAPISchema{
+						"success": {Type: "boolean", Description: "正常为true，错误时为false"},
+						"message": {Type: "string", Description: "错误描述"},
+						"data":    {Description: "数据", Nullable: true},
+					},
+					Required: []string{"success", "message", "data"},
+				},
+				schemaPagination: {
+					Type:        "object",
+					Description: "分页数据",
+					Properties: map[string]*OpenAPISchema{
+						"page":  {Type: "integer", Format: "int64", Description: "页数"},
+						"size":  {Type: "integer", Format: "int64", Description: "每页条数"},
+						"total": {Type: "integer", Format: "int64", Description: "总条数"},
+						"data":  {Type: "array", Items: &OpenAPISchema{Type: "object"}, Description: "数据"},
+					},
+				},
+				schemaExecResult: {
+					Type:        "object",
+					Description: "写操作结果",
+					Properties: map[string]*OpenAPISchema{
+						"rowsAffected":  {Type: "integer", Format: "int64", Description: "影响行数"},
+						"generatedKeys": {Type: "array", Items: &OpenAPISchema{}, Description: "生成的主键"},
+					},
+				},
+			},
+			Responses: make(map[string]*OpenAPIResponse, len(openAPIErrors)),
+			SecuritySchemes: map[string]*OpenAPISecurityScheme{
+				"bearerAuth": {
+					Type:         "http",
+					Scheme:       "bearer",
+					BearerFormat: "JWT",
+					Description:  "登录获取的token，请求头Authorization: Bearer <token>",
+				},
+				"appKey": {
+					Type:        "apiKey",
+					In:          "header",
+					Name:        "X-App-Key",
+					Description: "应用ID",
+				},
+				"appSecret": {
+					Type:        "apiKey",
+					In:          "header",
+					Name:        "X-App-Secret",
+					Description: "应用密钥",
+				},
+				"appToken": {
+					Type:        "apiKey",
+					In:          "header",
+					Name:        "Authorization",
+					Description: "应用凭证，请求头Authorization: App <key>.<secret>",
+				},
+				"signature": {
+					Type:        "apiKey",
+					In:          "header",
+					Name:        "X-Signature",
+					Description: "应用密钥对请求方法、路径、查询参数、请求体SHA256、时间戳以及nonce的HMAC-SHA256签名",
+				},
+				"timestamp": {
+					Type:        "apiKey",
+					In:          "header",
+					Name:        "X-Timestamp",
+					Description: "签名时间戳（秒）",
+				},
+				"nonce": {
+					Type:        "apiKey",
+					In:          "header",
+					Name:        "X-Nonce",
+					Description: "签名随机数，不能重复使用",
+				},
+			},
+		},
+		// 任选一种认证方式
+		Security: []map[string][]string{
+			{"bearerAuth": {}},
+			{"appKey": {}, "appSecret": {}},
+			{"appToken": {}},
+			{"appKey": {}, "signature": {}, "timestamp": {}, "nonce": {}},
+		},
+	}
+	for _, e := range openAPIErrors {
+		doc.Components.Responses[e.name] = &OpenAPIResponse{
+			Description: e.description,
+			Content:     envelope(&OpenAPISchema{Nullable: true}),
+		}
+	}
+	return doc
+}
+
+// addDataSet 添加数据集的接口：查询数据集为POST，无请求体参数时同时支持GET；写操作数据集为指定的请求方法
+func (doc *OpenAPI) addDataSet(dataSet *entity.DataSet) {
+	path := "/api/" + openAPIPath(strings.TrimPrefix(dataSet.Path, "/"))
+	operations, ok := doc.Paths[path]
+	if !ok {
+		operations = make(map[string]*OpenAPIOperation)
+		doc.Paths[path] = operations
+	}
+
+	var (
+		parameters = make([]*OpenAPIParameter, 0, len(dataSet.RequestParams)+2)
+		body       = &OpenAPISchema{Type: "object", Properties: make(map[string]*OpenAPISchema)}
+	)
+	if dataSet.Kind == entity.KindQuery && dataSet.EnablePage {
+		parameters = append(parameters,
+			&OpenAPIParameter{Name: "page", In: "query", Description: "分页页数", Schema: &OpenAPISchema{Type: "integer", Default: 1, Minimum: uintPtr(1)}},
+			&OpenAPIParameter{Name: "size", In: "query", Description: "分页每页显示的条数", Schema: &OpenAPISchema{Type: "integer", Default: 10, Minimum: uintPtr(1), Maximum: nonZero(dataSet.MaxRows)}},
+		)
+	}
+	if dataSet.Kind == entity.KindWrite {
+		parameters = append(parameters, &OpenAPIParameter{
+			Name:        "Idempotency-Key",
+			In:          "header",
+			Description: "写操作幂等键，相同的key在24小时内返回首次执行结果",
+			Schema:      &OpenAPISchema{Type: "string"},
+		})
+	}
+	for _, p := range dataSet.RequestParams {
+		schema := paramSchema(p.ParamType)
+		schema.Default = defaultValue(p.ParamType, p.DefaultValue)
+		switch p.ParamLocation {
+		case entity.ParamPath:
+			parameters = append(parameters, &OpenAPIParameter{Name: p.Name, In: "path", Description: p.Description, Required: true, Schema: schema})
+		case entity.ParamQuery:
+			parameters = append(parameters, &OpenAPIParameter{Name: p.Name, In: "query", Description: p.Description, Required: p.Required, Schema: schema})
+		case entity.ParamBody:
+			schema.Description = p.Description
+			body.Properties[p.Name] = schema
+			if p.Required {
+				body.Required = append(body.Required, p.Name)
+			}
+		}
+	}
+
+	// 未声明的路径参数按字符串处理
+	for _, name := range strings.Split(strings.TrimPrefix(dataSet.Path, "/"), "/") {
+		if !strings.HasPrefix(name, ":") {
+			continue
+		}
+		name = strings.TrimPrefix(name, ":")
+		declared := false
+		for _, p := range parameters {
+			if p.In == "path" && p.Name == name {
+				declared = true
+			}
+		}
+		if !declared {
+			parameters = append(parameters, &OpenAPIParameter{Name: name, In: "path", Required: true, Schema: &OpenAPISchema{Type: "string"}})
+		}
+	}
+
+	operation := func(method string) *OpenAPIOperation {
+		op := &OpenAPIOperation{
+			OperationID: strings.ToLower(method) + "_" + dataSet.ID,
+			Summary:     dataSet.Name,
+			Description: dataSet.Description,
+			Tags:        []string{strings.SplitN(strings.TrimPrefix(dataSet.Path, "/"), "/", 2)[0]},
+			Parameters:  parameters,
+			Responses: map[string]*OpenAPIResponse{
+				"200": {Description: "服务器正常响应", Content: envelope(dataSchema(dataSet))},
+			},
+			Deprecated: dataSet.State == entity.StateDeprecated,
+		}
+		for _, e := range openAPIErrors {
+			op.Responses[strconv.Itoa(e.code)] = &OpenAPIResponse{Ref: "#/components/responses/" + e.name}
+		}
+		if method != http.MethodGet && len(body.Properties) > 0 {
+			op.RequestBody = &OpenAPIRequestBody{
+				Required: len(body.Required) > 0,
+				Content:  map[string]*OpenAPIMedia{mimeJSON: {Schema: body}},
+			}
+		}
+		return op
+	}
+
+	if dataSet.Kind == entity.KindWrite {
+		operations[strings.ToLower(dataSet.Method)] = operation(dataSet.Method)
+		return
+	}
+	operations["post"] = operation(http.MethodPost)
+	if len(body.Properties) == 0 {
+		operations["get"] = operation(http.MethodGet)
+	}
+}
+
+// dataSchema 响应数据：分页、单条、列表或写操作结果
+func dataSchema(dataSet *entity.DataSet) *OpenAPISchema {
+	if dataSet.Kind == entity.KindWrite {
+		return &OpenAPISchema{Ref: "#/components/schemas/" + schemaExecResult}
+	}
+	row := &OpenAPISchema{Type: "object", Properties: make(map[string]*OpenAPISchema, len(dataSet.ResponseParams))}
+	for _, p := range dataSet.ResponseParams {
+		schema := paramSchema(p.ParamType)
+		schema.Description = p.Description
+		schema.Nullable = true
+		row.Properties[responseName(p)] = schema
+	}
+	switch {
+	case dataSet.EnablePage:
+		return &OpenAPISchema{AllOf: []*OpenAPISchema{
+			{Ref: "#/components/schemas/" + schemaPagination},
+			{Type: "object", Properties: map[string]*OpenAPISchema{"data": {Type: "array", Items: row}}},
+		}}
+	case dataSet.BatchLimit == 1:
+		row.Nullable = true
+		return row
+	}
+	return &OpenAPISchema{Type: "array", Items: row}
+}
+
+// envelope 统一响应格式包装的数据
+func envelope(data *OpenAPISchema) map[string]*OpenAPIMedia {
+	return map[string]*OpenAPIMedia{
+		mimeJSON: {Schema: &OpenAPISchema{AllOf: []*OpenAPISchema{
+			{Ref: "#/components/schemas/" + schemaAPI},
+			{Type: "object", Properties: map[string]*OpenAPISchema{"data": data}},
+		}}},
+	}
+}
+
+// paramSchema 参数类型对应的数据结构
+func paramSchema(paramType entity.ParamType) *OpenAPISchema {
+	switch paramType {
+	case entity.Boolean:
+		return &OpenAPISchema{Type: "boolean"}
+	case entity.Int:
+		return &OpenAPISchema{Type: "integer", Format: "int32"}
+	case entity.Long:
+		return &OpenAPISchema{Type: "integer", Format: "int64"}
+	case entity.Float:
+		return &OpenAPISchema{Type: "number", Format: "float"}
+	case entity.Double:
+		return &OpenAPISchema{Type: "number", Format: "double"}
+	case entity.DateTime:
+		return &OpenAPISchema{Type: "string", Format: "date-time"}
+	case entity.Object:
+		return &OpenAPISchema{Type: "object"}
+	case entity.Array:
+		return &OpenAPISchema{Type: "array", Items: &OpenAPISchema{}}
+	}
+	return &OpenAPISchema{Type: "string"}
+}
+
+// openAPIPath 路径参数:name转为{name}
+func openAPIPath(path string) string {
+	names := strings.Split(path, "/")
+	for i, name := range names {
+		if strings.HasPrefix(name, ":") {
+			names[i] = "{" + strings.TrimPrefix(name, ":") + "}"
+		}
+	}
+	return strings.Join(names, "/")
+}
+
+func uintPtr(v uint) *uint {
+	return &v
+}
+
+func nonZero(v uint) *uint {
+	if v == 0 {
+		return nil
+	}
+	return &v
+}
@@ -0,0 +1,25 @@
+package srv
+
+import (
+	"testing"
+
+	"github.com/xuanbo/ohmydata/pkg/entity"
+)
+
+func TestDataSchemaRename(t *testing.T) {
+	dataSet := &entity.DataSet{Kind: entity.KindQuery, BatchLimit: 1}
+	dataSet.ResponseParams = []*entity.ResponseParam{
+		{Name: "user_name", ParamType: entity.String, ConvertType: entity.ConvertRename, ConvertValue: "userName"},
+		// 未配置新名称时保留原名
+		{Name: "age", ParamType: entity.Int, ConvertType: entity.ConvertRename},
+	}
+	row := dataSchema(dataSet)
+	for _, name := range []string{"userName", "age"} {
+		if row.Properties[name] == nil {
+			t.Errorf("missing property %s", name)
+		}
+	}
+	if len(row.Properties) != 2 {
+		t.Errorf("unexpected properties: %v", row.Properties)
+	}
+}
@@ -0,0 +1,425 @@
+package srv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/xuanbo/ohmydata/pkg/api/util"
+	"github.com/xuanbo/ohmydata/pkg/cache"
+	"github.com/xuanbo/ohmydata/pkg/db"
+	"github.com/xuanbo/ohmydata/pkg/entity"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// 限流响应头
+const (
+	headerRateLimitLimit     = "X-RateLimit-Limit"
+	headerRateLimitRemaining = "X-RateLimit-Remaining"
+	headerRateLimitReset     = "X-RateLimit-Reset"
+	headerRetryAfter         = "Retry-After"
+)
+
+// 限流以及配额：KEYS每个维度依次为令牌桶、当日计数、当月计数，ARGV[1]为当前毫秒时间戳，
+// 之后每个维度依次为每秒令牌数、桶容量、每日配额、每月配额（0不限制）以及当日、当月计数过期秒数。
+// 所有维度均通过时才扣减令牌以及增加计数，返回被拒绝的维度序号（从1开始，0表示允许）、
+// 原因（1请求过于频繁、2超过每日配额、3超过每月配额）、需等待的毫秒数，之后每个维度依次为剩余令牌数、当日、当月计数
+var rateLimitScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local n = #KEYS / 3
+local result = {0, 0, 0}
+local states = {}
+for i = 1, n do
+	local k, a = (i - 1) * 3, 1 + (i - 1) * 6
+	local rate, burst = tonumber(ARGV[a + 1]), tonumber(ARGV[a + 2])
+	local dailyQuota, monthlyQuota = tonumber(ARGV[a + 3]), tonumber(ARGV[a + 4])
+	local tokens, ts = 0, now
+	if rate > 0 then
+		local bucket = redis.call('HMGET', KEYS[k + 1], 'tokens', 'ts')
+		tokens = tonumber(bucket[1]) or burst
+		ts = tonumber(bucket[2]) or now
+		if now > ts then
+			tokens = math.min(burst, tokens + (now - ts) * rate / 1000)
+		end
+	end
+	local daily = tonumber(redis.call('GET', KEYS[k + 2]) or '0')
+	local monthly = tonumber(redis.call('GET', KEYS[k + 3]) or '0')
+	states[i] = {tokens, ts, daily, monthly}
+	if result[1] == 0 then
+		if rate > 0 and tokens < 1 then
+			result = {i, 1, math.ceil((1 - tokens) * 1000 / rate)}
+		elseif dailyQuota > 0 and daily >= dailyQuota then
+			result = {i, 2, 0}
+		elseif monthlyQuota > 0 and monthly >= monthlyQuota then
+			result = {i, 3, 0}
+		end
+	end
+end
+for i = 1, n do
+	local k, a = (i - 1) * 3, 1 + (i - 1) * 6
+	local rate, burst = tonumber(ARGV[a + 1]), tonumber(ARGV[a + 2])
+	local dailyQuota, monthlyQuota = tonumber(ARGV[a + 3]), tonumber(ARGV[a + 4])
+	local state = states[i]
+	if result[1] == 0 then
+		if rate > 0 then
+			state[1] = state[1] - 1
+			redis.call('HSET', KEYS[k + 1], 'tokens', state[1], 'ts', math.max(now, state[2]))
+			redis.call('PEXPIRE', KEYS[k + 1], math.ceil(burst * 1000 / rate) + 1000)
+		end
+		if dailyQuota > 0 or monthlyQuota > 0 then
+			state[3] = redis.call('INCR', KEYS[k + 2])
+			if state[3] == 1 then
+				redis.call('EXPIRE', KEYS[k + 2], ARGV[a + 5])
+			end
+			state[4] = redis.call('INCR', KEYS[k + 3])
+			if state[4] == 1 then
+				redis.call('EXPIRE', KEYS[k + 3], ARGV[a + 6])
+			end
+		end
+	end
+	table.insert(result, math.floor(state[1]))
+	table.insert(result, state[3])
+	table.insert(result, state[4])
+end
+return result
+`)
+
+// RateLimiter 限流以及配额服务，计数保存在redis，多实例共享
+type RateLimiter struct {
+	db *gorm.DB
+}
+
+// RateLimitUsage 当前用量
+type RateLimitUsage struct {
+	Scope  entity.RateLimitScope `json:"scope"`
+	Target string                `json:"target"`
+	// 生效的配置，未配置时为空
+	Limit *entity.RateLimit `json:"limit"`
+	// 当日、当月调用次数
+	Daily   int64 `json:"daily"`
+	Monthly int64 `json:"monthly"`
+	// 配额重置时间
+	DailyResetAt   entity.Time `json:"dailyResetAt"`
+	MonthlyResetAt entity.Time `json:"monthlyResetAt"`
+}
+
+// rateLimitStatus 限流状态，多个维度时取剩余最少的设置响应头
+type rateLimitStatus struct {
+	limit     int64
+	remaining int64
+	reset     time.Duration
+}
+
+// NewRateLimiter 创建实例
+func NewRateLimiter() *RateLimiter {
+	return &RateLimiter{db: db.DB()}
+}
+
+// Create 新增
+func (s *RateLimiter) Create(ctx context.Context, limit *entity.RateLimit) error {
+	if err := validRateLimit(limit); err != nil {
+		return err
+	}
+	var total int64
+	err := s.db.WithContext(ctx).Model(&entity.RateLimit{}).
+		Where("scope = ? AND target = ?", limit.Scope, limit.Target).Count(&total).Error
+	if err != nil {
+		return err
+	}
+	if total > 0 {
+		return echo.NewHTTPError(http.StatusConflict, "该限流配置已存在")
+	}
+	limit.ID = db.NewID()
+	if err := s.db.WithContext(ctx).Create(limit).Error; err != nil {
+		return err
+	}
+	s.clearCache(ctx)
+	return nil
+}
+
+// Modify 修改
+func (s *RateLimiter) Modify(ctx context.Context, limit *entity.RateLimit) error {
+	if limit.ID == "" {
+		return errors.New("更新时主键不能为空")
+	}
+	if err := validRateLimit(limit); err != nil {
+		return err
+	}
+	if err := s.db.WithContext(ctx).Save(limit).Error; err != nil {
+		return err
+	}
+	s.clearCache(ctx)
+	return nil
+}
+
+// Remove 删除
+func (s *RateLimiter) Remove(ctx context.Context, id string) error {
+	if err := s.db.WithContext(ctx).Delete(&entity.RateLimit{}, "id = ?", id).Error; err != nil {
+		return err
+	}
+	s.clearCache(ctx)
+	return nil
+}
+
+// All 查询所有
+func (s *RateLimiter) All(ctx context.Context) ([]*entity.RateLimit, error) {
+	var (
+		list []*entity.RateLimit
+		key  = "ohmydata:ratelimit:all"
+		err  error
+	)
+	if err = cache.Get(ctx, key, &list); errors.Is(err, redis.Nil) {
+		// 查询db
+		if err = s.db.WithContext(ctx).Order("scope, target").Find(&list).Error; err != nil {
+			return nil, err
+		}
+		// 写入缓存
+		cache.Set(ctx, key, list, cacheTTL)
+	}
+	return list, err
+}
+
+// Check 按数据集、应用、IP校验限流以及配额，所有维度均通过时才计数，超过时返回429，并设置X-RateLimit-*、Retry-After响应头
+func (s *RateLimiter) Check(ctx context.Context, dataSet *entity.DataSet) error {
+	limits, err := s.All(ctx)
+	if err != nil {
+		return err
+	}
+	if len(limits) == 0 {
+		return nil
+	}
+	var matched []matchedRateLimit
+	for _, target := range rateLimitTargets(ctx, dataSet) {
+		if limit := matchRateLimit(limits, target.scope, target.id); limit != nil {
+			matched = append(matched, matchedRateLimit{limit, target.id})
+		}
+	}
+	if len(matched) == 0 {
+		return nil
+	}
+	header, _ := ctx.Value(util.ResponseHeader).(http.Header)
+	status, err := s.check(ctx, matched)
+	setRateLimitHeader(header, status)
+	return err
+}
+
+// Usage 查询当前用量
+func (s *RateLimiter) Usage(ctx context.Context, scope entity.RateLimitScope, target string) (*RateLimitUsage, error) {
+	if scope == "" || target == "" {
+		return nil, errors.New("限流维度以及目标不能为空")
+	}
+	limits, err := s.All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		now            = time.Now()
+		daily, monthly = quotaKeys(scope, target, now)
+		usage          = &RateLimitUsage{
+			Scope:          scope,
+			Target:         target,
+			Limit:          matchRateLimit(limits, scope, target),
+			DailyResetAt:   entity.Time{Time: nextDay(now)},
+			MonthlyResetAt: entity.Time{Time: nextMonth(now)},
+		}
+	)
+	if err := cache.Get(ctx, daily, &usage.Daily); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+	if err := cache.Get(ctx, monthly, &usage.Monthly); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+	return usage, nil
+}
+
+// check 在一个脚本中校验所有维度的限流以及配额，返回剩余最少的维度状态，被拒绝时返回拒绝的维度状态
+func (s *RateLimiter) check(ctx context.Context, matched []matchedRateLimit) (*rateLimitStatus, error) {
+	var (
+		now  = time.Now()
+		keys = make([]string, 0, len(matched)*3)
+		args = make([]interface{}, 0, len(matched)*6+1)
+	)
+	args = append(args, now.UnixNano()/int64(time.Millisecond))
+	for _, e := range matched {
+		daily, monthly := quotaKeys(e.limit.Scope, e.target, now)
+		keys = append(keys, "ohmydata:ratelimit:bucket:"+string(e.limit.Scope)+":"+e.target, daily, monthly)
+		args = append(args, e.limit.Rate, burst(e.limit), e.limit.DailyQuota, e.limit.MonthlyQuota,
+			int64(48*time.Hour/time.Second), int64(32*24*time.Hour/time.Second))
+	}
+	v, err := cache.Run(ctx, rateLimitScript, keys, args...)
+	if err != nil {
+		return nil, err
+	}
+	result := scriptResult(v)
+	if len(result) < 3+len(matched)*3 {
+		return nil, fmt.Errorf("限流脚本返回错误: %v", v)
+	}
+
+	var status *rateLimitStatus
+	for i, e := range matched {
+		var (
+			limit                   = e.limit
+			state                   = result[3+i*3 : 6+i*3]
+			tokens, daily, monthly  = state[0], state[1], state[2]
+			rejected                = result[0] == int64(i+1)
+			bucket, quota, selected *rateLimitStatus
+		)
+		// 每秒请求数
+		if limit.Rate > 0 {
+			b := int64(burst(limit))
+			bucket = &rateLimitStatus{
+				limit:     b,
+				remaining: tokens,
+				reset:     time.Duration(float64(b-tokens)/limit.Rate*1000) * time.Millisecond,
+			}
+			if rejected && result[1] == 1 {
+				bucket.reset = time.Duration(result[2]) * time.Millisecond
+				return bucket, echo.NewHTTPError(http.StatusTooManyRequests, fmt.Sprintf("请求过于频繁，请稍后重试[%s:%s]", limit.Scope, e.target))
+			}
+		}
+		// 每日、每月配额
+		if limit.DailyQuota > 0 {
+			quota = &rateLimitStatus{limit: int64(limit.DailyQuota), remaining: int64(limit.DailyQuota) - daily, reset: nextDay(now).Sub(now)}
+		}
+		if limit.MonthlyQuota > 0 && (quota == nil || int64(limit.MonthlyQuota)-monthly < quota.remaining || rejected && result[1] == 3) {
+			quota = &rateLimitStatus{limit: int64(limit.MonthlyQuota), remaining: int64(limit.MonthlyQuota) - monthly, reset: nextMonth(now).Sub(now)}
+		}
+		if quota != nil && quota.remaining < 0 {
+			quota.remaining = 0
+		}
+		if rejected {
+			switch result[1] {
+			case 2:
+				return quota, echo.NewHTTPError(http.StatusTooManyRequests, fmt.Sprintf("已超过每日调用配额[%s:%s]", limit.Scope, e.target))
+			case 3:
+				return quota, echo.NewHTTPError(http.StatusTooManyRequests, fmt.Sprintf("已超过每月调用配额[%s:%s]", limit.Scope, e.target))
+			}
+		}
+		selected = bucket
+		if quota != nil && (selected == nil || quota.remaining < selected.remaining) {
+			selected = quota
+		}
+		if selected != nil && (status == nil || selected.remaining < status.remaining) {
+			status = selected
+		}
+	}
+	return status, nil
+}
+
+func (s *RateLimiter) clearCache(ctx context.Context) {
+	cache.Del(ctx, "ohmydata:ratelimit:all")
+}
+
+// rateLimitTarget 限流目标
+type rateLimitTarget struct {
+	scope entity.RateLimitScope
+	id    string
+}
+
+// matchedRateLimit 限流目标生效的配置
+type matchedRateLimit struct {
+	limit  *entity.RateLimit
+	target string
+}
+
+// rateLimitTargets 当前调用的限流目标：数据集、应用（使用应用凭证时）、客户端IP
+func rateLimitTargets(ctx context.Context, dataSet *entity.DataSet) []rateLimitTarget {
+	targets := []rateLimitTarget{{entity.ScopeDataSet, dataSet.ID}}
+	if v := ctx.Value(util.AppID); v != nil {
+		targets = append(targets, rateLimitTarget{entity.ScopeApp, fmt.Sprintf("%v", v)})
+	}
+	if v, ok := ctx.Value(util.ClientIP).(string); ok && v != "" {
+		targets = append(targets, rateLimitTarget{entity.ScopeIP, v})
+	}
+	return targets
+}
+
+// matchRateLimit 目标的限流配置，未单独配置时使用*配置
+func matchRateLimit(limits []*entity.RateLimit, scope entity.RateLimitScope, target string) *entity.RateLimit {
+	var fallback *entity.RateLimit
+	for _, limit := range limits {
+		if limit.Scope != scope {
+			continue
+		}
+		if limit.Target == target {
+			return limit
+		}
+		if limit.Target == "*" {
+			fallback = limit
+		}
+	}
+	return fallback
+}
+
+// setRateLimitHeader 设置限流响应头，被拒绝时设置Retry-After
+func setRateLimitHeader(header http.Header, status *rateLimitStatus) {
+	if header == nil || status == nil {
+		return
+	}
+	reset := int64(math.Ceil(status.reset.Seconds()))
+	header.Set(headerRateLimitLimit, strconv.FormatInt(status.limit, 10))
+	header.Set(headerRateLimitRemaining, strconv.FormatInt(status.remaining, 10))
+	header.Set(headerRateLimitReset, strconv.FormatInt(reset, 10))
+	if status.remaining <= 0 {
+		if reset < 1 {
+			reset = 1
+		}
+		header.Set(headerRetryAfter, strconv.FormatInt(reset, 10))
+	}
+}
+
+func validRateLimit(limit *entity.RateLimit) error {
+	switch limit.Scope {
+	case entity.ScopeDataSet, entity.ScopeApp, entity.ScopeIP:
+	default:
+		return fmt.Errorf("不支持的限流维度: %s", limit.Scope)
+	}
+	if limit.Target == "" {
+		return errors.New("限流目标不能为空，默认配置使用*")
+	}
+	if limit.Rate < 0 {
+		return errors.New("每秒请求数不能小于0")
+	}
+	return nil
+}
+
+// quotaKeys 当日、当月的配额计数key
+func quotaKeys(scope entity.RateLimitScope, target string, now time.Time) (string, string) {
+	prefix := "ohmydata:ratelimit:quota:" + string(scope) + ":" + target
+	return prefix + ":d:" + now.Format("20060102"), prefix + ":m:" + now.Format("200601")
+}
+
+func nextDay(now time.Time) time.Time {
+	y, m, d := now.Date()
+	return time.Date(y, m, d+1, 0, 0, 0, 0, now.Location())
+}
+
+func nextMonth(now time.Time) time.Time {
+	y, m, _ := now.Date()
+	return time.Date(y, m+1, 1, 0, 0, 0, 0, now.Location())
+}
+
+// burst 令牌桶容量，未配置时为每秒请求数
+func burst(limit *entity.RateLimit) uint {
+	if limit.Burst == 0 {
+		return uint(math.Ceil(limit.Rate))
+	}
+	return limit.Burst
+}
+
+// scriptResult 解析脚本返回的整数数组
+func scriptResult(v interface{}) []int64 {
+	list, _ := v.([]interface{})
+	result := make([]int64, len(list))
+	for i, e := range list {
+		result[i], _ = e.(int64)
+	}
+	return result
+}
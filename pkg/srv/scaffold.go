@@ -0,0 +1,299 @@
+package srv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/xuanbo/ohmydata/pkg/db"
+	"github.com/xuanbo/ohmydata/pkg/entity"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// identRegexp 可作为模板字段访问的参数名
+var identRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Scaffold 根据表生成数据集
+type Scaffold struct {
+	SourceID string `json:"sourceId"`
+	Table    string `json:"table"`
+	// 请求路径，默认为表名
+	Path string `json:"path"`
+	// 是否生成新增、修改、删除写操作数据集，数据源需开启写操作
+	Write bool `json:"write"`
+}
+
+// Scaffold 根据表结构生成数据集：分页列表、主键查询，可选新增、修改、删除，在同一事务中保存
+func (s *DataSet) Scaffold(ctx context.Context, scaffold *Scaffold) ([]*entity.DataSet, error) {
+	if scaffold.SourceID == "" || scaffold.Table == "" {
+		return nil, errors.New("数据源以及表名不能为空")
+	}
+	var dataSource entity.DataSource
+	if err := s.db.WithContext(ctx).Where("id = ?", scaffold.SourceID).Find(&dataSource).Error; err != nil {
+		return nil, err
+	}
+	if dataSource.ID == "" {
+		return nil, errors.New("数据源不存在")
+	}
+	adapter, err := db.GetAdapter(scaffold.SourceID)
+	if err != nil {
+		return nil, err
+	}
+	table, err := adapter.Table(ctx, scaffold.Table)
+	if err != nil {
+		return nil, err
+	}
+	var keys []*db.Column
+	for _, column := range table.Columns {
+		if column.PrimaryKey {
+			keys = append(keys, column)
+		}
+	}
+	if len(keys) == 0 {
+		return nil, echo.NewHTTPError(http.StatusUnprocessableEntity, fmt.Sprintf("表%s没有主键，无法生成数据集", scaffold.Table))
+	}
+	if len(keys) > 1 {
+		return nil, echo.NewHTTPError(http.StatusUnprocessableEntity, fmt.Sprintf("表%s为联合主键，无法生成按:id查询的数据集", scaffold.Table))
+	}
+
+	g := &scaffoldGenerator{
+		table: table,
+		key:   keys[0],
+		quote: identQuote(dataSource.Type),
+		path:  strings.Trim(scaffold.Path, "/"),
+	}
+	if g.path == "" {
+		g.path = table.Name
+	}
+	list := []*entity.DataSet{g.list(), g.get()}
+	if scaffold.Write {
+		list = append(list, g.create(), g.update(), g.remove())
+	}
+
+	for _, dataSet := range list {
+		dataSet.ID = db.NewID()
+		dataSet.SourceID = scaffold.SourceID
+		if err := s.validDataSet(ctx, dataSet); err != nil {
+			return nil, fmt.Errorf("%s: %w", dataSet.Name, err)
+		}
+	}
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, dataSet := range list {
+			if err := createDataSet(tx, dataSet); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	// 清除缓存
+	s.clearCache(ctx, "all")
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// scaffoldGenerator 数据集生成
+type scaffoldGenerator struct {
+	table *db.Table
+	key   *db.Column
+	// 标识符引号
+	quote string
+	path  string
+}
+
+// list 分页列表，每个字段均可作为可选的相等过滤条件
+func (g *scaffoldGenerator) list() *entity.DataSet {
+	var (
+		exp           strings.Builder
+		requestParams = make([]*entity.RequestParam, 0, len(g.table.Columns))
+	)
+	exp.WriteString(fmt.Sprintf("SELECT %s FROM %s WHERE 1 = 1", g.columns(g.table.Columns), g.tableIdent()))
+	for _, column := range g.table.Columns {
+		exp.WriteString(fmt.Sprintf("\n{{if %s}} AND %s = {{bind %s}}{{end}}", paramRef(column.Name), g.ident(column.Name), paramRef(column.Name)))
+		requestParams = append(requestParams, &entity.RequestParam{
+			Name:          column.Name,
+			ParamLocation: entity.ParamQuery,
+			ParamType:     column.ParamType(),
+		})
+	}
+	return &entity.DataSet{
+		Name:           g.table.Name + "列表",
+		Description:    "分页查询" + g.table.Name,
+		Path:           g.path,
+		Kind:           entity.KindQuery,
+		Expression:     exp.String(),
+		EnablePage:     true,
+		RequestParams:  requestParams,
+		ResponseParams: g.responseParams(),
+	}
+}
+
+// get 主键查询
+func (g *scaffoldGenerator) get() *entity.DataSet {
+	return &entity.DataSet{
+		Name:           g.table.Name + "详情",
+		Description:    "主键查询" + g.table.Name,
+		Path:           g.path + "/:id",
+		Kind:           entity.KindQuery,
+		Expression:     fmt.Sprintf("SELECT %s FROM %s WHERE %s", g.columns(g.table.Columns), g.tableIdent(), g.where()),
+		BatchLimit:     1,
+		RequestParams:  []*entity.RequestParam{g.idParam()},
+		ResponseParams: g.responseParams(),
+	}
+}
+
+// create 新增，数据库生成的主键不传，其他主键为必填参数
+func (g *scaffoldGenerator) create() *entity.DataSet {
+	var (
+		columns       = g.inserts()
+		binds         = make([]string, len(columns))
+		requestParams = make([]*entity.RequestParam, len(columns))
+	)
+	for i, column := range columns {
+		binds[i] = fmt.Sprintf("{{bind %s}}", paramRef(column.Name))
+		requestParams[i] = g.bodyParam(column)
+	}
+	return &entity.DataSet{
+		Name:          g.table.Name + "新增",
+		Description:   "新增" + g.table.Name,
+		Path:          g.path,
+		Kind:          entity.KindWrite,
+		Method:        http.MethodPost,
+		Expression:    fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", g.tableIdent(), g.columns(columns), strings.Join(binds, ", ")),
+		RequestParams: requestParams,
+	}
+}
+
+// update 主键修改，覆盖除主键外的所有字段
+func (g *scaffoldGenerator) update() *entity.DataSet {
+	var (
+		columns       = g.values()
+		sets          = make([]string, len(columns))
+		requestParams = []*entity.RequestParam{g.idParam()}
+	)
+	for i, column := range columns {
+		sets[i] = fmt.Sprintf("%s = {{bind %s}}", g.ident(column.Name), paramRef(column.Name))
+		requestParams = append(requestParams, g.bodyParam(column))
+	}
+	return &entity.DataSet{
+		Name:          g.table.Name + "修改",
+		Description:   "主键修改" + g.table.Name,
+		Path:          g.path + "/:id",
+		Kind:          entity.KindWrite,
+		Method:        http.MethodPut,
+		Expression:    fmt.Sprintf("UPDATE %s SET %s WHERE %s", g.tableIdent(), strings.Join(sets, ", "), g.where()),
+		RequestParams: requestParams,
+	}
+}
+
+// remove 主键删除
+func (g *scaffoldGenerator) remove() *entity.DataSet {
+	return &entity.DataSet{
+		Name:          g.table.Name + "删除",
+		Description:   "主键删除" + g.table.Name,
+		Path:          g.path + "/:id",
+		Kind:          entity.KindWrite,
+		Method:        http.MethodDelete,
+		Expression:    fmt.Sprintf("DELETE FROM %s WHERE %s", g.tableIdent(), g.where()),
+		RequestParams: []*entity.RequestParam{g.idParam()},
+	}
+}
+
+func (g *scaffoldGenerator) where() string {
+	return fmt.Sprintf("%s = {{bind .id}}", g.ident(g.key.Name))
+}
+
+func (g *scaffoldGenerator) idParam() *entity.RequestParam {
+	return &entity.RequestParam{
+		Name:          "id",
+		Description:   "主键" + g.key.Name,
+		ParamLocation: entity.ParamPath,
+		ParamType:     g.key.ParamType(),
+		Required:      true,
+	}
+}
+
+func (g *scaffoldGenerator) bodyParam(column *db.Column) *entity.RequestParam {
+	return &entity.RequestParam{
+		Name:          column.Name,
+		ParamLocation: entity.ParamBody,
+		ParamType:     column.ParamType(),
+		Required:      !column.Nullable,
+	}
+}
+
+func (g *scaffoldGenerator) responseParams() []*entity.ResponseParam {
+	responseParams := make([]*entity.ResponseParam, len(g.table.Columns))
+	for i, column := range g.table.Columns {
+		responseParams[i] = &entity.ResponseParam{
+			Name:      column.Name,
+			ParamType: column.ParamType(),
+		}
+	}
+	return responseParams
+}
+
+// inserts 新增的字段，除数据库生成的主键外的所有字段
+func (g *scaffoldGenerator) inserts() []*db.Column {
+	columns := make([]*db.Column, 0, len(g.table.Columns))
+	for _, column := range g.table.Columns {
+		if !column.PrimaryKey || !column.Generated {
+			columns = append(columns, column)
+		}
+	}
+	return columns
+}
+
+// values 除主键外的字段
+func (g *scaffoldGenerator) values() []*db.Column {
+	columns := make([]*db.Column, 0, len(g.table.Columns))
+	for _, column := range g.table.Columns {
+		if !column.PrimaryKey {
+			columns = append(columns, column)
+		}
+	}
+	return columns
+}
+
+func (g *scaffoldGenerator) columns(columns []*db.Column) string {
+	names := make([]string, len(columns))
+	for i, column := range columns {
+		names[i] = g.ident(column.Name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// tableIdent 表名，带库名、schema前缀时分别加引号
+func (g *scaffoldGenerator) tableIdent() string {
+	parts := strings.Split(g.table.Name, ".")
+	for i, part := range parts {
+		parts[i] = g.ident(part)
+	}
+	return strings.Join(parts, ".")
+}
+
+func (g *scaffoldGenerator) ident(name string) string {
+	return g.quote + name + g.quote
+}
+
+// identQuote 数据源的标识符引号
+func identQuote(adapterType string) string {
+	if adapterType == "mysql" {
+		return "`"
+	}
+	return `"`
+}
+
+// paramRef 模板中引用参数，参数名不是合法标识符时使用index
+func paramRef(name string) string {
+	if identRegexp.MatchString(name) {
+		return "." + name
+	}
+	return fmt.Sprintf("(index . %q)", name)
+}
@@ -0,0 +1,131 @@
+package srv
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xuanbo/ohmydata/pkg/cache"
+	"github.com/xuanbo/ohmydata/pkg/entity"
+
+	"github.com/labstack/echo/v4"
+)
+
+// 签名请求默认允许的时间偏差
+const defaultSignSkew = 5 * time.Minute
+
+// SignedRequest 签名请求，使用应用密钥对请求进行HMAC-SHA256签名
+type SignedRequest struct {
+	Key       string
+	Timestamp string
+	Nonce     string
+	Signature string
+	Method    string
+	Path      string
+	// 查询参数
+	Params url.Values
+	// 请求体
+	Body []byte
+}
+
+// StringToSign 待签名字符串，各部分以换行分隔：
+// 请求方法（大写）、请求路径、按key排序并URL编码的查询参数、请求体SHA256（十六进制）、时间戳（秒）、nonce
+func (r *SignedRequest) StringToSign() string {
+	body := sha256.Sum256(r.Body)
+	return strings.Join([]string{
+		strings.ToUpper(r.Method),
+		r.Path,
+		canonicalParams(r.Params),
+		hex.EncodeToString(body[:]),
+		r.Timestamp,
+		r.Nonce,
+	}, "\n")
+}
+
+// Sign 使用密钥计算签名，十六进制的HMAC-SHA256
+func Sign(secret, stringToSign string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(stringToSign))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify 校验签名请求：时间戳在允许偏差内、签名正确且nonce未使用过，未通过时返回401
+func (s *App) Verify(ctx context.Context, req *SignedRequest) (*entity.App, error) {
+	if req.Key == "" || req.Timestamp == "" || req.Nonce == "" || req.Signature == "" {
+		return nil, echo.NewHTTPError(http.StatusUnauthorized, "签名请求的key、timestamp、nonce、signature不能为空")
+	}
+	if len(req.Nonce) > 64 {
+		return nil, echo.NewHTTPError(http.StatusUnauthorized, "nonce长度不能超过64")
+	}
+	skew := s.signSkew
+	if skew <= 0 {
+		skew = defaultSignSkew
+	}
+	timestamp, err := strconv.ParseInt(req.Timestamp, 10, 64)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusUnauthorized, "timestamp必须是秒级时间戳")
+	}
+	if d := time.Since(time.Unix(timestamp, 0)); d > skew || d < -skew {
+		return nil, echo.NewHTTPError(http.StatusUnauthorized, "请求时间戳已过期")
+	}
+
+	cached, err := s.load(ctx, req.Key)
+	if err != nil {
+		return nil, err
+	}
+	if cached == nil {
+		return nil, echo.NewHTTPError(http.StatusUnauthorized, "应用凭证无效")
+	}
+	if err := checkApp(cached.App); err != nil {
+		return nil, err
+	}
+	// 宽限期内的旧密钥同样可以签名
+	var (
+		stringToSign = req.StringToSign()
+		matched      bool
+	)
+	for _, secret := range cached.secrets() {
+		if hmac.Equal([]byte(Sign(secret, stringToSign)), []byte(strings.ToLower(req.Signature))) {
+			matched = true
+			break
+		}
+	}
+	if !matched {
+		return nil, echo.NewHTTPError(http.StatusUnauthorized, "签名错误")
+	}
+
+	// 签名通过后再记录nonce，避免伪造的请求占用nonce
+	ok, err := cache.SetNX(ctx, "ohmydata:nonce:"+req.Key+":"+req.Nonce, req.Timestamp, 2*skew)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, echo.NewHTTPError(http.StatusUnauthorized, "重复的请求")
+	}
+	return cached.App, nil
+}
+
+// canonicalParams 按key排序，同名参数按值排序，key、value进行URL编码后以&连接
+func canonicalParams(params url.Values) string {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var pairs []string
+	for _, k := range keys {
+		values := append([]string(nil), params[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			pairs = append(pairs, url.QueryEscape(k)+"="+url.QueryEscape(v))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
@@ -0,0 +1,53 @@
+package srv
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestSign(t *testing.T) {
+	cases := []struct {
+		name         string
+		req          *SignedRequest
+		stringToSign string
+		signature    string
+	}{
+		{
+			// 按key排序、同名参数按值排序、URL编码，空请求体的SHA256
+			name: "query",
+			req: &SignedRequest{
+				Method:    "get",
+				Path:      "/api/user/1",
+				Params:    url.Values{"c": {"中"}, "b": {"2", "1"}, "a b": {"x&y"}},
+				Timestamp: "1610000000",
+				Nonce:     "abc",
+			},
+			stringToSign: "GET\n/api/user/1\na+b=x%26y&b=1&b=2&c=%E4%B8%AD\n" +
+				"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855\n1610000000\nabc",
+			signature: "0bdc6fbcb9eea14d044e860d544914a8d82130c92cf76a9baa3c3a0b0db0f2cb",
+		},
+		{
+			name: "body",
+			req: &SignedRequest{
+				Method:    "POST",
+				Path:      "/api/user",
+				Body:      []byte(`{"name":"test"}`),
+				Timestamp: "1610000000",
+				Nonce:     "abc",
+			},
+			stringToSign: "POST\n/api/user\n\n" +
+				"7d9fd2051fc32b32feab10946fab6bb91426ab7e39aa5439289ed892864aa91d\n1610000000\nabc",
+			signature: "84ed38331b90bc4d417bd385553b1df60562762f9a75bf58bd0b9e4cdd9e7fc6",
+		},
+	}
+	for _, c := range cases {
+		stringToSign := c.req.StringToSign()
+		if stringToSign != c.stringToSign {
+			t.Errorf("%s: expected %q, actual: %q", c.name, c.stringToSign, stringToSign)
+			continue
+		}
+		if signature := Sign("secret", stringToSign); signature != c.signature {
+			t.Errorf("%s: expected %s, actual: %s", c.name, c.signature, signature)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package srv_test
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/xuanbo/ohmydata/pkg/entity"
+	"github.com/xuanbo/ohmydata/pkg/srv"
+)
+
+func TestApp(t *testing.T) {
+	app := srv.NewApp()
+	credential, err := app.Create(context.TODO(), &entity.App{Name: "test"})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer app.Remove(context.TODO(), credential.ID)
+
+	if _, err := app.AuthenticateToken(context.TODO(), credential.Token); err != nil {
+		t.Error(err)
+		return
+	}
+
+	// 轮换后旧密钥在宽限期内仍可使用
+	rotated, err := app.Rotate(context.TODO(), credential.ID)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err := app.Authenticate(context.TODO(), rotated.Key, rotated.Secret); err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err := app.Authenticate(context.TODO(), credential.Key, credential.Secret); err != nil {
+		t.Error(err)
+		return
+	}
+
+	// 吊销后不可使用
+	if _, err := app.Revoke(context.TODO(), credential.ID); err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err := app.Authenticate(context.TODO(), rotated.Key, rotated.Secret); err == nil {
+		t.Error("吊销后仍可认证")
+	}
+}
+
+func TestAppVerify(t *testing.T) {
+	app := srv.NewApp()
+	credential, err := app.Create(context.TODO(), &entity.App{Name: "test", SignatureRequired: true})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer app.Remove(context.TODO(), credential.ID)
+
+	// 必须签名时不允许直接使用密钥
+	if _, err := app.Authenticate(context.TODO(), credential.Key, credential.Secret); err == nil {
+		t.Error("必须签名的应用仍可直接使用密钥")
+	}
+
+	req := &srv.SignedRequest{
+		Key:       credential.Key,
+		Timestamp: strconv.FormatInt(time.Now().Unix(), 10),
+		Nonce:     strconv.FormatInt(time.Now().UnixNano(), 10),
+		Method:    "GET",
+		Path:      "/api/user/1",
+		Params:    url.Values{"name": {"test"}},
+	}
+	req.Signature = srv.Sign(credential.Secret, req.StringToSign())
+	if _, err := app.Verify(context.TODO(), req); err != nil {
+		t.Error(err)
+		return
+	}
+	// 重放
+	if _, err := app.Verify(context.TODO(), req); err == nil {
+		t.Error("重复的nonce未拒绝")
+	}
+}
@@ -0,0 +1,66 @@
+package srv_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/xuanbo/ohmydata/pkg/db"
+	"github.com/xuanbo/ohmydata/pkg/srv"
+)
+
+func TestBundle(t *testing.T) {
+	bundle := srv.NewBundle(srv.NewDataSource(), srv.NewDataSet())
+	doc, err := bundle.Export(context.TODO(), &srv.BundleExport{Secrets: srv.SecretReference})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	b, err := srv.EncodeBundle(doc, srv.BundleYAML)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	t.Logf("bundle: %s", string(b))
+
+	if doc, err = srv.DecodeBundle(b); err != nil {
+		t.Error(err)
+		return
+	}
+	result, err := bundle.Import(context.TODO(), doc, &srv.BundleImport{DryRun: true})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if b, err = json.Marshal(result); err != nil {
+		t.Error(err)
+		return
+	}
+	t.Logf("import: %s", string(b))
+}
+
+func TestBundleUnsetPassword(t *testing.T) {
+	bundle := srv.NewBundle(srv.NewDataSource(), srv.NewDataSet())
+	doc := &srv.BundleDoc{
+		DataSources: []*srv.BundleDataSource{{
+			Type:     "mysql",
+			Name:     "bundle-unset-password-" + db.NewID(),
+			URL:      "root@tcp(127.0.0.1:3306)/ohmydata",
+			Username: "root",
+			Password: "${OHMYDATA_TEST_UNSET_PASSWORD}",
+		}},
+	}
+	// 试运行返回冲突
+	result, err := bundle.Import(context.TODO(), doc, &srv.BundleImport{DryRun: true})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(result.Changes) != 1 || result.Changes[0].Action != srv.ChangeConflict {
+		t.Errorf("环境变量未设置时应为冲突: %+v", result.Changes)
+	}
+	// 导入时拒绝
+	if _, err := bundle.Import(context.TODO(), doc, &srv.BundleImport{}); err == nil {
+		t.Error("环境变量未设置时不应以空密码导入")
+	}
+}
@@ -0,0 +1,42 @@
+package srv_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/xuanbo/ohmydata/pkg/db"
+	"github.com/xuanbo/ohmydata/pkg/entity"
+	"github.com/xuanbo/ohmydata/pkg/srv"
+)
+
+func TestCallLogStats(t *testing.T) {
+	dataSetID := db.NewID()
+	now := entity.Now()
+	calls := []*entity.APICall{
+		{ID: db.NewID(), DataSetID: dataSetID, Caller: "app:1", Status: 200, Success: true, Latency: 10, CalledAt: &now},
+		{ID: db.NewID(), DataSetID: dataSetID, Caller: "app:1", Status: 200, Success: true, Latency: 20, CacheHit: true, CalledAt: &now},
+		{ID: db.NewID(), DataSetID: dataSetID, Caller: "app:2", Status: 429, Latency: 100, CalledAt: &now},
+	}
+	if err := db.DB().Create(&calls).Error; err != nil {
+		t.Error(err)
+		return
+	}
+	defer db.DB().Where("data_set_id = ?", dataSetID).Delete(&entity.APICall{})
+
+	end := entity.Time{Time: now.Add(time.Minute)}
+	stats, err := srv.NewCallLog().Stats(context.TODO(), &srv.CallStatsQuery{DataSetID: dataSetID, End: &end, Bucket: srv.CallBucketHour})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if stats.Summary.Calls != 3 || stats.Summary.Errors != 1 || stats.Summary.CacheHits != 1 {
+		t.Errorf("统计错误: %+v", stats.Summary)
+	}
+	if stats.Summary.P50 != 20 || stats.Summary.P99 != 100 {
+		t.Errorf("耗时分位数错误: %+v", stats.Summary)
+	}
+	if len(stats.TopCallers) != 2 || stats.TopCallers[0].Caller != "app:1" {
+		t.Errorf("调用者排行错误: %+v", stats.TopCallers)
+	}
+}
@@ -3,11 +3,17 @@ package srv_test
 import (
 	"context"
 	"encoding/json"
+	"net/http"
+	"strings"
 	"testing"
 
+	"github.com/xuanbo/ohmydata/pkg/api/util"
+	"github.com/xuanbo/ohmydata/pkg/db"
 	"github.com/xuanbo/ohmydata/pkg/entity"
 	"github.com/xuanbo/ohmydata/pkg/model"
 	"github.com/xuanbo/ohmydata/pkg/srv"
+
+	"github.com/labstack/echo/v4"
 )
 
 func TestDataSetCreate(t *testing.T) {
@@ -124,6 +130,282 @@ func TestDataSetParseExpression(t *testing.T) {
 	t.Logf("variables: %s", string(b))
 }
 
+func TestDataSetRender(t *testing.T) {
+	dataSet := srv.NewDataSet()
+	rendering, err := dataSet.Render(context.TODO(), &entity.DataSet{
+		SourceID:   "1347065257465483264",
+		Expression: "select * from oh_data_source where name = {{bind .name}} and max_open_conns > {{bind .conns}}",
+		EnablePage: true,
+		RequestParams: []*entity.RequestParam{
+			{
+				Name:         "name",
+				ParamType:    entity.String,
+				DefaultValue: "test",
+			},
+			{
+				Name:         "conns",
+				ParamType:    entity.Int,
+				DefaultValue: "8",
+			},
+		},
+	}, map[string]interface{}{"page": 2, "size": 5})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	// 默认值按参数类型转换
+	if len(rendering.Args) != 2 || rendering.Args[0] != "test" || rendering.Args[1] != int64(8) {
+		t.Errorf("默认值错误: %v", rendering.Args)
+	}
+	b, err := json.Marshal(rendering)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	t.Logf("rendering: %s", string(b))
+}
+
+func TestDataSetRenderWrite(t *testing.T) {
+	dataSet := srv.NewDataSet()
+	rendering, err := dataSet.Render(context.TODO(), &entity.DataSet{
+		SourceID:   "1347065257465483264",
+		Kind:       entity.KindWrite,
+		Method:     http.MethodPut,
+		Expression: "update oh_data_source set name = {{bind .name}} where id = {{bind .id}}",
+	}, map[string]interface{}{"id": "1", "name": "test"})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if strings.Count(rendering.SQL, "?") != 2 {
+		t.Errorf("bind未替换为占位符: %s", rendering.SQL)
+	}
+	if len(rendering.Args) != 2 || rendering.Args[0] != "test" || rendering.Args[1] != "1" {
+		t.Errorf("绑定参数顺序错误: %v", rendering.Args)
+	}
+
+	_, err = dataSet.Render(context.TODO(), &entity.DataSet{
+		SourceID:   "1347065257465483264",
+		Kind:       entity.KindWrite,
+		Method:     http.MethodPost,
+		Expression: "drop table oh_data_source",
+	}, map[string]interface{}{})
+	if err == nil {
+		t.Error("写操作数据集不能执行DDL")
+	}
+}
+
+func TestDataSetExecIdempotent(t *testing.T) {
+	dataSet := srv.NewDataSet()
+	if err := dataSet.LoadRoutes(context.TODO()); err != nil {
+		t.Error(err)
+		return
+	}
+	ctx := context.WithValue(srv.WithInternal(context.TODO()), util.IdempotencyKey, db.NewID())
+	params := map[string]interface{}{"name": "idempotent"}
+	first, err := dataSet.ServeAPI(ctx, http.MethodPost, "user", params)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	// 相同key重试返回首次执行结果
+	second, err := dataSet.ServeAPI(ctx, http.MethodPost, "user", map[string]interface{}{"name": "idempotent"})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	a, _ := json.Marshal(first)
+	b, _ := json.Marshal(second)
+	if string(a) != string(b) {
+		t.Errorf("重试结果与首次执行结果不一致: %s, %s", a, b)
+	}
+	// 相同key不同参数
+	_, err = dataSet.ServeAPI(ctx, http.MethodPost, "user", map[string]interface{}{"name": "other"})
+	if he, ok := err.(*echo.HTTPError); !ok || he.Code != http.StatusUnprocessableEntity {
+		t.Errorf("相同Idempotency-Key不同参数应返回422: %v", err)
+	}
+}
+
+func TestDataSetExecIdempotentCallers(t *testing.T) {
+	dataSet := srv.NewDataSet()
+	if err := dataSet.LoadRoutes(context.TODO()); err != nil {
+		t.Error(err)
+		return
+	}
+	key := db.NewID()
+	ctx := context.WithValue(srv.WithInternal(context.TODO()), util.IdempotencyKey, key)
+	first, err := dataSet.ServeAPI(context.WithValue(ctx, util.UserID, "caller-a"), http.MethodPost, "user", map[string]interface{}{"name": "idempotent"})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	// 其他调用者使用相同key以及参数时重新执行
+	second, err := dataSet.ServeAPI(context.WithValue(ctx, util.UserID, "caller-b"), http.MethodPost, "user", map[string]interface{}{"name": "idempotent"})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	a, _ := json.Marshal(first)
+	b, _ := json.Marshal(second)
+	if string(a) == string(b) {
+		t.Errorf("不同调用者使用相同Idempotency-Key时返回了其他调用者的结果: %s", a)
+	}
+}
+
+func TestDataSetScaffold(t *testing.T) {
+	dataSet := srv.NewDataSet()
+	list, err := dataSet.Scaffold(context.TODO(), &srv.Scaffold{
+		SourceID: "1347065257465483264",
+		Table:    "oh_data_source",
+		Path:     "/scaffold/data-source",
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	// 主键由应用生成，新增时为必填参数
+	for _, e := range list {
+		if e.Kind != entity.KindWrite || e.Method != http.MethodPost {
+			continue
+		}
+		var found bool
+		for _, p := range e.RequestParams {
+			if p.Name == "id" {
+				found = p.Required
+			}
+		}
+		if !found || !strings.Contains(e.Expression, "`id`") {
+			t.Errorf("新增数据集缺少主键: %s", e.Expression)
+		}
+	}
+	b, err := json.Marshal(list)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	t.Logf("dataSets: %s", string(b))
+}
+
+func TestDataSetVersions(t *testing.T) {
+	dataSet := srv.NewDataSet()
+	list, err := dataSet.Versions(context.TODO(), "1347469523384537088")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(list) < 2 {
+		t.Logf("versions: %d", len(list))
+		return
+	}
+	diff, err := dataSet.DiffVersions(context.TODO(), "1347469523384537088", list[1].Version, list[0].Version)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	b, err := json.Marshal(diff)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	t.Logf("diff: %s", string(b))
+}
+
+func TestDataSetTransition(t *testing.T) {
+	dataSet := srv.NewDataSet()
+	ctx := context.WithValue(context.TODO(), util.UserID, "1347065257465483264")
+	review, err := dataSet.Transition(ctx, "1347469523384537088", &srv.Transition{
+		Action:  entity.ActionComment,
+		Comment: "请补充请求参数说明",
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	b, err := json.Marshal(review)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	t.Logf("review: %s", string(b))
+}
+
+func TestDataSetOpenAPI(t *testing.T) {
+	dataSet := srv.NewDataSet()
+	if err := dataSet.LoadRoutes(context.TODO()); err != nil {
+		t.Error(err)
+		return
+	}
+	doc, err := dataSet.OpenAPI(context.TODO())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(doc.Security) != 4 {
+		t.Errorf("认证方式错误: %v", doc.Security)
+	}
+	for _, requirement := range doc.Security {
+		for name := range requirement {
+			if _, ok := doc.Components.SecuritySchemes[name]; !ok {
+				t.Errorf("未定义认证方式%s", name)
+			}
+		}
+	}
+	b, err := json.Marshal(doc)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	t.Logf("openapi: %s", string(b))
+}
+
+func TestDataSetGraphQL(t *testing.T) {
+	dataSet := srv.NewDataSet()
+	if err := dataSet.LoadRoutes(context.TODO()); err != nil {
+		t.Error(err)
+		return
+	}
+	result, err := dataSet.GraphQL(srv.WithInternal(context.TODO()), &srv.GraphQLRequest{
+		Query: "{ __schema { queryType { fields { name args { name } } } } }",
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if result.HasErrors() {
+		t.Error(result.Errors)
+		return
+	}
+	b, err := json.Marshal(result)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	t.Logf("graphql: %s", string(b))
+}
+
+func TestDataSetServeBatch(t *testing.T) {
+	dataSet := srv.NewDataSet()
+	if err := dataSet.LoadRoutes(context.TODO()); err != nil {
+		t.Error(err)
+		return
+	}
+	results, err := dataSet.ServeBatch(context.TODO(), []*srv.BatchCall{
+		{Path: "user/page", Params: map[string]interface{}{"page": 1, "size": 10}},
+		{Path: "/api/user/1", Method: "GET"},
+		{Path: "not-found"},
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	b, err := json.Marshal(results)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	t.Logf("batch: %s", string(b))
+}
+
 func TestRouter(t *testing.T) {
 	router := new(srv.Node)
 	router.Add("/user", "/user")
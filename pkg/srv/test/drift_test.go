@@ -0,0 +1,40 @@
+package srv_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/xuanbo/ohmydata/pkg/model"
+	"github.com/xuanbo/ohmydata/pkg/srv"
+)
+
+func TestDriftCheck(t *testing.T) {
+	drift := srv.NewDrift(srv.NewDataSet())
+	events, err := drift.Check(context.TODO())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	b, err := json.Marshal(events)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	t.Logf("events: %s", string(b))
+}
+
+func TestDriftPage(t *testing.T) {
+	drift := srv.NewDrift(srv.NewDataSet())
+	pagination := model.NewPagination(1, 10)
+	if err := drift.Page(context.TODO(), "", pagination); err != nil {
+		t.Error(err)
+		return
+	}
+	b, err := json.Marshal(pagination)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	t.Logf("page: %s", string(b))
+}
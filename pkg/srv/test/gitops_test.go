@@ -0,0 +1,103 @@
+package srv_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/xuanbo/ohmydata/pkg/srv"
+)
+
+const gitOpsDataSource = `
+dataSources:
+  - type: mysql
+    name: gitops-test
+    url: root:123456@tcp(127.0.0.1:3306)/ohmydata?charset=utf8
+`
+
+const gitOpsDataSet = `
+dataSets:
+  - source: gitops-test
+    name: gitops-test
+    path: gitops/test
+    expression: select id, name from oh_data_source
+    published: true
+    responseParams:
+      - name: id
+        paramType: 6
+      - name: name
+        paramType: 6
+`
+
+func TestGitOpsReconcile(t *testing.T) {
+	if err := os.MkdirAll("gitops", 0755); err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.RemoveAll("gitops")
+	write := func(name, content string) {
+		if err := ioutil.WriteFile(filepath.Join("gitops", name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dataSet := srv.NewDataSet()
+	gitOps := srv.NewGitOps(srv.NewBundle(srv.NewDataSource(), dataSet))
+
+	// 新增并按定义发布
+	write("datasource.yaml", gitOpsDataSource)
+	write("dataset.yaml", gitOpsDataSet)
+	status, err := gitOps.Reconcile(context.TODO())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(status.Errors) > 0 {
+		t.Errorf("同步错误: %+v", status.Errors[0])
+		return
+	}
+	if err := dataSet.LoadRoutes(context.TODO()); err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err := dataSet.Lookup(context.TODO(), "GET", "gitops/test", map[string]interface{}{}); err != nil {
+		t.Errorf("定义发布的数据集未上线: %v", err)
+	}
+
+	// 存在无法解析的文件时不删除
+	write("dataset.yaml", "dataSets: [")
+	if status, err = gitOps.Reconcile(context.TODO()); err != nil {
+		t.Error(err)
+		return
+	}
+	if len(status.Errors) == 0 || status.Errors[0].File != "dataset.yaml" {
+		t.Errorf("未记录解析错误: %+v", status.Errors)
+	}
+	for _, change := range status.Changes {
+		if change.Action == srv.ChangeDelete {
+			t.Errorf("部分文件解析失败时删除了%s", change.Name)
+		}
+	}
+
+	// 不再定义时删除
+	if err := os.Remove(filepath.Join("gitops", "dataset.yaml")); err != nil {
+		t.Error(err)
+		return
+	}
+	write("datasource.yaml", "dataSources: []")
+	if status, err = gitOps.Reconcile(context.TODO()); err != nil {
+		t.Error(err)
+		return
+	}
+	deleted := make(map[string]bool)
+	for _, change := range status.Changes {
+		if change.Action == srv.ChangeDelete {
+			deleted[change.Type] = true
+		}
+	}
+	if !deleted["dataSet"] || !deleted["dataSource"] {
+		t.Errorf("不再定义的记录未删除: %+v", status.Changes)
+	}
+}
@@ -0,0 +1,55 @@
+package srv_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/xuanbo/ohmydata/pkg/api/util"
+	"github.com/xuanbo/ohmydata/pkg/entity"
+	"github.com/xuanbo/ohmydata/pkg/srv"
+)
+
+func TestGrant(t *testing.T) {
+	grant := srv.NewGrant()
+	group := &entity.UserGroup{Name: "test"}
+	if err := grant.CreateGroup(context.TODO(), group); err != nil {
+		t.Error(err)
+		return
+	}
+	defer grant.RemoveGroup(context.TODO(), group.ID)
+	if err := grant.SetMembers(context.TODO(), group.ID, []string{"test-user"}); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := grant.Create(context.TODO(), &entity.Grant{
+		SubjectType: entity.SubjectGroup,
+		SubjectID:   group.ID,
+		PathPrefix:  "user",
+	}); err != nil {
+		t.Error(err)
+		return
+	}
+
+	ctx := context.WithValue(context.TODO(), util.UserID, "test-user")
+	for path, want := range map[string]bool{"user": true, "user/:id": true, "users": false, "order/:id": false} {
+		ok, err := grant.Allowed(ctx, &entity.DataSet{Path: path})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if ok != want {
+			t.Errorf("path %s allowed %v, want %v", path, ok, want)
+		}
+	}
+	if err := grant.Authorize(ctx, "GET", "order/1", &entity.DataSet{Path: "order/:id"}); err == nil {
+		t.Error("未授权的API未拒绝")
+	}
+
+	// 无调用者时拒绝，仅内部调用不校验
+	if ok, err := grant.Allowed(context.TODO(), &entity.DataSet{Path: "user"}); err != nil || ok {
+		t.Errorf("无调用者时应拒绝: %v, %v", ok, err)
+	}
+	if ok, err := grant.Allowed(srv.WithInternal(context.TODO()), &entity.DataSet{Path: "order/:id"}); err != nil || !ok {
+		t.Errorf("内部调用应允许: %v, %v", ok, err)
+	}
+}
@@ -0,0 +1,95 @@
+package srv_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/xuanbo/ohmydata/pkg/api/util"
+	"github.com/xuanbo/ohmydata/pkg/db"
+	"github.com/xuanbo/ohmydata/pkg/entity"
+	"github.com/xuanbo/ohmydata/pkg/srv"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestRateLimiter(t *testing.T) {
+	limiter := srv.NewRateLimiter()
+	limit := &entity.RateLimit{
+		Scope:      entity.ScopeIP,
+		Target:     "10.0.0.1",
+		Rate:       0.1,
+		Burst:      2,
+		DailyQuota: 100,
+	}
+	if err := limiter.Create(context.TODO(), limit); err != nil {
+		t.Error(err)
+		return
+	}
+	defer limiter.Remove(context.TODO(), limit.ID)
+
+	header := http.Header{}
+	ctx := context.WithValue(context.TODO(), util.ClientIP, "10.0.0.1")
+	ctx = context.WithValue(ctx, util.ResponseHeader, header)
+	dataSet := &entity.DataSet{Path: "user"}
+	for i := 0; i < 2; i++ {
+		if err := limiter.Check(ctx, dataSet); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+	err := limiter.Check(ctx, dataSet)
+	if he, ok := err.(*echo.HTTPError); !ok || he.Code != http.StatusTooManyRequests {
+		t.Errorf("超过突发容量未限流: %v", err)
+	}
+	if header.Get("Retry-After") == "" {
+		t.Error("未设置Retry-After响应头")
+	}
+
+	usage, err := limiter.Usage(context.TODO(), entity.ScopeIP, "10.0.0.1")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	t.Log(usage.Daily, usage.Monthly)
+}
+
+func TestRateLimiterAllScopes(t *testing.T) {
+	var (
+		limiter   = srv.NewRateLimiter()
+		dataSetID = db.NewID()
+		appID     = db.NewID()
+		limits    = []*entity.RateLimit{
+			{Scope: entity.ScopeDataSet, Target: dataSetID, DailyQuota: 100},
+			{Scope: entity.ScopeApp, Target: appID, Rate: 0.1, Burst: 1},
+		}
+	)
+	for _, limit := range limits {
+		if err := limiter.Create(context.TODO(), limit); err != nil {
+			t.Error(err)
+			return
+		}
+		defer limiter.Remove(context.TODO(), limit.ID)
+	}
+
+	ctx := context.WithValue(context.TODO(), util.AppID, appID)
+	dataSet := &entity.DataSet{Path: "user"}
+	dataSet.ID = dataSetID
+	if err := limiter.Check(ctx, dataSet); err != nil {
+		t.Error(err)
+		return
+	}
+	// 应用维度拒绝时数据集维度不计数
+	err := limiter.Check(ctx, dataSet)
+	if he, ok := err.(*echo.HTTPError); !ok || he.Code != http.StatusTooManyRequests {
+		t.Errorf("超过突发容量未限流: %v", err)
+	}
+	usage, err := limiter.Usage(context.TODO(), entity.ScopeDataSet, dataSetID)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if usage.Daily != 1 {
+		t.Errorf("被拒绝的请求不应计入配额，当日调用次数: %d", usage.Daily)
+	}
+}
@@ -0,0 +1,57 @@
+package srv_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/xuanbo/ohmydata/pkg/srv"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/sdk/export/trace/tracetest"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/semconv"
+)
+
+func TestDataSetTracing(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	defer otel.SetTracerProvider(previous)
+
+	dataSet := srv.NewDataSet()
+	if err := dataSet.LoadRoutes(context.TODO()); err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err := dataSet.ServeAPI(srv.WithInternal(context.TODO()), "GET", "user/1", map[string]interface{}{}); err != nil {
+		t.Error(err)
+		return
+	}
+
+	names := make(map[string]bool)
+	for _, span := range exporter.GetSpans() {
+		names[span.Name] = true
+		if span.Name != "adapter.query" {
+			continue
+		}
+		var statement bool
+		for _, kv := range span.Attributes {
+			if kv.Key == semconv.DBStatementKey && kv.Value.AsString() != "" {
+				statement = true
+			}
+		}
+		if !statement {
+			t.Error("数据源调用的span未记录语句")
+		}
+	}
+	required := []string{"api.call", "pagination.parse"}
+	// 命中缓存时不渲染、不查询数据源
+	if !names["cache.get"] || names["cache.set"] {
+		required = append(required, "template.render", "adapter.query")
+	}
+	for _, name := range required {
+		if !names[name] {
+			t.Errorf("缺少span: %s", name)
+		}
+	}
+}
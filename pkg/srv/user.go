@@ -108,3 +108,38 @@ func (u *User) Login(ctx context.Context, user *entity.User) (map[string]interfa
 	}
 	return res, nil
 }
+
+// Create 新增用户
+func (u *User) Create(ctx context.Context, user *entity.User) error {
+	if user.Username == "" || user.Password == "" {
+		return errors.New("用户名以及密码不能为空")
+	}
+	var total int64
+	if err := u.db.WithContext(ctx).Model(&entity.User{}).Where("username = ?", user.Username).Count(&total).Error; err != nil {
+		return err
+	}
+	if total > 0 {
+		return errors.New("用户名已存在")
+	}
+	if user.Name == "" {
+		user.Name = user.Username
+	}
+	user.ID = db.NewID()
+	return u.db.WithContext(ctx).Create(user).Error
+}
+
+// ResetPassword 重置密码
+func (u *User) ResetPassword(ctx context.Context, username, password string) error {
+	if password == "" {
+		return errors.New("密码不能为空")
+	}
+	result := u.db.WithContext(ctx).Model(&entity.User{}).Where("username = ?", username).Update("password", password)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("用户不存在")
+	}
+	// 清除缓存
+	return cache.Del(ctx, "ohmydata:user:username:"+username)
+}
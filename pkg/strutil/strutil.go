@@ -0,0 +1,11 @@
+package strutil
+
+// EqualAny 判断v是否等于list中的任一元素
+func EqualAny(v string, list []string) bool {
+	for _, e := range list {
+		if v == e {
+			return true
+		}
+	}
+	return false
+}
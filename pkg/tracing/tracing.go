@@ -0,0 +1,92 @@
+package tracing
+
+import (
+	"context"
+
+	"github.com/xuanbo/ohmydata/pkg/config"
+	"github.com/xuanbo/ohmydata/pkg/log"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlphttp"
+	"go.opentelemetry.io/otel/label"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/semconv"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+const tracerName = "github.com/xuanbo/ohmydata"
+
+// span属性
+const (
+	DataSetIDKey   = label.Key("ohmydata.dataset.id")
+	DataSetPathKey = label.Key("ohmydata.dataset.path")
+	SourceIDKey    = label.Key("ohmydata.source.id")
+	CacheKeyKey    = label.Key("ohmydata.cache.key")
+	CacheHitKey    = label.Key("ohmydata.cache.hit")
+)
+
+// Init 注册W3C trace context传播，开启后通过OTLP/HTTP上报span，返回关闭方法，关闭时上报剩余的span
+func Init(ctx context.Context) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	if !config.GetBool("tracing.enabled") {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	var opts []otlphttp.Option
+	endpoint := config.GetString("tracing.endpoint")
+	if endpoint != "" {
+		opts = append(opts, otlphttp.WithEndpoint(endpoint))
+	}
+	if config.GetBool("tracing.insecure") {
+		opts = append(opts, otlphttp.WithInsecure())
+	}
+	exporter, err := otlp.NewExporter(ctx, otlphttp.NewDriver(opts...))
+	if err != nil {
+		return nil, err
+	}
+
+	serviceName := config.GetString("tracing.serviceName")
+	if serviceName == "" {
+		serviceName = "ohmydata"
+	}
+	ratio := config.GetFloat64("tracing.sampleRatio")
+	if ratio <= 0 || ratio > 1 {
+		ratio = 1
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithConfig(sdktrace.Config{
+			// 上游已采样的请求继续采样
+			DefaultSampler: sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio)),
+		}),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.ServiceNameKey.String(serviceName))),
+		sdktrace.WithBatcher(exporter),
+	)
+	otel.SetTracerProvider(provider)
+
+	log.Logger().Info("开启链路追踪", zap.String("endpoint", endpoint), zap.Float64("sampleRatio", ratio))
+	return provider.Shutdown, nil
+}
+
+// Tracer 当前的tracer，未开启时为空实现
+func Tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// Start 创建span
+func Start(ctx context.Context, name string, attrs ...label.KeyValue) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End 结束span，err不为空时记录错误
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
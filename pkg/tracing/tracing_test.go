@@ -0,0 +1,58 @@
+package tracing_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/xuanbo/ohmydata/pkg/tracing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/export/trace/tracetest"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestTracing(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	defer otel.SetTracerProvider(previous)
+
+	// 延续上游的trace
+	carrier := map[string]string{"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}
+	ctx := propagation.TraceContext{}.Extract(context.TODO(), mapCarrier(carrier))
+	ctx, parent := tracing.Start(ctx, "api.call", tracing.DataSetPathKey.String("user/:id"))
+	_, child := tracing.Start(ctx, "adapter.query")
+	tracing.End(child, errors.New("timeout"))
+	tracing.End(parent, nil)
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Errorf("span数量 %d, want 2", len(spans))
+		return
+	}
+	for _, span := range spans {
+		if span.SpanContext.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+			t.Errorf("span %s 未延续上游trace: %s", span.Name, span.SpanContext.TraceID)
+		}
+	}
+	if spans[0].Name != "adapter.query" || spans[0].StatusCode != codes.Error {
+		t.Errorf("错误未记录到span: %s %v", spans[0].Name, spans[0].StatusCode)
+	}
+	if spans[0].ParentSpanID != spans[1].SpanContext.SpanID {
+		t.Error("子span的父span错误")
+	}
+}
+
+type mapCarrier map[string]string
+
+func (c mapCarrier) Get(key string) string {
+	return c[key]
+}
+
+func (c mapCarrier) Set(key, value string) {
+	c[key] = value
+}